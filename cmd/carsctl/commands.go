@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"cars-service/internal/spreadsheet"
+	"cars-service/migrations"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// errFailed is returned by commands which printed their results but failed for some of arguments
+var errFailed = errors.New("some of cars were not processed")
+
+func runAdd(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+
+	cars := make([]model.Car, flags.NArg())
+	for i, regNum := range flags.Args() {
+		cars[i] = model.Car{RegNum: regNum}
+	}
+	items := env.app.ImportCars(ctx, cars)
+
+	results := make([]importResult, len(items))
+	for i, item := range items {
+		results[i] = importResult{
+			RegNum: item.RegNum,
+			Status: item.Status,
+			CarId:  item.CarId,
+			Error:  item.Error,
+		}
+	}
+	return printImportResults(*output, results)
+}
+
+func runImport(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	rows, err := spreadsheet.Read(file, file.Name())
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", file.Name(), err)
+	}
+
+	// only successfully parsed rows are imported, the rest are reported as failed
+	cars := make([]model.Car, 0, len(rows))
+	for _, row := range rows {
+		if row.Err == nil {
+			cars = append(cars, row.Car)
+		}
+	}
+	items := env.app.ImportCars(ctx, cars)
+
+	results := make([]importResult, len(rows))
+	for i, row := range rows {
+		results[i] = importResult{
+			Line:   row.Line,
+			RegNum: row.Car.RegNum,
+		}
+		if row.Err != nil {
+			results[i].Status = model.ImportFailed
+			results[i].Error = row.Err.Error()
+			continue
+		}
+		results[i].Status = items[0].Status
+		results[i].CarId = items[0].CarId
+		results[i].Error = items[0].Error
+		items = items[1:]
+	}
+	return printImportResults(*output, results)
+}
+
+func runList(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	output := outputFlag(flags)
+	var filter model.Filter
+	flags.UintVar(&filter.Limit, "limit", 0, "maximum number of cars, 0 means no limit")
+	flags.UintVar(&filter.Offset, "offset", 0, "number of skipped cars")
+	flags.StringVar(&filter.RegNum, "regNum", "", "registration number")
+	flags.StringVar(&filter.Mark, "mark", "", "mark")
+	flags.StringVar(&filter.Model, "model", "", "model")
+	flags.IntVar(&filter.Year, "year", 0, "year")
+	flags.StringVar(&filter.OwnerName, "ownerName", "", "name of the owner")
+	flags.StringVar(&filter.OwnerSurname, "ownerSurname", "", "surname of the owner")
+	flags.StringVar(&filter.OwnerPatronymic, "ownerPatronymic", "", "patronymic of the owner")
+	sortBy := flags.String("sort", string(model.SortById), "field cars are ordered by")
+	flags.BoolVar(&filter.SortDesc, "desc", false, "descending order")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+	filter.SortBy = model.CarSortField(*sortBy)
+
+	// only flags which are set are used for filtering, so empty values can be found too
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "regNum":
+			filter.ByRegNum = true
+		case "mark":
+			filter.ByMark = true
+		case "model":
+			filter.ByModel = true
+		case "year":
+			filter.ByYear = true
+		case "ownerName":
+			filter.ByOwnerName = true
+		case "ownerSurname":
+			filter.ByOwnerSurname = true
+		case "ownerPatronymic":
+			filter.ByOwnerPatronymic = true
+		}
+	})
+
+	p, err := newCarPrinter(*output)
+	if err != nil {
+		return err
+	}
+	if err = env.app.IterateCars(ctx, filter, p.Print); err != nil {
+		return err
+	}
+	return p.Close()
+}
+
+func runDelete(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+	ids, err := parseIds(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	var res error
+	for _, id := range ids {
+		if err = env.app.DeleteCar(ctx, id); err != nil {
+			fmt.Fprintf(os.Stderr, "car %d: %v\n", id, err)
+			res = errFailed
+			continue
+		}
+		fmt.Printf("car %d deleted\n", id)
+	}
+	return res
+}
+
+func runMergeOwners(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("merge-owners", flag.ContinueOnError)
+	output := outputFlag(flags)
+	into := flags.Uint64("into", 0, "id of the owner which gets cars of duplicates")
+	if err := flags.Parse(args); err != nil || *into == 0 || flags.NArg() == 0 {
+		return errUsage
+	}
+	from, err := parseIds(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	cars, err := env.app.MergeOwners(ctx, *into, from)
+	if err != nil {
+		return err
+	}
+	p, err := newCarPrinter(*output)
+	if err != nil {
+		return err
+	}
+	for _, car := range cars {
+		if err = p.Print(car); err != nil {
+			return err
+		}
+	}
+	return p.Close()
+}
+
+func runMigrate(ctx context.Context, env *environment, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	baseline := flags.Int("baseline", 0, "version up to which migrations are marked as applied without running")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	applied, err := repo.Migrate(ctx, env.pool, migrations.FS, *baseline)
+	for _, m := range applied {
+		if m.Version <= *baseline {
+			fmt.Printf("%s marked as applied\n", m.Name)
+		} else {
+			fmt.Printf("%s applied\n", m.Name)
+		}
+	}
+	if err == nil && len(applied) == 0 {
+		fmt.Println("no new migrations")
+	}
+	return err
+}
+
+func parseIds(args []string) ([]uint64, error) {
+	ids := make([]uint64, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: id %q", model.ErrInvalidInput, arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"cars-service/internal/adapters/api"
+	"cars-service/internal/app"
+	"cars-service/internal/config"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `carsctl is a maintenance tool for the catalog of cars
+
+Usage:
+	carsctl [-config file.yaml] [config flags] <command> [flags] [args]
+
+Commands:
+	add [-output table|json] REGNUM...
+		get data of cars from the outer API and add them
+	import [-output table|json] FILE
+		add cars from CSV or XLSX file with the same columns as the HTTP export
+	list [-output table|json] [-limit N] [-offset N] [-sort field] [-desc] [filters]
+		print cars, filters are -regNum, -mark, -model, -year, -ownerName,
+		-ownerSurname and -ownerPatronymic, fields of -sort are id, regNum, mark,
+		model and year
+	delete ID...
+		delete cars
+	merge-owners [-output table|json] -into ID FROM_ID...
+		move cars of duplicate owners to the owner and delete duplicates
+	migrate [-baseline N]
+		apply new migrations, migrations up to the baseline version are only
+		marked as applied, it is needed for the database created by docker
+
+Global flags:
+`
+
+// command runs a subcommand with its arguments
+type command func(ctx context.Context, env *environment, args []string) error
+
+var commands = map[string]command{
+	"add":          runAdd,
+	"import":       runImport,
+	"list":         runList,
+	"delete":       runDelete,
+	"merge-owners": runMergeOwners,
+	"migrate":      runMigrate,
+}
+
+// environment contains dependencies of commands
+type environment struct {
+	pool *pgxpool.Pool
+	app  app.App
+}
+
+// errUsage is returned by commands for wrong arguments, usage is printed for it
+var errUsage = errors.New("wrong arguments")
+
+func main() {
+	flags := flag.NewFlagSet("carsctl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	// only errors are logged by default, so they do not mix with the output of commands
+	defaults := config.Default()
+	defaults.Log.Level = "error"
+	cfg, err := config.Load(flags, os.Args[1:], defaults)
+	if err != nil {
+		fatal(err)
+	}
+
+	run, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err = cfg.Validate(); err != nil {
+		fatal(err)
+	}
+	logs, _ := logger.NewWithLevel(cfg.Log.Level)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	apiCli, err := api.NewFromConfig(cfg.Api)
+	if err != nil {
+		fatal(err)
+	}
+	pool, err := repo.Connect(ctx, cfg.Postgres, logs)
+	if err != nil {
+		fatal(err)
+	}
+	defer pool.Close()
+
+	env := &environment{
+		pool: pool,
+		app: app.New(
+			repo.New(pool),
+			repo.NewJobRepo(pool),
+			repo.NewWebhookRepo(pool),
+			repo.NewRefreshRepo(pool),
+			apiCli,
+			logs,
+		),
+	}
+	if err = run(ctx, env, flags.Args()[1:]); errors.Is(err, errUsage) {
+		flags.Usage()
+		pool.Close()
+		os.Exit(2)
+	} else if err != nil {
+		pool.Close()
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "carsctl:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"cars-service/internal/model"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("output", outputTable, "output format, table or json")
+}
+
+type carJSON struct {
+	Id     uint64    `json:"id"`
+	RegNum string    `json:"regNum"`
+	Mark   string    `json:"mark"`
+	Model  string    `json:"model"`
+	Year   int       `json:"year"`
+	Owner  ownerJSON `json:"owner"`
+}
+
+type ownerJSON struct {
+	Id         uint64 `json:"id"`
+	Name       string `json:"name"`
+	Surname    string `json:"surname"`
+	Patronymic string `json:"patronymic"`
+}
+
+// importResult is a result of adding a car, Line is set only for cars read from a file
+type importResult struct {
+	Line   int                `json:"line,omitempty"`
+	RegNum string             `json:"regNum"`
+	Status model.ImportStatus `json:"status"`
+	CarId  uint64             `json:"carId,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// carPrinter writes cars while they are read, JSON is written as an array
+type carPrinter struct {
+	output string
+	tw     *tabwriter.Writer
+	n      int
+}
+
+func newCarPrinter(output string) (*carPrinter, error) {
+	p := &carPrinter{output: output}
+	switch output {
+	case outputTable:
+		p.tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, err := fmt.Fprintln(p.tw, "ID\tREGNUM\tMARK\tMODEL\tYEAR\tOWNER ID\tOWNER")
+		return p, err
+	case outputJSON:
+		_, err := fmt.Print("[")
+		return p, err
+	default:
+		return nil, fmt.Errorf("%w: unknown output %q", model.ErrInvalidInput, output)
+	}
+}
+
+func (p *carPrinter) Print(car model.Car) error {
+	p.n++
+	if p.output == outputTable {
+		_, err := fmt.Fprintf(p.tw, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			car.Id,
+			car.RegNum,
+			car.Mark,
+			car.Model,
+			car.Year,
+			car.Owner.Id,
+			strings.TrimSpace(car.Owner.Surname+" "+car.Owner.Name+" "+car.Owner.Patronymic),
+		)
+		return err
+	}
+
+	data, err := json.MarshalIndent(carJSON{
+		Id:     car.Id,
+		RegNum: car.RegNum,
+		Mark:   car.Mark,
+		Model:  car.Model,
+		Year:   car.Year,
+		Owner:  ownerJSON(car.Owner),
+	}, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if p.n == 1 {
+		sep = "\n  "
+	}
+	_, err = fmt.Print(sep + string(data))
+	return err
+}
+
+// Close finishes the output
+func (p *carPrinter) Close() error {
+	if p.output == outputTable {
+		return p.tw.Flush()
+	}
+	if p.n == 0 {
+		_, err := fmt.Println("]")
+		return err
+	}
+	_, err := fmt.Println("\n]")
+	return err
+}
+
+// printImportResults prints results and returns errFailed if some of cars were not added
+func printImportResults(output string, results []importResult) error {
+	switch output {
+	case outputTable:
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "LINE\tREGNUM\tSTATUS\tCAR ID\tERROR")
+		for _, r := range results {
+			line, carId := "-", "-"
+			if r.Line != 0 {
+				line = fmt.Sprint(r.Line)
+			}
+			if r.CarId != 0 {
+				carId = fmt.Sprint(r.CarId)
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", line, r.RegNum, r.Status, carId, r.Error)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown output %q", model.ErrInvalidInput, output)
+	}
+
+	for _, r := range results {
+		if r.Status != model.ImportDone {
+			return errFailed
+		}
+	}
+	return nil
+}
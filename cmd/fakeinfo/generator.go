@@ -0,0 +1,167 @@
+package main
+
+import (
+	"github.com/Pallinder/go-randomdata"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+// carInfo is the response of the outer API expected by the api adapter
+type carInfo struct {
+	RegNum string    `json:"regNum"`
+	Mark   string    `json:"mark"`
+	Model  string    `json:"model"`
+	Year   int       `json:"year"`
+	Owner  ownerInfo `json:"owner"`
+}
+
+type ownerInfo struct {
+	Name       string `json:"name"`
+	Surname    string `json:"surname"`
+	Patronymic string `json:"patronymic,omitempty"`
+}
+
+// maleName is a male name with the stem of patronymics derived from it
+type maleName struct {
+	name string
+	// male patronymic is stem+"ич", female one is stem+"на"
+	stem string
+}
+
+var maleNames = []maleName{
+	{"Александр", "Александров"}, {"Алексей", "Алексеев"}, {"Андрей", "Андреев"},
+	{"Антон", "Антонов"}, {"Артём", "Артёмов"}, {"Борис", "Борисов"},
+	{"Вадим", "Вадимов"}, {"Василий", "Васильев"}, {"Виктор", "Викторов"},
+	{"Владимир", "Владимиров"}, {"Дмитрий", "Дмитриев"}, {"Евгений", "Евгеньев"},
+	{"Егор", "Егоров"}, {"Иван", "Иванов"}, {"Игорь", "Игорев"},
+	{"Кирилл", "Кириллов"}, {"Константин", "Константинов"}, {"Максим", "Максимов"},
+	{"Михаил", "Михайлов"}, {"Николай", "Николаев"}, {"Олег", "Олегов"},
+	{"Павел", "Павлов"}, {"Роман", "Романов"}, {"Сергей", "Сергеев"},
+	{"Станислав", "Станиславов"}, {"Юрий", "Юрьев"},
+}
+
+var femaleNames = []string{
+	"Александра", "Алина", "Анастасия", "Анна", "Валентина", "Виктория", "Галина",
+	"Дарья", "Екатерина", "Елена", "Ирина", "Ксения", "Людмила", "Марина", "Мария",
+	"Наталья", "Ольга", "Полина", "Светлана", "Софья", "Татьяна", "Юлия",
+}
+
+// surnames are male forms, female forms are made by feminineSurname
+var surnames = []string{
+	"Иванов", "Смирнов", "Кузнецов", "Попов", "Васильев", "Петров", "Соколов",
+	"Михайлов", "Новиков", "Фёдоров", "Морозов", "Волков", "Алексеев", "Лебедев",
+	"Семёнов", "Егоров", "Павлов", "Козлов", "Степанов", "Николаев", "Орлов",
+	"Андреев", "Макаров", "Никитин", "Захаров", "Зайцев", "Соловьёв", "Борисов",
+	"Яковлев", "Григорьев", "Романов", "Воробьёв", "Сергеев", "Кузьмин", "Фролов",
+	"Александров", "Дмитриев", "Королёв", "Гусев", "Киселёв", "Ильин", "Максимов",
+	"Поляков", "Сорокин", "Виноградов", "Ковалёв", "Белов", "Медведев", "Антонов",
+	"Тарасов", "Жуков", "Баранов", "Филиппов", "Комаров", "Давыдов", "Беляев",
+	"Герасимов", "Богданов", "Осипов", "Сидоров", "Матвеев", "Титов", "Марков",
+	"Миронов", "Крылов", "Куликов", "Карпов", "Власов", "Мельников", "Денисов",
+	"Гаврилов", "Тихонов", "Казаков", "Афанасьев", "Данилов", "Савельев", "Тимофеев",
+	"Фомин", "Чернов", "Абрамов", "Мартынов", "Ефимов", "Федотов", "Щербаков",
+	"Назаров", "Калинин", "Исаев", "Чернышёв", "Быков", "Маслов", "Родионов",
+	"Коновалов", "Лазарев", "Воронин", "Климов", "Филатов", "Пономарёв", "Голубев",
+	"Кудрявцев", "Прохоров", "Наумов", "Потапов", "Журавлёв", "Овчинников", "Трофимов",
+	"Леонов", "Соболев", "Ермаков", "Колесников", "Гончаров", "Емельянов", "Никифоров",
+	"Грачёв", "Котов", "Гришин", "Ефремов", "Архипов", "Громов", "Кириллов",
+	"Малышев", "Панов", "Моисеев", "Румянцев", "Акимов", "Кондратьев", "Бирюков",
+	"Горбунов", "Анисимов", "Ерёмин", "Тихомиров", "Галкин", "Лукьянов", "Михеев",
+	"Скворцов", "Юдин", "Белоусов", "Нестеров", "Симонов", "Прокофьев", "Харитонов",
+	"Князев", "Цветков", "Левин", "Митрофанов", "Воронов", "Аксёнов", "Софронов",
+	"Мальцев", "Логинов", "Горшков", "Савин", "Краснов", "Майоров", "Демидов",
+	"Елисеев", "Рыбаков", "Сафонов", "Плотников", "Дёмин", "Хохлов", "Жданов",
+	"Островский", "Ковальский", "Вишневский", "Покровский", "Чайковский",
+}
+
+// catalog contains popular in Russia marks with their models, it is a slice so the data does not
+// depend on the order of a map
+var catalog = []struct {
+	mark   string
+	models []string
+}{
+	{"Lada", []string{"Vesta", "Granta", "Niva Legend", "Niva Travel", "Largus", "XRAY"}},
+	{"Kia", []string{"Rio", "Sportage", "Ceed", "Seltos", "K5"}},
+	{"Hyundai", []string{"Solaris", "Creta", "Tucson", "Elantra", "Santa Fe"}},
+	{"Toyota", []string{"Camry", "Corolla", "RAV4", "Land Cruiser Prado", "Land Cruiser"}},
+	{"Volkswagen", []string{"Polo", "Tiguan", "Passat", "Jetta", "Touareg"}},
+	{"Skoda", []string{"Octavia", "Rapid", "Kodiaq", "Karoq", "Superb"}},
+	{"Renault", []string{"Logan", "Duster", "Sandero", "Arkana", "Kaptur"}},
+	{"Nissan", []string{"Qashqai", "X-Trail", "Almera", "Terrano"}},
+	{"Mitsubishi", []string{"Outlander", "Pajero Sport", "ASX", "Lancer"}},
+	{"Haval", []string{"Jolion", "F7", "H6", "Dargo"}},
+	{"Chery", []string{"Tiggo 4 Pro", "Tiggo 7 Pro", "Tiggo 8 Pro"}},
+	{"Geely", []string{"Coolray", "Atlas Pro", "Monjaro", "Tugella"}},
+	{"BMW", []string{"3 Series", "5 Series", "X3", "X5"}},
+	{"Mercedes-Benz", []string{"C-Class", "E-Class", "GLC", "GLE"}},
+	{"Audi", []string{"A4", "A6", "Q5", "Q7"}},
+	{"UAZ", []string{"Patriot", "Hunter", "Pickup"}},
+	{"GAZ", []string{"Gazelle Next", "Sobol"}},
+}
+
+const (
+	// years are fixed, so the data of a regNum does not change in the next year
+	minYear = 1995
+	maxYear = 2024
+	// noPatronymicPercent of owners are foreign citizens without patronymic
+	noPatronymicPercent = 5
+)
+
+// generator makes the same data for the same regNum and seed
+type generator struct {
+	seed int64
+	// mu guards the random source of randomdata which is global
+	mu sync.Mutex
+}
+
+func (g *generator) car(regNum string) carInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	randomdata.CustomRand(rand.New(rand.NewSource(g.hash(regNum, "car"))))
+
+	mark := catalog[randomdata.Number(len(catalog))]
+	car := carInfo{
+		RegNum: regNum,
+		Mark:   mark.mark,
+		Model:  randomdata.StringSample(mark.models...),
+		Year:   randomdata.Number(minYear, maxYear+1),
+	}
+
+	father := maleNames[randomdata.Number(len(maleNames))]
+	hasPatronymic := randomdata.Number(100) >= noPatronymicPercent
+	car.Owner.Surname = randomdata.StringSample(surnames...)
+	if randomdata.Number(2) == randomdata.Male {
+		car.Owner.Name = maleNames[randomdata.Number(len(maleNames))].name
+		if hasPatronymic {
+			car.Owner.Patronymic = father.stem + "ич"
+		}
+	} else {
+		car.Owner.Name = randomdata.StringSample(femaleNames...)
+		car.Owner.Surname = feminineSurname(car.Owner.Surname)
+		if hasPatronymic {
+			car.Owner.Patronymic = father.stem + "на"
+		}
+	}
+	return car
+}
+
+// known reports whether the regNum exists in the registry, rate of unknown regNums is notFoundRate
+func (g *generator) known(regNum string, notFoundRate float64) bool {
+	return float64(uint64(g.hash(regNum, "known"))>>11)/(1<<53) >= notFoundRate
+}
+
+// hash mixes the seed with the regNum, purpose makes independent values for the same regNum
+func (g *generator) hash(regNum string, purpose string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(purpose + ":" + regNum))
+	return int64(h.Sum64()) ^ g.seed
+}
+
+func feminineSurname(surname string) string {
+	if stem, ok := strings.CutSuffix(surname, "ский"); ok {
+		return stem + "ская"
+	}
+	return surname + "а"
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"cars-service/pkg/logger"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// chaos makes the outer API slow and unreliable like real ones
+type chaos struct {
+	// every response is delayed by latency plus random time up to jitter
+	latency time.Duration
+	jitter  time.Duration
+	// errorRate is the probability of 500 response to any request
+	errorRate float64
+	// notFoundRate is the share of regNums unknown to the API, they always get 404
+	notFoundRate float64
+}
+
+func (c chaos) delay() time.Duration {
+	if c.jitter <= 0 {
+		return c.latency
+	}
+	return c.latency + time.Duration(rand.Int63n(int64(c.jitter)))
+}
+
+// handleInfo implements GET /info?regNum= of the outer API
+func handleInfo(g *generator, c chaos, logs logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+			return
+		}
+		regNum := r.URL.Query().Get("regNum")
+
+		delay := c.delay()
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		status := http.StatusOK
+		var resp any
+		switch {
+		case regNum == "":
+			status, resp = http.StatusBadRequest, errorResponse{Error: "regNum is required"}
+		case rand.Float64() < c.errorRate:
+			status, resp = http.StatusInternalServerError, errorResponse{Error: "internal error"}
+		case !g.known(regNum, c.notFoundRate):
+			status, resp = http.StatusNotFound, errorResponse{Error: "car not found"}
+		default:
+			resp = g.car(regNum)
+		}
+		writeJSON(w, status, resp)
+
+		logs.Info(logger.Fields{
+			"regNum": regNum,
+			"status": status,
+			"delay":  delay.String(),
+		}, "")
+	}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
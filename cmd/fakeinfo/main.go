@@ -0,0 +1,80 @@
+package main
+
+import (
+	"cars-service/pkg/logger"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const usage = `fakeinfo is a fake outer API returning generated data of cars for local development
+
+Usage:
+	fakeinfo [flags]
+
+It serves GET /info?regNum= like the outer API. Data of a regNum is the same for the same
+seed, including regNums unknown to the API. Errors and latency are random for every request.
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("fakeinfo", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	addr := flags.String("addr", "localhost:8081", "address of the server")
+	seed := flags.Int64("seed", 0, "seed of generated data, other seeds give other data of the same regNums")
+	var c chaos
+	flags.DurationVar(&c.latency, "latency", 0, "minimum delay of responses")
+	flags.DurationVar(&c.jitter, "jitter", 0, "maximum random delay added to latency")
+	flags.Float64Var(&c.errorRate, "error-rate", 0, "probability of 500 response from 0 to 1")
+	flags.Float64Var(&c.notFoundRate, "not-found-rate", 0, "share of unknown regNums getting 404 from 0 to 1")
+	_ = flags.Parse(os.Args[1:])
+
+	logs := logger.New()
+	if err := validate(c); err != nil {
+		logs.Fatal(nil, err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/info", handleInfo(&generator{seed: *seed}, c, logs))
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: mux,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logs.Fatal(nil, err.Error())
+		}
+	}()
+	logs.Info(logger.Fields{"addr": *addr}, "fake outer API started")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(shutdownCtx)
+}
+
+func validate(c chaos) error {
+	switch {
+	case c.latency < 0 || c.jitter < 0:
+		return errors.New("latency and jitter must not be negative")
+	case c.errorRate < 0 || c.errorRate > 1:
+		return errors.New("error-rate must be between 0 and 1")
+	case c.notFoundRate < 0 || c.notFoundRate > 1:
+		return errors.New("not-found-rate must be between 0 and 1")
+	}
+	return nil
+}
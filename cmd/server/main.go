@@ -3,15 +3,21 @@ package main
 import (
 	"cars-service/internal/adapters/api"
 	"cars-service/internal/app"
+	"cars-service/internal/config"
+	"cars-service/internal/outbox"
+	"cars-service/internal/ports/grpcserver"
 	"cars-service/internal/ports/httpserver"
 	"cars-service/internal/repo"
+	"cars-service/internal/webhooks"
+	"cars-service/migrations"
 	"cars-service/pkg/logger"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -19,69 +25,194 @@ import (
 	"time"
 )
 
-func connectToPostgres(ctx context.Context) (*pgxpool.Pool, error) {
-	coreRepoUrl := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		os.Getenv("POSTGRES_DB_USERNAME"),
-		os.Getenv("POSTGRES_DB_PASSWORD"),
-		os.Getenv("POSTGRES_DB_HOST"),
-		os.Getenv("POSTGRES_DB_PORT"),
-		os.Getenv("POSTGRES_DB_NAME"),
-		os.Getenv("POSTGRES_DB_SSLMODE"))
-
-	// 30 attempts to connect to postgres starting in docker container
-	for i := 0; i < 30; i++ {
-		conn, err := pgxpool.New(ctx, coreRepoUrl)
-		if err != nil {
-			time.Sleep(time.Second)
-		} else {
-			return conn, nil
-		}
+// newOutboxSink creates the sink of change events, it returns nil if no sink is configured
+func newOutboxSink(cfg config.Outbox) (outbox.Sink, error) {
+	switch cfg.Sink {
+	case "http":
+		return outbox.NewHTTPSink(cfg.SinkURL), nil
+	case "file":
+		return outbox.NewFileSink(cfg.SinkFile)
+	default:
+		return nil, nil
 	}
+}
 
-	return nil, errors.New("unable to connect to postgres passwords repo")
+// stopGRPC waits for active RPCs to finish and closes the rest when ctx is done
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+		<-stopped
+	}
 }
 
-const (
-	dockerConfigFile = "config/docker/.env"
-	localConfigFile  = "config/local/.env"
-)
+// migrateSchema applies new migrations if apply is set, otherwise it fails if any of them is not
+// applied, so the server does not run against an outdated schema
+func migrateSchema(ctx context.Context, pool *pgxpool.Pool, apply bool, logs logger.Logger) error {
+	if apply {
+		applied, err := repo.Migrate(ctx, pool, migrations.FS, 0)
+		for _, m := range applied {
+			logs.Info(logger.Fields{"version": m.Version}, "migration applied: "+m.Name)
+		}
+		return err
+	}
+	pending, err := repo.PendingMigrations(ctx, pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+	if len(pending) != 0 {
+		return fmt.Errorf("database schema is outdated, %d migrations starting from %s are not applied: "+
+			"run carsctl migrate or set postgres.migrate", len(pending), pending[0].Name)
+	}
+	return nil
+}
+
+const outboxInterval = time.Second
 
 //	@title			cars-service API
 //	@version		1.0
-//	@description	Swagger-документация к API каталога автомобилей
+//	@description	Swagger-документация к API каталога автомобилей. Запросы клиента, определяемого по заголовку X-Api-Key или по IP, ограничены, ответы содержат заголовки RateLimit-*, при превышении лимита возвращается 429 с заголовком Retry-After. Повтор изменяющего запроса с тем же заголовком Idempotency-Key получает сохранённый ответ первого запроса с заголовком Idempotent-Replayed, повтор с другим телом отклоняется с 422. Запрос, не обработанный за отведённое время, завершается ответом 504 с ошибкой "request deadline exceeded"
 //	@host			localhost:8080
 //	@BasePath		/api/v1
 
 func main() {
 	logs := logger.New()
 
-	isDocker := flag.Bool("docker", false, "flag if this project is running in docker container")
-	flag.Parse()
-	var configPath string
-	if *isDocker {
-		configPath = dockerConfigFile
-	} else {
-		configPath = localConfigFile
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	printConfig := flags.Bool("print-config", false, "print configuration with secrets redacted and exit")
+	cfg, err := config.Load(flags, os.Args[1:], config.Default())
+	if err != nil {
+		logs.Fatal(nil, err.Error())
+	}
+	// invalid configuration is printed too, so the exit code shows whether it is valid
+	if *printConfig {
+		out, err := cfg.Redacted()
+		if err != nil {
+			logs.Fatal(nil, err.Error())
+		}
+		_, _ = os.Stdout.Write(out)
+	}
+	if err = cfg.Validate(); err != nil {
+		logs.Fatal(nil, err.Error())
+	}
+	if *printConfig {
+		return
+	}
+	// the level is already validated
+	logs, _ = logger.NewWithLevel(cfg.Log.Level)
+
+	// waiting for postgres is interrupted by signals too
+	startupCtx, stopStartup := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	pool, err := repo.Connect(startupCtx, cfg.Postgres, logs)
+	stopStartup()
+	if err != nil {
+		logs.Fatal(nil, err.Error())
 	}
+	defer pool.Close()
 
-	if err := godotenv.Load(configPath); err != nil {
-		logs.Fatal(nil, "unable to load config files")
+	if err = migrateSchema(context.Background(), pool, cfg.Postgres.Migrate, logs); err != nil {
+		logs.Fatal(nil, err.Error())
 	}
 
-	pool, err := connectToPostgres(context.Background())
+	replicaPools, err := repo.ConnectReplicas(context.Background(), cfg.Postgres)
 	if err != nil {
 		logs.Fatal(nil, err.Error())
 	}
-	defer pool.Close()
+	for _, replicaPool := range replicaPools {
+		defer replicaPool.Close()
+	}
+
+	apiCli, err := api.NewFromConfig(cfg.Api)
+	if err != nil {
+		logs.Fatal(nil, err.Error())
+	}
+
+	carsRepo := repo.New(pool)
+	var replicatedRepo repo.ReplicatedRepo
+	if len(replicaPools) != 0 {
+		replicatedRepo = repo.NewReplicatedRepo(carsRepo, replicaPools, logs, cfg.Postgres.ReplicaCheckInterval)
+		carsRepo = replicatedRepo
+	}
+	if cfg.Cache.Size > 0 {
+		carsRepo = repo.NewCachedRepo(carsRepo, cfg.Cache.Size, cfg.Cache.TTL)
+	}
+	jobs := repo.NewJobRepo(pool)
+	webhookRepo := repo.NewWebhookRepo(pool)
+	refreshes := repo.NewRefreshRepo(pool)
+	a := app.New(carsRepo, jobs, webhookRepo, refreshes, apiCli, logs)
 
-	apiCli := api.New(os.Getenv("API_ADDR"))
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	replicasDone := make(chan struct{})
+	go func() {
+		if replicatedRepo != nil {
+			replicatedRepo.Run(backgroundCtx)
+		}
+		close(replicasDone)
+	}()
+	runnerDone := make(chan struct{})
+	go func() {
+		app.NewImportRunner(a, jobs, logs, cfg.Import.Workers).Run(backgroundCtx)
+		close(runnerDone)
+	}()
+
+	refreshDone := make(chan struct{})
+	go func() {
+		app.NewRefreshRunner(a, refreshes, logs, cfg.Refresh).Run(backgroundCtx)
+		close(refreshDone)
+	}()
+
+	sink, err := newOutboxSink(cfg.Outbox)
+	if err != nil {
+		logs.Fatal(nil, err.Error())
+	}
+	relayDone := make(chan struct{})
+	go func() {
+		// events are kept in the outbox until a sink is configured
+		if sink != nil {
+			outbox.NewRelay(repo.NewOutboxRepo(pool), sink, logs, outboxInterval).Run(backgroundCtx)
+		}
+		close(relayDone)
+	}()
+	dispatcherDone := make(chan struct{})
+	go func() {
+		webhooks.NewDispatcher(webhookRepo, logs).Run(backgroundCtx)
+		close(dispatcherDone)
+	}()
 
-	a := app.New(repo.New(pool), apiCli, logs)
+	rateLimits := repo.NewMemoryRateLimitRepo()
+	if cfg.Server.RateLimit.Store == "postgres" {
+		rateLimits = repo.NewRateLimitRepo(pool)
+	}
+	srv := httpserver.New(cfg.Server, a, rateLimits, repo.NewIdempotencyRepo(pool), logs)
+	if srv.TLSConfig, err = httpserver.NewTLSConfig(cfg.Server.TLS, logs); err != nil {
+		logs.Fatal(nil, err.Error())
+	}
 
-	srv := httpserver.New(os.Getenv("SERVER_ADDR"), a, logs)
+	go func() {
+		// the certificate is taken from TLSConfig
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logs.Fatal(nil, err.Error())
+		}
+	}()
+	grpcListener, err := net.Listen("tcp", cfg.Server.GRPCAddr)
+	if err != nil {
+		logs.Fatal(nil, err.Error())
+	}
+	grpcSrv := grpcserver.New(a, logs, cfg.Server.MaxBatchSize)
 
 	go func() {
-		if err = srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err = grpcSrv.Serve(grpcListener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
 			logs.Fatal(nil, err.Error())
 		}
 	}()
@@ -93,8 +224,17 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGINT)
 
 	<-quit
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	_ = srv.Shutdown(shutdownCtx)
+	stopGRPC(shutdownCtx, grpcSrv)
+
+	// unfinished import jobs are resumed and undelivered events are sent after restart
+	stopBackground()
+	<-runnerDone
+	<-refreshDone
+	<-relayDone
+	<-dispatcherDone
+	<-replicasDone
 }
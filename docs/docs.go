@@ -17,9 +17,10 @@ const docTemplate = `{
     "paths": {
         "/cars": {
             "get": {
-                "description": "Возвращает список автомобилей, поддерживаются фильтрация и пагинация",
+                "description": "Возвращает список автомобилей, поддерживаются фильтрация и пагинация. С заголовком Accept: application/x-ndjson автомобили передаются потоком по одному JSON-объекту на строку, а limit и offset необязательны. JSON-ответ содержит ETag, который меняется при изменении любого автомобиля страницы, с заголовком If-None-Match неизменённая страница не передаётся повторно",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/x-ndjson"
                 ],
                 "summary": "Получение списка автомобилей",
                 "parameters": [
@@ -72,6 +73,12 @@ const docTemplate = `{
                         "description": "Отчество владельца",
                         "name": "ownerPatronymic",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag ранее полученного ответа",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -79,8 +86,17 @@ const docTemplate = `{
                         "description": "Успешное получение информации",
                         "schema": {
                             "$ref": "#/definitions/httpserver.carResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия страницы"
+                            }
                         }
                     },
+                    "304": {
+                        "description": "Страница не изменилась"
+                    },
                     "400": {
                         "description": "Неверный формат входных данных",
                         "schema": {
@@ -92,11 +108,17 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httpserver.carResponse"
                         }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.carResponse"
+                        }
                     }
                 }
             },
             "post": {
-                "description": "Получает на вход номера автомобилей, выполняет запрос во внешний API для получения недостающих данных и добавляет информацию о новых автомобилях",
+                "description": "Получает на вход номера автомобилей, выполняет запрос во внешний API для получения недостающих данных и добавляет информацию о новых автомобилях. Повторы номеров в запросе обрабатываются один раз и возвращаются в поле duplicates, при неверном формате любого из номеров автомобили не добавляются, а неверные номера перечисляются в поле details",
                 "consumes": [
                     "application/json"
                 ],
@@ -119,17 +141,115 @@ const docTemplate = `{
                     "200": {
                         "description": "Успешное добавление информации",
                         "schema": {
-                            "$ref": "#/definitions/httpserver.carsResponse"
+                            "$ref": "#/definitions/httpserver.addCarsResponse"
                         }
                     },
                     "400": {
-                        "description": "Неверный формат входных данных",
+                        "description": "Неверный формат входных данных или номеров, слишком много номеров",
                         "schema": {
-                            "$ref": "#/definitions/httpserver.carsResponse"
+                            "$ref": "#/definitions/httpserver.addCarsResponse"
                         }
                     },
                     "409": {
                         "description": "Попытка добавления существующего номера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.addCarsResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Слишком большое тело запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.addCarsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.addCarsResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.addCarsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/cars/export": {
+            "get": {
+                "description": "Выгружает отфильтрованный каталог в формате CSV или XLSX, фильтры такие же, как у получения списка автомобилей, limit и offset необязательны",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "summary": "Выгрузка каталога автомобилей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Формат файла: csv (по умолчанию) или xlsx",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "offset",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Регистрационный номер",
+                        "name": "regNum",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Марка",
+                        "name": "mark",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Модель",
+                        "name": "model",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Имя владельца",
+                        "name": "ownerName",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фамилия владельца",
+                        "name": "ownerSurname",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Отчество владельца",
+                        "name": "ownerPatronymic",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Файл с каталогом",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
                         "schema": {
                             "$ref": "#/definitions/httpserver.carsResponse"
                         }
@@ -139,13 +259,66 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httpserver.carsResponse"
                         }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.carsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/cars/import": {
+            "post": {
+                "description": "Принимает файл CSV или XLSX с заголовком (колонки как при выгрузке, обязательна только regNum). Строки со всеми заполненными полями добавляются без запроса во внешний API, для остальных недостающие данные запрашиваются во внешнем API. Возвращает результат обработки каждой строки",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Загрузка автомобилей из файла",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Файл CSV или XLSX",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Файл обработан",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importRowsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importRowsResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Слишком большое тело запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importRowsResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importRowsResponse"
+                        }
                     }
                 }
             }
         },
         "/cars/{id}": {
             "get": {
-                "description": "Возвращает информацию об автомобиле и его владельце, если автомобиль с указанным id существует",
+                "description": "Возвращает информацию об автомобиле и его владельце, если автомобиль с указанным id существует. Ответ содержит ETag, который меняется при каждом изменении автомобиля, с заголовком If-None-Match неизменённый автомобиль не передаётся повторно",
                 "produces": [
                     "application/json"
                 ],
@@ -157,6 +330,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag ранее полученного ответа",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -164,8 +343,17 @@ const docTemplate = `{
                         "description": "Успешное получение информации",
                         "schema": {
                             "$ref": "#/definitions/httpserver.carResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия автомобиля"
+                            }
                         }
                     },
+                    "304": {
+                        "description": "Автомобиль не изменился"
+                    },
                     "400": {
                         "description": "Неверный формат входных данных",
                         "schema": {
@@ -183,6 +371,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httpserver.carResponse"
                         }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.carResponse"
+                        }
                     }
                 }
             },
@@ -221,7 +415,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Неверный формат входных данных",
+                        "description": "Неверный формат входных данных или ошибка валидации полей",
                         "schema": {
                             "$ref": "#/definitions/httpserver.carResponse"
                         }
@@ -238,11 +432,23 @@ const docTemplate = `{
                             "$ref": "#/definitions/httpserver.carResponse"
                         }
                     },
+                    "413": {
+                        "description": "Слишком большое тело запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.carResponse"
+                        }
+                    },
                     "500": {
                         "description": "Ошибка на стороне сервера",
                         "schema": {
                             "$ref": "#/definitions/httpserver.carResponse"
                         }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.carResponse"
+                        }
                     }
                 }
             },
@@ -285,64 +491,1078 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httpserver.carResponse"
                         }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.carResponse"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "httpserver.addCarsRequest": {
-            "type": "object",
-            "properties": {
-                "regNums": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
+        },
+        "/cars/{id}/refresh": {
+            "post": {
+                "description": "Повторно запрашивает данные автомобиля у поставщиков и возвращает отличия от сохранённых данных. Изменения применяются сразу, если apply=true, иначе сохраняются для проверки. Если отличий нет, возвращается статус unchanged",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Обновление данных автомобиля из внешнего API",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id автомобиля",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Применить изменения сразу",
+                        "name": "apply",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Данные обновлены",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Автомобиль с указанным id не найден",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Автомобиль изменён другим запросом во время обновления",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Ошибка внешнего API",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
                     }
                 }
             }
         },
-        "httpserver.carData": {
-            "type": "object",
-            "properties": {
-                "id": {
-                    "type": "integer"
-                },
-                "mark": {
-                    "type": "string"
-                },
-                "model": {
-                    "type": "string"
-                },
-                "owner": {
-                    "$ref": "#/definitions/httpserver.ownerData"
-                },
-                "regNum": {
-                    "type": "string"
-                },
-                "year": {
-                    "type": "integer"
+        "/graphql": {
+            "post": {
+                "description": "Выполняет GraphQL-запрос к каталогу: car(id), cars(filter, sort, page), owner(id) с автомобилями владельца и историей владения, а также мутации addCars, updateCar и deleteCar. Ошибки возвращаются в поле errors с кодом в extensions.code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "GraphQL API",
+                "parameters": [
+                    {
+                        "description": "GraphQL-запрос",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.graphqlRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат запроса",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.carResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Слишком большое тело запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.carResponse"
+                        }
+                    }
                 }
             }
         },
-        "httpserver.carResponse": {
+        "/imports": {
+            "post": {
+                "description": "Принимает номера автомобилей в формате JSON или CSV (первая колонка, заголовок regNum необязателен) и сразу возвращает задачу импорта, которая выполняется в фоне",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Создание задачи массового импорта",
+                "parameters": [
+                    {
+                        "description": "Регистрационные номера",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.addCarsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Задача импорта создана",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Слишком большое тело запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/imports/{id}": {
+            "get": {
+                "description": "Возвращает прогресс задачи импорта и результат обработки каждого номера",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получение состояния задачи импорта",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id задачи импорта",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Успешное получение информации",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача импорта с указанным id не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/imports/{id}/cancel": {
+            "post": {
+                "description": "Останавливает обработку задачи импорта, уже добавленные автомобили остаются в каталоге",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Отмена задачи импорта",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id задачи импорта",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Задача импорта отменена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача импорта с указанным id не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Задача импорта уже завершена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.importJobResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/refreshes": {
+            "get": {
+                "description": "Возвращает последние обновления с указанным статусом, по умолчанию ожидающие проверки",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получение списка обновлений данных автомобилей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Статус: pending (по умолчанию), applied, rejected или superseded",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Успешное получение информации",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshesResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshesResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshesResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/refreshes/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получение обновления данных автомобиля по id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id обновления",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Успешное получение информации",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Обновление с указанным id не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/refreshes/{id}/apply": {
+            "post": {
+                "description": "Применяет изменения ожидающего проверки обновления к автомобилю",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Применение обновления данных автомобиля",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id обновления",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменения применены",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Обновление или автомобиль не найдены",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Обновление уже применено или отклонено либо автомобиль изменён другим запросом",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/refreshes/{id}/reject": {
+            "post": {
+                "description": "Отклоняет изменения ожидающего проверки обновления, данные автомобиля не изменяются",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Отклонение обновления данных автомобиля",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id обновления",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменения отклонены",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Обновление с указанным id не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Обновление уже применено или отклонено",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.refreshResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получение списка подписок на события",
+                "responses": {
+                    "200": {
+                        "description": "Успешное получение информации",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhooksResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhooksResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhooksResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Регистрирует URL, на который будут отправляться события car.created, car.updated, car.deleted и owner.changed. Тело запроса подписывается HMAC-SHA256 с секретом подписки (заголовок X-Webhook-Signature), секрет генерируется, если не передан, и возвращается только при создании",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Создание подписки на события",
+                "parameters": [
+                    {
+                        "description": "Параметры подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Подписка создана",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных или ошибка валидации полей",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Слишком большое тело запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получение подписки на события по id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Успешное получение информации",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка с указанным id не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Изменяет URL, события и фильтры подписки, секрет не изменяется. Повторное включение отключённой подписки сбрасывает счётчик ошибок",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Изменение подписки на события",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новые параметры подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка изменена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных или ошибка валидации полей",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка с указанным id не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Слишком большое тело запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет подписку вместе с журналом её доставок",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Удаление подписки на события",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка удалена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка с указанным id не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.webhookResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Возвращает последние доставки событий подписки с количеством попыток, кодом ответа и ошибкой последней попытки",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Журнал доставок подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Успешное получение информации",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.deliveriesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат входных данных",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.deliveriesResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка с указанным id не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.deliveriesResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на стороне сервера",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.deliveriesResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Превышено время обработки запроса",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.deliveriesResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "httpserver.addCarsRequest": {
+            "type": "object",
+            "properties": {
+                "regNums": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "httpserver.addCarsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.carData"
+                    }
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.fieldErrorData"
+                    }
+                },
+                "duplicates": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.carData": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "mark": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "owner": {
+                    "$ref": "#/definitions/httpserver.ownerData"
+                },
+                "regNum": {
+                    "type": "string"
+                },
+                "sources": {
+                    "description": "Sources maps fields to providers which supplied them, it is ignored in requests",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "year": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpserver.carResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/httpserver.carData"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.fieldErrorData"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.carsResponse": {
             "type": "object",
             "properties": {
                 "data": {
-                    "$ref": "#/definitions/httpserver.carData"
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.carData"
+                    }
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.fieldErrorData"
+                    }
                 },
                 "error": {
                     "type": "string"
                 }
             }
         },
-        "httpserver.carsResponse": {
+        "httpserver.deliveriesResponse": {
             "type": "object",
             "properties": {
                 "data": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/httpserver.carData"
+                        "$ref": "#/definitions/httpserver.deliveryData"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.deliveryData": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "carId": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "nextAttemptAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "statusCode": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.fieldChangeData": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "new": {
+                    "type": "string"
+                },
+                "old": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.fieldErrorData": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.graphqlRequest": {
+            "type": "object",
+            "required": [
+                "query"
+            ],
+            "properties": {
+                "operationName": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "httpserver.importItemData": {
+            "type": "object",
+            "properties": {
+                "carId": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "regNum": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.importJobData": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.importItemData"
+                    }
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "succeeded": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.importJobResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/httpserver.importJobData"
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.importRowData": {
+            "type": "object",
+            "properties": {
+                "carId": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "regNum": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.importRowsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.importRowData"
                     }
                 },
                 "error": {
@@ -363,6 +1583,159 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "httpserver.refreshData": {
+            "type": "object",
+            "properties": {
+                "car": {
+                    "$ref": "#/definitions/httpserver.carData"
+                },
+                "carId": {
+                    "type": "integer"
+                },
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.fieldChangeData"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "resolvedAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.refreshResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/httpserver.refreshData"
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.refreshesResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.refreshData"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.webhookData": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "failures": {
+                    "type": "integer"
+                },
+                "filter": {
+                    "$ref": "#/definitions/httpserver.webhookFilterData"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.webhookFilterData": {
+            "type": "object",
+            "properties": {
+                "mark": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.webhookRequest": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Active is used only on update, webhook is enabled if it is not set",
+                    "type": "boolean"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "filter": {
+                    "$ref": "#/definitions/httpserver.webhookFilterData"
+                },
+                "secret": {
+                    "description": "Secret is used only on creation, it is generated if empty",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.webhookResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/httpserver.webhookData"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.fieldErrorData"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpserver.webhooksResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.webhookData"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -374,7 +1747,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/api/v1",
 	Schemes:          []string{},
 	Title:            "cars-service API",
-	Description:      "Swagger-документация к API каталога автомобилей",
+	Description:      "Swagger-документация к API каталога автомобилей. Запросы клиента, определяемого по заголовку X-Api-Key или по IP, ограничены, ответы содержат заголовки RateLimit-*, при превышении лимита возвращается 429 с заголовком Retry-After. Повтор изменяющего запроса с тем же заголовком Idempotency-Key получает сохранённый ответ первого запроса с заголовком Idempotent-Replayed, повтор с другим телом отклоняется с 422. Запрос, не обработанный за отведённое время, завершается ответом 504 с ошибкой \"request deadline exceeded\"",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 }
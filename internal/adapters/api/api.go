@@ -3,7 +3,6 @@ package api
 import (
 	"cars-service/internal/model"
 	"context"
-	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
@@ -11,63 +10,52 @@ import (
 )
 
 type apiImpl struct {
-	url string
+	url        string
+	queryParam string
+	headers    map[string]string
+	mapping    mapping
+	auth       Authenticator
 	http.Client
 }
 
 func (a *apiImpl) GetInfo(ctx context.Context, regNum string) (model.Car, error) {
 	params := url.Values{}
-	params.Add("regNum", regNum)
+	params.Add(a.queryParam, regNum)
 	reqUrl := a.url + "?" + params.Encode()
 
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	for name, value := range a.headers {
+		req.Header.Set(name, value)
+	}
+	if err := a.auth.Authenticate(ctx, req); err != nil {
+		return model.Car{}, errors.Join(model.ErrApiError, err)
+	}
 	resp, err := a.Client.Do(req)
 	if err != nil {
-		return model.Car{}, errors.Join(model.ErrApiError, err)
+		return model.Car{}, errors.Join(model.ErrApiError, model.ErrApiUnavailable, err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return model.Car{}, errors.Join(model.ErrApiError, model.ErrApiUnavailable)
+	case resp.StatusCode == http.StatusUnauthorized:
+		// the token may be revoked before its expiry, so the next request gets a new one
+		a.auth.Reset()
+		return model.Car{}, model.ErrApiError
+	case resp.StatusCode != http.StatusOK:
 		return model.Car{}, model.ErrApiError
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return model.Car{}, errors.Join(model.ErrApiError, err)
+		return model.Car{}, errors.Join(model.ErrApiError, model.ErrApiUnavailable, err)
 	}
 
-	var data carInfo
-	if err = json.Unmarshal(body, &data); err != nil {
+	car, err := a.mapping.decode(body)
+	if err != nil {
 		return model.Car{}, errors.Join(model.ErrApiError, err)
 	}
-
-	return model.Car{
-		RegNum: data.RegNum,
-		Mark:   data.Mark,
-		Model:  data.Model,
-		Year:   data.Year,
-		Owner: model.Owner{
-			Name:       data.Owner.Name,
-			Surname:    data.Owner.Surname,
-			Patronymic: data.Owner.Patronymic,
-		},
-	}, nil
-
-}
-
-// carInfo is a struct for parsing data from response body
-type carInfo struct {
-	RegNum string    `json:"regNum"`
-	Mark   string    `json:"mark"`
-	Model  string    `json:"model"`
-	Year   int       `json:"year"`
-	Owner  ownerInfo `json:"owner"`
-}
-
-// ownerInfo is a struct for parsing data from response body
-type ownerInfo struct {
-	Name       string `json:"name"`
-	Surname    string `json:"surname"`
-	Patronymic string `json:"patronymic"`
+	return car, nil
 }
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"cars-service/internal/config"
 	"cars-service/internal/model"
 	"context"
+	"fmt"
 	"net/http"
+	"time"
 )
 
 // Api is an interface fo outer API
@@ -12,10 +15,124 @@ type Api interface {
 	GetInfo(ctx context.Context, regNum string) (model.Car, error)
 }
 
-// New creates Api implementation
-func New(url string) Api {
+// New creates Api implementation of the original outer API, timeout limits every request to it
+func New(url string, timeout time.Duration) Api {
+	a, _ := NewProvider(config.Provider{Addr: url}, timeout)
+	return a
+}
+
+// NewProvider creates Api implementation which queries cfg.Addr with cfg.Headers authenticated
+// by cfg.Auth and reads the response according to cfg.Mapping, cfg.Timeout is ignored in favor
+// of timeout
+func NewProvider(cfg config.Provider, timeout time.Duration) (Api, error) {
+	m, err := newMapping(cfg.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("mapping of provider %q: %w", cfg.Name, err)
+	}
+	transport, err := newTransport(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("tls of provider %q: %w", cfg.Name, err)
+	}
+	queryParam := cfg.QueryParam
+	if queryParam == "" {
+		queryParam = "regNum"
+	}
 	return &apiImpl{
-		url:    url,
-		Client: http.Client{},
+		url:        cfg.Addr,
+		queryParam: queryParam,
+		headers:    cfg.Headers,
+		mapping:    m,
+		auth:       NewAuthenticator(cfg.Auth, timeout, transport),
+		Client:     http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// Authenticator adds credentials of the client to requests to the outer API
+type Authenticator interface {
+	// Authenticate sets headers of req, it may request credentials from other servers
+	Authenticate(ctx context.Context, req *http.Request) error
+	// Reset drops cached credentials rejected by the outer API, so they are requested again
+	Reset()
+}
+
+// NewAuthenticator creates Authenticator of cfg.Type, tokens of oauth2 are cached until
+// cfg.RefreshBefore their expiry and requested through transport, timeout limits requests for
+// them. Authenticator of mtls and of empty type adds nothing, the certificate is presented by
+// the transport of NewProvider. Nil transport is http.DefaultTransport
+func NewAuthenticator(cfg config.Auth, timeout time.Duration, transport http.RoundTripper) Authenticator {
+	switch cfg.Type {
+	case "apiKey":
+		header := cfg.APIKeyHeader
+		if header == "" {
+			header = defaultAPIKeyHeader
+		}
+		return &apiKeyAuthenticator{header: header, key: cfg.APIKey}
+	case "oauth2":
+		refreshBefore := cfg.RefreshBefore
+		if refreshBefore == 0 {
+			refreshBefore = defaultRefreshBefore
+		}
+		return &oauth2Authenticator{
+			tokenURL:      cfg.TokenURL,
+			clientID:      cfg.ClientID,
+			clientSecret:  cfg.ClientSecret,
+			scopes:        cfg.Scopes,
+			refreshBefore: refreshBefore,
+			client:        http.Client{Timeout: timeout, Transport: transport},
+			now:           time.Now,
+		}
+	default:
+		return noneAuthenticator{}
+	}
+}
+
+// Provider is the outer API with the name recorded as the source of the data it supplies
+type Provider struct {
+	Name string
+	Api  Api
+}
+
+// Mode is the way Registry queries its providers
+type Mode string
+
+const (
+	// ModePriority queries providers one by one until all fields of the car are found
+	ModePriority Mode = "priority"
+	// ModeParallel queries all providers at once
+	ModeParallel Mode = "parallel"
+)
+
+// NewRegistry creates Api implementation which merges data of the providers field by field,
+// fields of earlier providers win in both modes and sources of fields are set in model.Car.
+// model.ErrApiError is returned only if no provider supplied any field
+func NewRegistry(providers []Provider, mode Mode) Api {
+	return &registryImpl{
+		providers: providers,
+		mode:      mode,
+	}
+}
+
+// NewFromConfig creates Registry of providers of cfg or of the single provider of cfg.Addr
+// authenticated by cfg.Auth and named "default" if no providers are configured
+func NewFromConfig(cfg config.Api) (Api, error) {
+	if len(cfg.Providers) == 0 {
+		a, err := NewProvider(config.Provider{Name: "default", Addr: cfg.Addr, Auth: cfg.Auth}, cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		return NewRegistry([]Provider{{Name: "default", Api: a}}, Mode(cfg.Mode)), nil
+	}
+	providers := make([]Provider, len(cfg.Providers))
+	for i, p := range cfg.Providers {
+		timeout := p.Timeout
+		if timeout == 0 {
+			timeout = cfg.Timeout
+		}
+		a, err := NewProvider(p, timeout)
+		if err != nil {
+			return nil, err
+		}
+		providers[i] = Provider{Name: p.Name, Api: a}
 	}
+	return NewRegistry(providers, Mode(cfg.Mode)), nil
 }
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"cars-service/internal/config"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultAPIKeyHeader is the header of the API key if it is not configured
+	defaultAPIKeyHeader = "X-Api-Key"
+	// defaultRefreshBefore is the time before expiry of the token when it is requested again
+	defaultRefreshBefore = time.Minute
+)
+
+type noneAuthenticator struct{}
+
+func (noneAuthenticator) Authenticate(context.Context, *http.Request) error {
+	return nil
+}
+
+func (noneAuthenticator) Reset() {}
+
+type apiKeyAuthenticator struct {
+	header string
+	key    string
+}
+
+func (a *apiKeyAuthenticator) Authenticate(_ context.Context, req *http.Request) error {
+	req.Header.Set(a.header, a.key)
+	return nil
+}
+
+// Reset does nothing, the configured key can't be replaced
+func (a *apiKeyAuthenticator) Reset() {}
+
+type oauth2Authenticator struct {
+	tokenURL      string
+	clientID      string
+	clientSecret  string
+	scopes        []string
+	refreshBefore time.Duration
+	client        http.Client
+	now           func() time.Time
+
+	// mu is held while the token is requested, so concurrent requests wait for the same token
+	mu        sync.Mutex
+	token     string
+	tokenType string
+	// refreshAt is the time when the token is requested again, zero if it does not expire
+	refreshAt time.Time
+}
+
+func (a *oauth2Authenticator) Authenticate(ctx context.Context, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == "" || (!a.refreshAt.IsZero() && !a.now().Before(a.refreshAt)) {
+		if err := a.requestToken(ctx); err != nil {
+			return fmt.Errorf("unable to get oauth2 token: %w", err)
+		}
+	}
+	req.Header.Set("Authorization", a.tokenType+" "+a.token)
+	return nil
+}
+
+// Reset drops the cached token, e.g. revoked by the server before its expiry
+func (a *oauth2Authenticator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token = ""
+}
+
+// tokenResponse is a struct for parsing the response of the token endpoint
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Error       string `json:"error"`
+}
+
+// requestToken gets the token of the client credentials grant and caches it
+func (a *oauth2Authenticator) requestToken(ctx context.Context) error {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(a.scopes) != 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// credentials are encoded before basic auth as RFC 6749 requires
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+
+	obtained := a.now()
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	var data tokenResponse
+	// errors of the token endpoint are JSON too, but the status is enough if they are not
+	jsonErr := json.Unmarshal(body, &data)
+	if resp.StatusCode != http.StatusOK {
+		if data.Error != "" {
+			return fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, data.Error)
+		}
+		return fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+	if jsonErr != nil {
+		return jsonErr
+	}
+	if data.AccessToken == "" {
+		return errors.New("token endpoint returned empty access_token")
+	}
+
+	a.token = data.AccessToken
+	a.tokenType = data.TokenType
+	// the type is case-insensitive, but some servers accept only the canonical form
+	if a.tokenType == "" || strings.EqualFold(a.tokenType, "bearer") {
+		a.tokenType = "Bearer"
+	}
+	a.refreshAt = time.Time{}
+	if data.ExpiresIn > 0 {
+		// short-lived tokens are used for at least half of their lifetime
+		lifetime := time.Duration(data.ExpiresIn) * time.Second
+		a.refreshAt = obtained.Add(max(lifetime-a.refreshBefore, lifetime/2))
+	}
+	return nil
+}
+
+// newTransport returns the transport presenting the client certificate of mtls auth and verifying
+// the server with CAFile, it returns nil for http.DefaultTransport if neither is configured
+func newTransport(cfg config.Auth) (http.RoundTripper, error) {
+	if cfg.Type != "mtls" && cfg.CAFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Type == "mtls" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
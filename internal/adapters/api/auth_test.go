@@ -0,0 +1,177 @@
+package api
+
+import (
+	"cars-service/internal/config"
+	"cars-service/internal/model"
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTokenServer returns the token endpoint issuing tokens valid for expiresIn seconds, the
+// number of the issued token is the token itself
+func newTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var issued atomic.Int32
+	srv := httptest.NewServer(tokenHandler(&issued, expiresIn))
+	t.Cleanup(srv.Close)
+	return srv, &issued
+}
+
+// tokenHandler is the token endpoint of newTokenServer
+func tokenHandler(issued *atomic.Int32, expiresIn int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		n := issued.Add(1)
+		_, _ = fmt.Fprintf(w, `{"access_token":"token%d","token_type":"bearer","expires_in":%d}`, n, expiresIn)
+	}
+}
+
+func TestOAuth2Authenticator(t *testing.T) {
+	srv, issued := newTokenServer(t, 600)
+	auth := NewAuthenticator(config.Auth{
+		Type:          "oauth2",
+		TokenURL:      srv.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		RefreshBefore: time.Minute,
+	}, time.Second, nil).(*oauth2Authenticator)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	auth.now = func() time.Time { return now }
+
+	authorization := func() string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/info", nil)
+		if err := auth.Authenticate(context.Background(), req); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		return req.Header.Get("Authorization")
+	}
+
+	if got := authorization(); got != "Bearer token1" {
+		t.Errorf("Authorization = %q, want Bearer token1", got)
+	}
+	now = now.Add(8 * time.Minute)
+	if got := authorization(); got != "Bearer token1" {
+		t.Errorf("cached Authorization = %q, want Bearer token1", got)
+	}
+	// the token is requested again a minute before its expiry
+	now = now.Add(time.Minute)
+	if got := authorization(); got != "Bearer token2" {
+		t.Errorf("refreshed Authorization = %q, want Bearer token2", got)
+	}
+	if n := issued.Load(); n != 2 {
+		t.Errorf("issued %d tokens, want 2", n)
+	}
+}
+
+func TestOAuth2AuthenticatorShortLivedToken(t *testing.T) {
+	srv, issued := newTokenServer(t, 30)
+	auth := NewAuthenticator(config.Auth{
+		Type:         "oauth2",
+		TokenURL:     srv.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+	}, time.Second, nil).(*oauth2Authenticator)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	auth.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := auth.Authenticate(context.Background(), httptest.NewRequest(http.MethodGet, "/info", nil)); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	// tokens shorter than refreshBefore are used for half of their lifetime
+	if n := issued.Load(); n != 2 {
+		t.Errorf("issued %d tokens, want 2", n)
+	}
+}
+
+func TestOAuth2AuthenticatorError(t *testing.T) {
+	srv, _ := newTokenServer(t, 600)
+	auth := NewAuthenticator(config.Auth{
+		Type:         "oauth2",
+		TokenURL:     srv.URL,
+		ClientID:     "client",
+		ClientSecret: "wrong",
+	}, time.Second, nil)
+
+	err := auth.Authenticate(context.Background(), httptest.NewRequest(http.MethodGet, "/info", nil))
+	if want := "unable to get oauth2 token: token endpoint returned 401: invalid_client"; err == nil || err.Error() != want {
+		t.Errorf("Authenticate error = %v, want %s", err, want)
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/info", nil)
+	auth := NewAuthenticator(config.Auth{Type: "apiKey", APIKey: "key"}, time.Second, nil)
+	if err := auth.Authenticate(context.Background(), req); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got := req.Header.Get(defaultAPIKeyHeader); got != "key" {
+		t.Errorf("%s = %q, want key", defaultAPIKeyHeader, got)
+	}
+}
+
+func TestProviderOAuth2(t *testing.T) {
+	var issued atomic.Int32
+	tokens := httptest.NewTLSServer(tokenHandler(&issued, 600))
+	t.Cleanup(tokens.Close)
+	// the first token is revoked right after it is issued
+	info := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"regNum":"X123XX150","mark":"Lada","model":"Vesta","year":2020}`)
+	}))
+	t.Cleanup(info.Close)
+
+	// both servers present the same test certificate, so requests for tokens are verified too
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: info.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	a, err := NewProvider(config.Provider{
+		Addr: info.URL,
+		Auth: config.Auth{
+			Type:         "oauth2",
+			TokenURL:     tokens.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			CAFile:       caFile,
+		},
+	}, time.Second)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+
+	if _, err = a.GetInfo(context.Background(), "X123XX150"); !errors.Is(err, model.ErrApiError) {
+		t.Fatalf("GetInfo with revoked token error = %v, want %v", err, model.ErrApiError)
+	}
+	car, err := a.GetInfo(context.Background(), "X123XX150")
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if car.Mark != "Lada" {
+		t.Errorf("Mark = %q, want Lada", car.Mark)
+	}
+	if n := issued.Load(); n != 2 {
+		t.Errorf("issued %d tokens, want 2", n)
+	}
+}
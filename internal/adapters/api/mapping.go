@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"cars-service/internal/config"
+	"cars-service/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultMapping is the schema of the original outer API, it is used for fields missing in
+// the mapping of the provider
+var defaultMapping = config.Mapping{
+	RegNum:          "regNum",
+	Mark:            "mark",
+	Model:           "model",
+	Year:            "year",
+	OwnerName:       "owner.name",
+	OwnerSurname:    "owner.surname",
+	OwnerPatronymic: "owner.patronymic",
+}
+
+// pathStep is a key of an object or an index of an array
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// path is a compiled path of the field in the response like $.person.names[0]
+type path []pathStep
+
+// parsePath parses dot-separated keys each followed by optional indexes of arrays like
+// $.person.documents[0].number, the leading $. is optional
+func parsePath(s string) (path, error) {
+	var p path
+	for _, segment := range strings.Split(strings.TrimPrefix(s, "$."), ".") {
+		key, rest, hasIndex := strings.Cut(segment, "[")
+		if key == "" || strings.Contains(key, "]") {
+			return nil, fmt.Errorf("invalid key in path %q", s)
+		}
+		p = append(p, pathStep{key: key})
+		for hasIndex {
+			var index string
+			var ok bool
+			if index, rest, ok = strings.Cut(rest, "]"); !ok {
+				return nil, fmt.Errorf("unclosed index in path %q", s)
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid index in path %q", s)
+			}
+			p = append(p, pathStep{index: i, isIndex: true})
+			if rest == "" {
+				break
+			}
+			if rest, hasIndex = strings.CutPrefix(rest, "["); !hasIndex {
+				return nil, fmt.Errorf("invalid index in path %q", s)
+			}
+		}
+	}
+	return p, nil
+}
+
+// lookup returns the value at the path in the decoded JSON, it returns nil if the value is absent
+func (p path) lookup(v any) any {
+	for _, step := range p {
+		if step.isIndex {
+			arr, ok := v.([]any)
+			if !ok || step.index >= len(arr) {
+				return nil
+			}
+			v = arr[step.index]
+			continue
+		}
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[step.key]
+	}
+	return v
+}
+
+// mapping is the compiled config.Mapping
+type mapping struct {
+	regNum          path
+	mark            path
+	model           path
+	year            path
+	ownerName       path
+	ownerSurname    path
+	ownerPatronymic path
+}
+
+// newMapping compiles paths of cfg, paths of defaultMapping are used for empty ones
+func newMapping(cfg config.Mapping) (mapping, error) {
+	var m mapping
+	var errs []error
+	compile := func(dst *path, field string, s string, def string) {
+		if s == "" {
+			s = def
+		}
+		p, err := parsePath(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+		*dst = p
+	}
+	compile(&m.regNum, "regNum", cfg.RegNum, defaultMapping.RegNum)
+	compile(&m.mark, "mark", cfg.Mark, defaultMapping.Mark)
+	compile(&m.model, "model", cfg.Model, defaultMapping.Model)
+	compile(&m.year, "year", cfg.Year, defaultMapping.Year)
+	compile(&m.ownerName, "ownerName", cfg.OwnerName, defaultMapping.OwnerName)
+	compile(&m.ownerSurname, "ownerSurname", cfg.OwnerSurname, defaultMapping.OwnerSurname)
+	compile(&m.ownerPatronymic, "ownerPatronymic", cfg.OwnerPatronymic, defaultMapping.OwnerPatronymic)
+	return m, errors.Join(errs...)
+}
+
+// decode returns the car from the response body, absent fields are left empty, so partial
+// data of the provider can be merged with data of others
+func (m mapping) decode(body []byte) (model.Car, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	// numbers are kept as they are, so big years and regNums of digits are not rounded
+	dec.UseNumber()
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return model.Car{}, err
+	}
+
+	var errs []error
+	str := func(field string, p path) string {
+		s, err := toString(p.lookup(data))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+		return s
+	}
+	car := model.Car{
+		RegNum: str("regNum", m.regNum),
+		Mark:   str("mark", m.mark),
+		Model:  str("model", m.model),
+		Owner: model.Owner{
+			Name:       str("ownerName", m.ownerName),
+			Surname:    str("ownerSurname", m.ownerSurname),
+			Patronymic: str("ownerPatronymic", m.ownerPatronymic),
+		},
+	}
+	year, err := toInt(m.year.lookup(data))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("year: %w", err))
+	}
+	car.Year = year
+	return car, errors.Join(errs...)
+}
+
+// toString converts strings, numbers and booleans of JSON to a string, null is empty
+func toString(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unexpected %T instead of string", v)
+	}
+}
+
+// toInt converts numbers and numeric strings of JSON to an integer, null and empty string are zero
+func toInt(v any) (int, error) {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		s = v.String()
+	case string:
+		if s = strings.TrimSpace(v); s == "" {
+			return 0, nil
+		}
+	default:
+		return 0, fmt.Errorf("unexpected %T instead of integer", v)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not integer", s)
+	}
+	return n, nil
+}
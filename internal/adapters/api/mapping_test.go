@@ -0,0 +1,113 @@
+package api
+
+import (
+	"cars-service/internal/config"
+	"cars-service/internal/model"
+	"reflect"
+	"testing"
+)
+
+func TestMappingDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping config.Mapping
+		body    string
+		want    model.Car
+		wantErr bool
+	}{
+		{
+			name: "default",
+			body: `{"regNum":"X123XX150","mark":"Lada","model":"Vesta","year":2002,` +
+				`"owner":{"name":"Иван","surname":"Иванов","patronymic":"Иванович"}}`,
+			want: model.Car{
+				RegNum: "X123XX150",
+				Mark:   "Lada",
+				Model:  "Vesta",
+				Year:   2002,
+				Owner:  model.Owner{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"},
+			},
+		},
+		{
+			name: "nested with year as string",
+			mapping: config.Mapping{
+				RegNum:          "$.plate",
+				Mark:            "$.brand",
+				Model:           "$.model",
+				Year:            "$.year",
+				OwnerName:       "$.person.names[0]",
+				OwnerSurname:    "$.person.surname",
+				OwnerPatronymic: "$.person.names[1]",
+			},
+			body: `{"plate":"X123XX150","brand":"Lada","model":"Vesta","year":" 2002",` +
+				`"person":{"names":["Иван","Иванович"],"surname":"Иванов"}}`,
+			want: model.Car{
+				RegNum: "X123XX150",
+				Mark:   "Lada",
+				Model:  "Vesta",
+				Year:   2002,
+				Owner:  model.Owner{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"},
+			},
+		},
+		{
+			name: "absent fields",
+			body: `{"regNum":123,"owner":null,"year":null}`,
+			want: model.Car{RegNum: "123"},
+		},
+		{
+			name:    "year not integer",
+			body:    `{"year":"2002a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "object instead of string",
+			body:    `{"mark":{"name":"Lada"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			body:    `{"mark":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newMapping(tt.mapping)
+			if err != nil {
+				t.Fatalf("newMapping: %v", err)
+			}
+			got, err := m.decode([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decode error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decode = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "regNum"},
+		{path: "$.person.name"},
+		{path: "documents[0].number"},
+		{path: "matrix[1][2]"},
+		{path: "", wantErr: true},
+		{path: "$.", wantErr: true},
+		{path: "person..name", wantErr: true},
+		{path: "[0]", wantErr: true},
+		{path: "names[a]", wantErr: true},
+		{path: "names[0", wantErr: true},
+		{path: "names[0]x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if _, err := parsePath(tt.path); (err != nil) != tt.wantErr {
+				t.Errorf("parsePath error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
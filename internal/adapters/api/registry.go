@@ -0,0 +1,96 @@
+package api
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type registryImpl struct {
+	providers []Provider
+	mode      Mode
+}
+
+// result is a response of the provider
+type result struct {
+	car model.Car
+	err error
+}
+
+func (r *registryImpl) GetInfo(ctx context.Context, regNum string) (model.Car, error) {
+	car := model.Car{
+		RegNum:  regNum,
+		Sources: make(map[string]string),
+	}
+	var errs []error
+	add := func(p Provider, res result) {
+		if res.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, res.err))
+			return
+		}
+		merge(&car, res.car, p.Name)
+	}
+
+	if r.mode == ModeParallel {
+		results := make([]result, len(r.providers))
+		var wg sync.WaitGroup
+		for i, p := range r.providers {
+			wg.Add(1)
+			go func(i int, p Provider) {
+				defer wg.Done()
+				results[i].car, results[i].err = p.Api.GetInfo(ctx, regNum)
+			}(i, p)
+		}
+		wg.Wait()
+		for i, p := range r.providers {
+			add(p, results[i])
+		}
+	} else {
+		for _, p := range r.providers {
+			var res result
+			res.car, res.err = p.Api.GetInfo(ctx, regNum)
+			add(p, res)
+			if isComplete(car) || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+
+	if len(car.Sources) == 0 {
+		return model.Car{}, errors.Join(append([]error{model.ErrApiError}, errs...)...)
+	}
+	return car, nil
+}
+
+// merge fills empty fields of car with fields of from and records the provider as their source,
+// so fields of earlier providers win. The owner is taken as a whole, so names of different
+// people are not mixed
+func merge(car *model.Car, from model.Car, provider string) {
+	if car.Mark == "" && from.Mark != "" {
+		car.Mark = from.Mark
+		car.Sources[model.FieldMark] = provider
+	}
+	if car.Model == "" && from.Model != "" {
+		car.Model = from.Model
+		car.Sources[model.FieldModel] = provider
+	}
+	if car.Year == 0 && from.Year != 0 {
+		car.Year = from.Year
+		car.Sources[model.FieldYear] = provider
+	}
+	if car.Owner.Name == "" && car.Owner.Surname == "" && (from.Owner.Name != "" || from.Owner.Surname != "") {
+		car.Owner = model.Owner{
+			Name:       from.Owner.Name,
+			Surname:    from.Owner.Surname,
+			Patronymic: from.Owner.Patronymic,
+		}
+		car.Sources[model.FieldOwner] = provider
+	}
+}
+
+// isComplete reports whether all fields of the car are found, so next providers are not queried
+func isComplete(car model.Car) bool {
+	return car.Mark != "" && car.Model != "" && car.Year != 0 && car.Owner.Name != "" && car.Owner.Surname != ""
+}
@@ -8,15 +8,17 @@ import (
 	"context"
 	"errors"
 	"golang.org/x/sync/errgroup"
+	"strings"
 	"sync"
-	"time"
-	"unicode"
 )
 
 type appImpl struct {
 	logger.Logger
 	api.Api
 	repo.Repo
+	jobs      repo.JobRepo
+	webhooks  repo.WebhookRepo
+	refreshes repo.RefreshRepo
 }
 
 func (a *appImpl) GetCarById(ctx context.Context, id uint64) (model.Car, error) {
@@ -44,6 +46,18 @@ func (a *appImpl) GetCars(ctx context.Context, filter model.Filter) ([]model.Car
 	return cars, err
 }
 
+func (a *appImpl) IterateCars(ctx context.Context, filter model.Filter, fn func(model.Car) error) error {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "IterateCars",
+		}, err)
+	}()
+
+	err = a.Repo.IterateCars(ctx, filter, fn)
+	return err
+}
+
 func (a *appImpl) AddCars(ctx context.Context, regNums []string) ([]model.Car, error) {
 	var err error
 	defer func() {
@@ -56,19 +70,22 @@ func (a *appImpl) AddCars(ctx context.Context, regNums []string) ([]model.Car, e
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	// getting data of all regNums
+	if err = validateRegNums(regNums); err != nil {
+		return []model.Car{}, err
+	}
+
+	// getting data of all regNums, repeated ones are fetched once
+	seen := make(map[string]bool, len(regNums))
 	for _, regNum := range regNums {
-		wg.Add(1)
-		if !isValidRegNum([]rune(regNum)) {
-			a.Logger.Debug(logger.Fields{
-				"Method": "AddCars",
-				"RegNum": regNum,
-			}, model.ErrValidation.Error())
+		regNum = strings.TrimSpace(regNum)
+		if seen[regNum] {
 			continue
 		}
+		seen[regNum] = true
+		wg.Add(1)
 		go func(regNum string) {
 			defer wg.Done()
-			car, err := a.Api.GetInfo(ctx, regNum)
+			car, err := a.fetchCar(ctx, regNum)
 			if err != nil {
 				a.Logger.Debug(logger.Fields{
 					"Method": "GetInfo",
@@ -88,6 +105,10 @@ func (a *appImpl) AddCars(ctx context.Context, regNums []string) ([]model.Car, e
 		}(regNum)
 	}
 	wg.Wait()
+	// regNums which are not fetched because of the deadline are not skipped as unknown ones
+	if err = ctx.Err(); err != nil {
+		return []model.Car{}, err
+	}
 
 	res := make([]model.Car, 0, len(cars))
 
@@ -114,6 +135,85 @@ func (a *appImpl) AddCars(ctx context.Context, regNums []string) ([]model.Car, e
 	return res, nil
 }
 
+func (a *appImpl) AddCar(ctx context.Context, regNum string) (model.Car, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "AddCar",
+			"RegNum": regNum,
+		}, err)
+	}()
+
+	regNum = strings.TrimSpace(regNum)
+	if err = validateRegNum(regNum); err != nil {
+		return model.Car{}, err
+	}
+	car, err := a.fetchCar(ctx, regNum)
+	if err != nil {
+		return model.Car{}, err
+	}
+
+	car, err = a.Repo.AddCar(ctx, car)
+	return car, err
+}
+
+func (a *appImpl) ImportCars(ctx context.Context, cars []model.Car) []model.ImportItem {
+	res := make([]model.ImportItem, len(cars))
+	var gr errgroup.Group
+	gr.SetLimit(importCarsConcurrency)
+	for i, car := range cars {
+		i, car := i, car
+		gr.Go(func() error {
+			car, err := a.importCar(ctx, car)
+			res[i] = model.ImportItem{
+				RegNum: car.RegNum,
+				Status: model.ImportDone,
+				CarId:  car.Id,
+			}
+			if err != nil {
+				res[i].Status = model.ImportFailed
+				res[i].Error = importErrorText(err)
+			}
+			return nil
+		})
+	}
+	_ = gr.Wait()
+
+	a.writeLogs(logger.Fields{
+		"Method": "ImportCars",
+		"Total":  len(cars),
+	}, nil)
+	return res
+}
+
+// importCar adds the car as is if all its fields are filled, otherwise it gets missing data
+// from the outer API
+func (a *appImpl) importCar(ctx context.Context, car model.Car) (model.Car, error) {
+	car = normalizeCar(car)
+	if car.Mark == "" || car.Model == "" || car.Year == 0 || car.Owner.Name == "" || car.Owner.Surname == "" {
+		added, err := a.AddCar(ctx, car.RegNum)
+		if err != nil {
+			return car, err
+		}
+		return added, nil
+	}
+
+	if err := validateCar(car); err != nil {
+		return car, err
+	}
+	car.Sources = map[string]string{
+		model.FieldMark:  model.SourceManual,
+		model.FieldModel: model.SourceManual,
+		model.FieldYear:  model.SourceManual,
+		model.FieldOwner: model.SourceManual,
+	}
+	added, err := a.Repo.AddCar(ctx, car)
+	if err != nil {
+		return car, err
+	}
+	return added, nil
+}
+
 func (a *appImpl) UpdateCar(ctx context.Context, id uint64, car model.Car) (model.Car, error) {
 	var err error
 	defer func() {
@@ -122,11 +222,14 @@ func (a *appImpl) UpdateCar(ctx context.Context, id uint64, car model.Car) (mode
 			"CarId":  id,
 		}, err)
 	}()
-	if !isValidRegNum([]rune(car.RegNum)) || !isValidYear(car.Year) {
-		err = model.ErrValidation
+
+	car = normalizeCar(car)
+	if err = validateCar(car); err != nil {
 		return model.Car{}, err
 	}
 
+	// sources are left to the repo, it attributes changed fields to manual input
+	car.Sources = nil
 	car, err = a.Repo.UpdateCar(ctx, id, car)
 	return car, err
 }
@@ -144,26 +247,19 @@ func (a *appImpl) DeleteCar(ctx context.Context, id uint64) error {
 	return err
 }
 
-func isValidRegNum(regNum []rune) bool {
-	if len(regNum) != 9 && len(regNum) != 8 {
-		return false
-	}
-	if len(regNum) == 9 && !unicode.IsDigit(regNum[8]) {
-		return false
+// fetchCar gets data of the car with valid regNum from the outer API and validates it
+// the same way as user input
+func (a *appImpl) fetchCar(ctx context.Context, regNum string) (model.Car, error) {
+	car, err := a.Api.GetInfo(ctx, regNum)
+	if err != nil {
+		return model.Car{}, err
 	}
 
-	return unicode.IsLetter(regNum[0]) &&
-		unicode.IsDigit(regNum[1]) &&
-		unicode.IsDigit(regNum[2]) &&
-		unicode.IsDigit(regNum[3]) &&
-		unicode.IsLetter(regNum[4]) &&
-		unicode.IsLetter(regNum[5]) &&
-		unicode.IsDigit(regNum[6]) &&
-		unicode.IsDigit(regNum[7])
-}
-
-func isValidYear(year int) bool {
-	return year >= 1900 && year <= time.Now().UTC().Year()
+	car = normalizeCar(car)
+	if err = validateCar(car); err != nil {
+		return model.Car{}, err
+	}
+	return car, nil
 }
 
 func (a *appImpl) writeLogs(fields logger.Fields, err error) {
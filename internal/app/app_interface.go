@@ -2,6 +2,7 @@ package app
 
 import (
 	"cars-service/internal/adapters/api"
+	"cars-service/internal/config"
 	"cars-service/internal/model"
 	"cars-service/internal/repo"
 	"cars-service/pkg/logger"
@@ -12,17 +13,103 @@ import (
 type App interface {
 	GetCarById(ctx context.Context, id uint64) (model.Car, error)
 	GetCars(ctx context.Context, filter model.Filter) ([]model.Car, error)
+	// IterateCars calls fn for every filtered car without loading all of them into memory
+	IterateCars(ctx context.Context, filter model.Filter, fn func(model.Car) error) error
 
+	// AddCars adds cars found by regNums in the outer API, if any regNum is invalid nothing is added
 	AddCars(ctx context.Context, regNums []string) ([]model.Car, error)
+	// AddCar adds a single car, unlike AddCars it returns the reason why the car was not added
+	AddCar(ctx context.Context, regNum string) (model.Car, error)
+	// ImportCars adds cars with all fields filled as is and gets data of the rest from the outer
+	// API, the result of every car is returned in the same order
+	ImportCars(ctx context.Context, cars []model.Car) []model.ImportItem
 	UpdateCar(ctx context.Context, id uint64, car model.Car) (model.Car, error)
 	DeleteCar(ctx context.Context, id uint64) error
+
+	GetOwnerById(ctx context.Context, id uint64) (model.Owner, error)
+	// GetCarsByOwnerIds returns cars of every owner, it is used for batch loading of related data
+	GetCarsByOwnerIds(ctx context.Context, ownerIds []uint64) (map[uint64][]model.Car, error)
+	// GetOwnershipHistory returns ownerships of every car from the oldest one
+	GetOwnershipHistory(ctx context.Context, carIds []uint64) (map[uint64][]model.Ownership, error)
+	// MergeOwners moves all cars of duplicate owners from to the owner into and deletes duplicates,
+	// it returns moved cars
+	MergeOwners(ctx context.Context, into uint64, from []uint64) ([]model.Car, error)
+
+	// CreateImportJob saves new import job which is processed in the background by ImportRunner
+	CreateImportJob(ctx context.Context, regNums []string) (model.ImportJob, error)
+	GetImportJob(ctx context.Context, id uint64) (model.ImportJob, error)
+	CancelImportJob(ctx context.Context, id uint64) (model.ImportJob, error)
+
+	// CreateWebhook subscribes the webhook to events, the secret for signing payloads
+	// is generated if it is empty
+	CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error)
+	GetWebhook(ctx context.Context, id uint64) (model.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]model.Webhook, error)
+	UpdateWebhook(ctx context.Context, id uint64, webhook model.Webhook) (model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uint64) error
+	// GetWebhookDeliveries returns the latest deliveries of the webhook
+	GetWebhookDeliveries(ctx context.Context, id uint64) ([]model.WebhookDelivery, error)
+
+	// RefreshCar queries providers for the stored car again, changes are applied at once if apply
+	// is set, otherwise they are saved as pending refresh for review
+	RefreshCar(ctx context.Context, id uint64, apply bool) (model.Refresh, error)
+	GetRefresh(ctx context.Context, id uint64) (model.Refresh, error)
+	// GetRefreshes returns the latest refreshes with the status
+	GetRefreshes(ctx context.Context, status model.RefreshStatus) ([]model.Refresh, error)
+	// ApplyRefresh applies changes of pending refresh to the car
+	ApplyRefresh(ctx context.Context, id uint64) (model.Refresh, error)
+	RejectRefresh(ctx context.Context, id uint64) (model.Refresh, error)
 }
 
 // New creates App implementation
-func New(r repo.Repo, cli api.Api, logs logger.Logger) App {
+func New(r repo.Repo, jobs repo.JobRepo, webhooks repo.WebhookRepo, refreshes repo.RefreshRepo, cli api.Api, logs logger.Logger) App {
 	return &appImpl{
-		Logger: logs,
-		Api:    cli,
-		Repo:   r,
+		Logger:    logs,
+		Api:       cli,
+		Repo:      r,
+		jobs:      jobs,
+		webhooks:  webhooks,
+		refreshes: refreshes,
+	}
+}
+
+// ImportRunner processes pending import jobs in the background
+type ImportRunner interface {
+	// Run processes import jobs until ctx is done, unfinished jobs are resumed on the next Run
+	Run(ctx context.Context)
+}
+
+// NewImportRunner creates ImportRunner implementation which adds cars using the given
+// number of concurrent workers
+func NewImportRunner(a App, jobs repo.JobRepo, logs logger.Logger, workers int) ImportRunner {
+	return &importRunnerImpl{
+		App:     a,
+		jobs:    jobs,
+		logs:    logs,
+		workers: workers,
+	}
+}
+
+// RefreshRunner refreshes stale cars in the background
+type RefreshRunner interface {
+	// Run refreshes batches of stale cars with the interval of the configuration until ctx is done,
+	// it returns at once if the interval is zero
+	Run(ctx context.Context)
+}
+
+// NewRefreshRunner creates RefreshRunner implementation, the configuration must be valid
+func NewRefreshRunner(a App, refreshes repo.RefreshRepo, logs logger.Logger, cfg config.Refresh) RefreshRunner {
+	// quiet hours are validated with the configuration
+	quietFrom, quietTo, _ := config.ParseQuietHours(cfg.QuietHours)
+	return &refreshRunnerImpl{
+		App:       a,
+		refreshes: refreshes,
+		logs:      logs,
+		interval:  cfg.Interval,
+		maxAge:    cfg.MaxAge,
+		batchSize: cfg.BatchSize,
+		quietFrom: quietFrom,
+		quietTo:   quietTo,
+		autoApply: cfg.AutoApply,
 	}
 }
@@ -0,0 +1,267 @@
+package app
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// importPollInterval is an interval between checks for new import jobs
+	importPollInterval = 2 * time.Second
+
+	// importBatchSize is a number of items loaded from the database at once,
+	// cancellation of the job is checked between batches
+	importBatchSize = 100
+
+	// importCarsConcurrency is a number of cars added concurrently by ImportCars
+	importCarsConcurrency = 8
+
+	// importMaxAttempts is a number of attempts of the item failing with temporary errors
+	// after which it is failed, importRetryBackoff is a delay before the first retry which
+	// is doubled after every attempt
+	importMaxAttempts  = 5
+	importRetryBackoff = 10 * time.Second
+)
+
+func (a *appImpl) CreateImportJob(ctx context.Context, regNums []string) (model.ImportJob, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "CreateImportJob",
+			"Total":  len(regNums),
+		}, err)
+	}()
+
+	trimmed := make([]string, 0, len(regNums))
+	for _, regNum := range regNums {
+		if regNum = strings.TrimSpace(regNum); regNum != "" {
+			trimmed = append(trimmed, regNum)
+		}
+	}
+	if len(trimmed) == 0 {
+		err = model.ErrInvalidInput
+		return model.ImportJob{}, err
+	}
+
+	job, err := a.jobs.CreateJob(ctx, trimmed)
+	return job, err
+}
+
+func (a *appImpl) GetImportJob(ctx context.Context, id uint64) (model.ImportJob, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "GetImportJob",
+			"JobId":  id,
+		}, err)
+	}()
+
+	job, err := a.jobs.GetJob(ctx, id)
+	return job, err
+}
+
+func (a *appImpl) CancelImportJob(ctx context.Context, id uint64) (model.ImportJob, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "CancelImportJob",
+			"JobId":  id,
+		}, err)
+	}()
+
+	if err = a.jobs.CancelJob(ctx, id); err != nil {
+		return model.ImportJob{}, err
+	}
+	job, err := a.jobs.GetJob(ctx, id)
+	return job, err
+}
+
+type importRunnerImpl struct {
+	App
+	jobs    repo.JobRepo
+	logs    logger.Logger
+	workers int
+}
+
+func (r *importRunnerImpl) Run(ctx context.Context) {
+	ticker := time.NewTicker(importPollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.processActiveJobs(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processActiveJobs processes all pending and running jobs one by one in order of creation
+func (r *importRunnerImpl) processActiveJobs(ctx context.Context) {
+	jobs, err := r.jobs.GetActiveJobs(ctx)
+	if err != nil {
+		r.logError(0, err)
+		return
+	}
+
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		r.processJob(ctx, job)
+	}
+}
+
+func (r *importRunnerImpl) processJob(ctx context.Context, job model.ImportJob) {
+	if job.Status == model.ImportPending {
+		if err := r.jobs.SetJobStatus(ctx, job.Id, model.ImportRunning); err != nil {
+			r.logError(job.Id, err)
+			return
+		}
+	}
+
+	for ctx.Err() == nil {
+		// the job could be cancelled while the previous batch was processed
+		if status, err := r.jobs.GetJobStatus(ctx, job.Id); err != nil {
+			r.logError(job.Id, err)
+			return
+		} else if status != model.ImportRunning {
+			return
+		}
+
+		items, err := r.jobs.GetPendingItems(ctx, job.Id, importBatchSize)
+		if err != nil {
+			r.logError(job.Id, err)
+			return
+		}
+		if len(items) == 0 {
+			// items waiting for a retry are processed on later polls
+			if pending, err := r.jobs.HasPendingItems(ctx, job.Id); err != nil {
+				r.logError(job.Id, err)
+				return
+			} else if pending {
+				return
+			}
+			if err = r.jobs.SetJobStatus(ctx, job.Id, model.ImportDone); err != nil {
+				r.logError(job.Id, err)
+				return
+			}
+			r.logs.Info(logger.Fields{
+				"Method": "ImportRunner",
+				"JobId":  job.Id,
+			}, "import job is done")
+			return
+		}
+
+		if !r.processItems(ctx, items) {
+			// items which are left pending are retried on later polls
+			return
+		}
+	}
+}
+
+// processItems concurrently adds cars of the given items and returns false if any of them
+// failed with a temporary error and should be retried later
+func (r *importRunnerImpl) processItems(ctx context.Context, items []model.ImportItem) bool {
+	sem := make(chan struct{}, r.workers)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := true
+
+	for _, item := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item model.ImportItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if !r.processItem(ctx, item) {
+				mu.Lock()
+				ok = false
+				mu.Unlock()
+			}
+		}(item)
+	}
+	wg.Wait()
+	return ok
+}
+
+// processItem adds the car of the item and saves the result, it returns false if the item
+// stays pending because of shutdown or temporary error. The added car is saved as the result
+// in the same transaction by the repo, so it is not reported as duplicate after restart
+func (r *importRunnerImpl) processItem(ctx context.Context, item model.ImportItem) bool {
+	car, err := r.App.AddCar(repo.WithImportItem(ctx, item.Id), item.RegNum)
+	if ctx.Err() != nil {
+		return false
+	}
+
+	switch {
+	case err == nil:
+		item.Status = model.ImportDone
+		item.CarId = car.Id
+	case isTemporaryError(err) && item.Attempts+1 < importMaxAttempts:
+		r.retryItem(ctx, item)
+		return false
+	default:
+		item.Status = model.ImportFailed
+		item.Error = importErrorText(err)
+	}
+
+	if err = r.jobs.SetItemResult(ctx, item); err != nil {
+		r.logs.Error(logger.Fields{
+			"Method": "ImportRunner",
+			"ItemId": item.Id,
+		}, err.Error())
+		return false
+	}
+	return true
+}
+
+// retryItem postpones the item by importRetryBackoff doubled for every failed attempt
+func (r *importRunnerImpl) retryItem(ctx context.Context, item model.ImportItem) {
+	retryAt := time.Now().Add(importRetryBackoff << item.Attempts)
+	if err := r.jobs.RetryItem(ctx, item.Id, retryAt); err != nil {
+		r.logs.Error(logger.Fields{
+			"Method": "ImportRunner",
+			"ItemId": item.Id,
+		}, err.Error())
+	}
+}
+
+// isTemporaryError reports whether the item failed with err may be imported if it is retried
+func isTemporaryError(err error) bool {
+	return errors.Is(err, model.ErrDatabaseError) || errors.Is(err, model.ErrApiUnavailable)
+}
+
+func (r *importRunnerImpl) logError(jobId uint64, err error) {
+	// the job can be cancelled at any moment, it is not an error of the runner
+	if errors.Is(err, context.Canceled) || errors.Is(err, model.ErrJobFinished) {
+		return
+	}
+	r.logs.Error(logger.Fields{
+		"Method": "ImportRunner",
+		"JobId":  jobId,
+	}, err.Error())
+}
+
+// importErrorText returns the reason of failed import without internal details of the error
+func importErrorText(err error) string {
+	switch {
+	case errors.Is(err, model.ErrValidation):
+		return err.Error()
+	case errors.Is(err, model.ErrDuplicateRegNum):
+		return model.ErrDuplicateRegNum.Error()
+	case errors.Is(err, model.ErrApiError):
+		return model.ErrApiError.Error()
+	default:
+		return model.ErrServiceError.Error()
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"cars-service/internal/model"
+	"cars-service/pkg/logger"
+	"context"
+	"slices"
+)
+
+func (a *appImpl) GetOwnerById(ctx context.Context, id uint64) (model.Owner, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":  "GetOwnerById",
+			"OwnerId": id,
+		}, err)
+	}()
+
+	owner, err := a.Repo.GetOwnerById(ctx, id)
+	return owner, err
+}
+
+func (a *appImpl) GetCarsByOwnerIds(ctx context.Context, ownerIds []uint64) (map[uint64][]model.Car, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "GetCarsByOwnerIds",
+			"Owners": len(ownerIds),
+		}, err)
+	}()
+
+	cars, err := a.Repo.GetCarsByOwnerIds(ctx, ownerIds)
+	return cars, err
+}
+
+func (a *appImpl) GetOwnershipHistory(ctx context.Context, carIds []uint64) (map[uint64][]model.Ownership, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "GetOwnershipHistory",
+			"Cars":   len(carIds),
+		}, err)
+	}()
+
+	history, err := a.Repo.GetOwnershipHistory(ctx, carIds)
+	return history, err
+}
+
+func (a *appImpl) MergeOwners(ctx context.Context, into uint64, from []uint64) ([]model.Car, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":  "MergeOwners",
+			"OwnerId": into,
+			"From":    from,
+		}, err)
+	}()
+
+	if len(from) == 0 || slices.Contains(from, into) {
+		err = model.ErrInvalidInput
+		return nil, err
+	}
+	from = slices.Clone(from)
+	slices.Sort(from)
+	from = slices.Compact(from)
+
+	cars, err := a.Repo.MergeOwners(ctx, into, from)
+	return cars, err
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"errors"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// refreshesLimit is a number of the latest refreshes returned by GetRefreshes
+const refreshesLimit = 100
+
+func (a *appImpl) RefreshCar(ctx context.Context, id uint64, apply bool) (model.Refresh, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "RefreshCar",
+			"CarId":  id,
+		}, err)
+	}()
+
+	car, err := a.Repo.GetCarById(repo.WithPrimary(ctx), id)
+	if err != nil {
+		return model.Refresh{}, err
+	}
+	fetched, err := a.fetchCar(ctx, car.RegNum)
+	if err != nil {
+		return model.Refresh{}, err
+	}
+
+	refresh := model.Refresh{
+		CarId:   id,
+		Status:  model.RefreshUnchanged,
+		Changes: diffCars(car, fetched),
+		Car:     car,
+	}
+	if len(refresh.Changes) != 0 {
+		refresh.Car = applyChanges(car, fetched, refresh.Changes)
+		refresh.Status = model.RefreshPending
+		// refresh.Car keeps the version of car, so the car changed during the slow request to
+		// providers is not overwritten. The applied change is saved with its refresh, so the
+		// history does not miss it
+		var save func(ctx context.Context) (model.Car, error)
+		if apply {
+			refresh.Status = model.RefreshApplied
+			save = func(ctx context.Context) (model.Car, error) {
+				return a.Repo.UpdateCar(ctx, id, refresh.Car)
+			}
+		}
+		// unchanged cars are only marked as synced, so the history contains only changes
+		if refresh, err = a.refreshes.AddRefresh(ctx, refresh, save); err != nil {
+			return model.Refresh{}, err
+		}
+	}
+	if err = a.refreshes.SetCarSynced(ctx, id, time.Now()); err != nil {
+		return model.Refresh{}, err
+	}
+	return refresh, nil
+}
+
+func (a *appImpl) GetRefresh(ctx context.Context, id uint64) (model.Refresh, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":    "GetRefresh",
+			"RefreshId": id,
+		}, err)
+	}()
+
+	refresh, err := a.refreshes.GetRefresh(ctx, id)
+	return refresh, err
+}
+
+func (a *appImpl) GetRefreshes(ctx context.Context, status model.RefreshStatus) ([]model.Refresh, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "GetRefreshes",
+			"Status": status,
+		}, err)
+	}()
+
+	switch status {
+	case model.RefreshPending, model.RefreshApplied, model.RefreshRejected, model.RefreshSuperseded:
+	default:
+		err = model.ErrInvalidInput
+		return nil, err
+	}
+	refreshes, err := a.refreshes.GetRefreshes(ctx, status, refreshesLimit)
+	return refreshes, err
+}
+
+func (a *appImpl) ApplyRefresh(ctx context.Context, id uint64) (model.Refresh, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":    "ApplyRefresh",
+			"RefreshId": id,
+		}, err)
+	}()
+
+	refresh, err := a.refreshes.GetRefresh(ctx, id)
+	if err != nil {
+		return model.Refresh{}, err
+	}
+	if refresh.Status != model.RefreshPending {
+		err = model.ErrRefreshResolved
+		return model.Refresh{}, err
+	}
+
+	// fields changed by users after the refresh are kept unless the refresh changes them too
+	car, err := a.Repo.GetCarById(repo.WithPrimary(ctx), refresh.CarId)
+	if err != nil {
+		return model.Refresh{}, err
+	}
+	// the car is saved with the version it is read with, so changes made since then are not lost
+	if err = a.refreshes.ApplyRefresh(ctx, id, func(ctx context.Context) error {
+		car, err = a.Repo.UpdateCar(ctx, car.Id, applyChanges(car, refresh.Car, refresh.Changes))
+		return err
+	}); err != nil {
+		return model.Refresh{}, err
+	}
+	if refresh, err = a.refreshes.GetRefresh(ctx, id); err != nil {
+		return model.Refresh{}, err
+	}
+	refresh.Car = car
+	return refresh, nil
+}
+
+func (a *appImpl) RejectRefresh(ctx context.Context, id uint64) (model.Refresh, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":    "RejectRefresh",
+			"RefreshId": id,
+		}, err)
+	}()
+
+	if err = a.refreshes.ResolveRefresh(ctx, id, model.RefreshRejected); err != nil {
+		return model.Refresh{}, err
+	}
+	refresh, err := a.refreshes.GetRefresh(ctx, id)
+	return refresh, err
+}
+
+// diffCars returns fields of the stored car which differ from the data of providers
+func diffCars(car model.Car, fetched model.Car) []model.FieldChange {
+	var changes []model.FieldChange
+	add := func(field string, from string, to string) {
+		if from != to {
+			changes = append(changes, model.FieldChange{
+				Field:  field,
+				Old:    from,
+				New:    to,
+				Source: fetched.Sources[field],
+			})
+		}
+	}
+	add(model.FieldMark, car.Mark, fetched.Mark)
+	add(model.FieldModel, car.Model, fetched.Model)
+	add(model.FieldYear, strconv.Itoa(car.Year), strconv.Itoa(fetched.Year))
+	add(model.FieldOwner, fullName(car.Owner), fullName(fetched.Owner))
+	return changes
+}
+
+// applyChanges returns the car with changed fields taken from fetched and attributed to their sources
+func applyChanges(car model.Car, fetched model.Car, changes []model.FieldChange) model.Car {
+	car.Sources = maps.Clone(car.Sources)
+	if car.Sources == nil {
+		car.Sources = make(map[string]string)
+	}
+	for _, change := range changes {
+		switch change.Field {
+		case model.FieldMark:
+			car.Mark = fetched.Mark
+		case model.FieldModel:
+			car.Model = fetched.Model
+		case model.FieldYear:
+			car.Year = fetched.Year
+		case model.FieldOwner:
+			car.Owner = model.Owner{
+				Name:       fetched.Owner.Name,
+				Surname:    fetched.Owner.Surname,
+				Patronymic: fetched.Owner.Patronymic,
+			}
+		}
+		// the previous source is not the source of the new value even if the provider is unknown
+		if change.Source != "" {
+			car.Sources[change.Field] = change.Source
+		} else {
+			delete(car.Sources, change.Field)
+		}
+	}
+	return car
+}
+
+// fullName returns the name of the owner as it is written in documents
+func fullName(owner model.Owner) string {
+	return strings.Join(strings.Fields(owner.Surname+" "+owner.Name+" "+owner.Patronymic), " ")
+}
+
+type refreshRunnerImpl struct {
+	App
+	refreshes repo.RefreshRepo
+	logs      logger.Logger
+	interval  time.Duration
+	maxAge    time.Duration
+	batchSize int
+	quietFrom time.Duration
+	quietTo   time.Duration
+	autoApply bool
+}
+
+func (r *refreshRunnerImpl) Run(ctx context.Context) {
+	if r.interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.refreshStaleCars(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshStaleCars refreshes a batch of the least recently synced cars outside of quiet hours
+func (r *refreshRunnerImpl) refreshStaleCars(ctx context.Context) {
+	if r.isQuiet(time.Now()) {
+		return
+	}
+	cars, err := r.refreshes.GetStaleCars(ctx, time.Now().Add(-r.maxAge), uint(r.batchSize))
+	if err != nil {
+		r.logError(0, err)
+		return
+	}
+
+	for _, car := range cars {
+		if ctx.Err() != nil || r.isQuiet(time.Now()) {
+			return
+		}
+		// errors of RefreshCar are logged by App
+		if _, err = r.RefreshCar(ctx, car.Id, r.autoApply); err == nil || ctx.Err() != nil {
+			continue
+		}
+		// the failed car is retried after maxAge, so it does not block the rest of stale cars
+		if err = r.refreshes.SetCarSynced(ctx, car.Id, time.Now()); err != nil {
+			r.logError(car.Id, err)
+		}
+	}
+}
+
+// isQuiet reports whether the local time of t is within quiet hours which may wrap around midnight
+func (r *refreshRunnerImpl) isQuiet(t time.Time) bool {
+	if r.quietFrom == r.quietTo {
+		return false
+	}
+	year, month, day := t.Date()
+	offset := t.Sub(time.Date(year, month, day, 0, 0, 0, 0, t.Location()))
+	if r.quietFrom < r.quietTo {
+		return offset >= r.quietFrom && offset < r.quietTo
+	}
+	return offset >= r.quietFrom || offset < r.quietTo
+}
+
+func (r *refreshRunnerImpl) logError(carId uint64, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	r.logs.Error(logger.Fields{
+		"Method": "RefreshRunner",
+		"CarId":  carId,
+	}, err.Error())
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"cars-service/internal/model"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxNameLength is a length of VARCHAR columns for marks, models and owners
+const maxNameLength = 100
+
+// normalizeCar trims leading and trailing spaces of all string fields of the car
+func normalizeCar(car model.Car) model.Car {
+	car.RegNum = strings.TrimSpace(car.RegNum)
+	car.Mark = strings.TrimSpace(car.Mark)
+	car.Model = strings.TrimSpace(car.Model)
+	car.Owner.Name = strings.TrimSpace(car.Owner.Name)
+	car.Owner.Surname = strings.TrimSpace(car.Owner.Surname)
+	car.Owner.Patronymic = strings.TrimSpace(car.Owner.Patronymic)
+	return car
+}
+
+// validateCar checks all fields of the normalized car and returns *model.ValidationError
+// with every failing field or nil if the car is valid
+func validateCar(car model.Car) error {
+	var fields []model.FieldError
+	if !isValidRegNum([]rune(car.RegNum)) {
+		fields = append(fields, model.FieldError{
+			Field:  "regNum",
+			Reason: "must match format X000XX00 or X000XX000",
+		})
+	}
+	if !isValidYear(car.Year) {
+		fields = append(fields, model.FieldError{
+			Field:  "year",
+			Reason: fmt.Sprintf("must be between 1900 and %d", time.Now().UTC().Year()),
+		})
+	}
+	fields = appendNameErrors(fields, "mark", car.Mark, true)
+	fields = appendNameErrors(fields, "model", car.Model, true)
+	fields = appendNameErrors(fields, "owner.name", car.Owner.Name, true)
+	fields = appendNameErrors(fields, "owner.surname", car.Owner.Surname, true)
+	fields = appendNameErrors(fields, "owner.patronymic", car.Owner.Patronymic, false)
+
+	if len(fields) != 0 {
+		return &model.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateRegNum checks only registration number, it is used before requests to the outer API
+func validateRegNum(regNum string) error {
+	if !isValidRegNum([]rune(regNum)) {
+		return &model.ValidationError{Fields: []model.FieldError{{
+			Field:  "regNum",
+			Reason: "must match format X000XX00 or X000XX000",
+		}}}
+	}
+	return nil
+}
+
+// validateRegNums checks registration numbers of the batch, every invalid one is reported
+// as a separate failure of regNums field
+func validateRegNums(regNums []string) error {
+	var fields []model.FieldError
+	for _, regNum := range regNums {
+		regNum = strings.TrimSpace(regNum)
+		if !isValidRegNum([]rune(regNum)) {
+			fields = append(fields, model.FieldError{
+				Field:  "regNums",
+				Reason: regNum + ": must match format X000XX00 or X000XX000",
+			})
+		}
+	}
+	if len(fields) != 0 {
+		return &model.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// appendNameErrors validates text field and appends its error to fields if there is any
+func appendNameErrors(fields []model.FieldError, name string, value string, required bool) []model.FieldError {
+	switch {
+	case value == "" && required:
+		return append(fields, model.FieldError{Field: name, Reason: "must not be empty"})
+	case utf8.RuneCountInString(value) > maxNameLength:
+		return append(fields, model.FieldError{
+			Field:  name,
+			Reason: fmt.Sprintf("must not be longer than %d characters", maxNameLength),
+		})
+	case strings.IndexFunc(value, unicode.IsControl) != -1:
+		return append(fields, model.FieldError{Field: name, Reason: "must not contain control characters"})
+	default:
+		return fields
+	}
+}
+
+func isValidRegNum(regNum []rune) bool {
+	if len(regNum) != 9 && len(regNum) != 8 {
+		return false
+	}
+	if len(regNum) == 9 && !unicode.IsDigit(regNum[8]) {
+		return false
+	}
+
+	return unicode.IsLetter(regNum[0]) &&
+		unicode.IsDigit(regNum[1]) &&
+		unicode.IsDigit(regNum[2]) &&
+		unicode.IsDigit(regNum[3]) &&
+		unicode.IsLetter(regNum[4]) &&
+		unicode.IsLetter(regNum[5]) &&
+		unicode.IsDigit(regNum[6]) &&
+		unicode.IsDigit(regNum[7])
+}
+
+func isValidYear(year int) bool {
+	return year >= 1900 && year <= time.Now().UTC().Year()
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/webhooks"
+	"cars-service/pkg/logger"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+const (
+	// webhookDeliveriesLimit is a number of the latest deliveries returned by GetWebhookDeliveries
+	webhookDeliveriesLimit = 100
+
+	// webhookSecretLength is a length in bytes of generated secrets for signing payloads
+	webhookSecretLength = 32
+)
+
+func (a *appImpl) CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "CreateWebhook",
+		}, err)
+	}()
+
+	webhook = normalizeWebhook(webhook)
+	if err = validateWebhook(webhook); err != nil {
+		return model.Webhook{}, err
+	}
+	if webhook.Secret == "" {
+		secret := make([]byte, webhookSecretLength)
+		if _, err = rand.Read(secret); err != nil {
+			return model.Webhook{}, errors.Join(model.ErrServiceError, err)
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+	webhook.Active = true
+
+	webhook, err = a.webhooks.CreateWebhook(ctx, webhook)
+	return webhook, err
+}
+
+func (a *appImpl) GetWebhook(ctx context.Context, id uint64) (model.Webhook, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":    "GetWebhook",
+			"WebhookId": id,
+		}, err)
+	}()
+
+	webhook, err := a.webhooks.GetWebhook(ctx, id)
+	return webhook, err
+}
+
+func (a *appImpl) GetWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method": "GetWebhooks",
+		}, err)
+	}()
+
+	webhooks, err := a.webhooks.GetWebhooks(ctx)
+	return webhooks, err
+}
+
+func (a *appImpl) UpdateWebhook(ctx context.Context, id uint64, webhook model.Webhook) (model.Webhook, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":    "UpdateWebhook",
+			"WebhookId": id,
+		}, err)
+	}()
+
+	webhook = normalizeWebhook(webhook)
+	if err = validateWebhook(webhook); err != nil {
+		return model.Webhook{}, err
+	}
+	webhook.Id = id
+
+	webhook, err = a.webhooks.UpdateWebhook(ctx, webhook)
+	return webhook, err
+}
+
+func (a *appImpl) DeleteWebhook(ctx context.Context, id uint64) error {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":    "DeleteWebhook",
+			"WebhookId": id,
+		}, err)
+	}()
+
+	err = a.webhooks.DeleteWebhook(ctx, id)
+	return err
+}
+
+func (a *appImpl) GetWebhookDeliveries(ctx context.Context, id uint64) ([]model.WebhookDelivery, error) {
+	var err error
+	defer func() {
+		a.writeLogs(logger.Fields{
+			"Method":    "GetWebhookDeliveries",
+			"WebhookId": id,
+		}, err)
+	}()
+
+	if _, err = a.webhooks.GetWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	deliveries, err := a.webhooks.GetDeliveries(ctx, id, webhookDeliveriesLimit)
+	return deliveries, err
+}
+
+func normalizeWebhook(webhook model.Webhook) model.Webhook {
+	webhook.Url = strings.TrimSpace(webhook.Url)
+	webhook.Mark = strings.TrimSpace(webhook.Mark)
+	webhook.Model = strings.TrimSpace(webhook.Model)
+	return webhook
+}
+
+// validateWebhook checks all fields of the normalized webhook and returns *model.ValidationError
+// with every failing field or nil if the webhook is valid
+func validateWebhook(webhook model.Webhook) error {
+	var fields []model.FieldError
+	if u, err := url.Parse(webhook.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fields = append(fields, model.FieldError{Field: "url", Reason: "must be absolute http or https url"})
+	} else if !isPublicHost(u.Hostname()) {
+		fields = append(fields, model.FieldError{Field: "url", Reason: "must not point to a local or private address"})
+	}
+	if len(webhook.Events) == 0 {
+		fields = append(fields, model.FieldError{Field: "events", Reason: "must not be empty"})
+	}
+	for _, event := range webhook.Events {
+		switch event {
+		case model.EventCarCreated, model.EventCarUpdated, model.EventCarDeleted, model.EventOwnerChanged:
+		default:
+			fields = append(fields, model.FieldError{Field: "events", Reason: "unknown event " + string(event)})
+		}
+	}
+	fields = appendNameErrors(fields, "filter.mark", webhook.Mark, false)
+	fields = appendNameErrors(fields, "filter.model", webhook.Model, false)
+
+	if len(fields) != 0 {
+		return &model.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// isPublicHost rejects local names and addresses which are not public, names resolved to such
+// addresses are rejected by the dispatcher on sending
+func isPublicHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return webhooks.IsPublicAddress(addr)
+	}
+	return true
+}
@@ -0,0 +1,533 @@
+// Package config loads configuration of the service from defaults, a YAML file, environment
+// variables and command line flags, every next source overrides the previous ones
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the service. Tags of leaf fields describe the sources: yaml is
+// the key in the file, env is the environment variable, flag is the command line flag and secret
+// marks values which are redacted on printing
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Postgres Postgres `yaml:"postgres"`
+	Api      Api      `yaml:"api"`
+	Log      Log      `yaml:"log"`
+	Import   Import   `yaml:"import"`
+	Outbox   Outbox   `yaml:"outbox"`
+	Refresh  Refresh  `yaml:"refresh"`
+	Cache    Cache    `yaml:"cache"`
+}
+
+type Server struct {
+	Addr            string        `yaml:"addr" env:"SERVER_ADDR" flag:"server-addr" usage:"address of HTTP server"`
+	GRPCAddr        string        `yaml:"grpcAddr" env:"GRPC_ADDR" flag:"grpc-addr" usage:"address of gRPC server"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SERVER_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"time to finish active requests on shutdown"`
+	// ReadTimeout limits reading of the whole request and WriteTimeout the time from the end of
+	// reading to the end of the response, IdleTimeout limits waiting for the next request of
+	// keep-alive connections
+	ReadTimeout  time.Duration `yaml:"readTimeout" env:"SERVER_READ_TIMEOUT" flag:"read-timeout" usage:"maximum time to read HTTP request"`
+	WriteTimeout time.Duration `yaml:"writeTimeout" env:"SERVER_WRITE_TIMEOUT" flag:"write-timeout" usage:"maximum time to write HTTP response"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout" env:"SERVER_IDLE_TIMEOUT" flag:"idle-timeout" usage:"maximum time to wait for the next request on keep-alive connection"`
+	// RequestTimeout is the deadline of handling of requests, LongRequestTimeout is used instead
+	// for adding cars, imports and exports of the catalog
+	RequestTimeout     time.Duration `yaml:"requestTimeout" env:"SERVER_REQUEST_TIMEOUT" flag:"request-timeout" usage:"deadline of handling of HTTP request"`
+	LongRequestTimeout time.Duration `yaml:"longRequestTimeout" env:"SERVER_LONG_REQUEST_TIMEOUT" flag:"long-request-timeout" usage:"deadline of adding cars, imports and exports"`
+	// MaxUploadSize limits bodies of imports instead of MaxBodySize, MaxBatchSize limits regNums
+	// added by one request, imports are not limited as they run in the background
+	MaxBodySize   int64 `yaml:"maxBodySize" env:"SERVER_MAX_BODY_SIZE" flag:"max-body-size" usage:"maximum size of HTTP request body in bytes"`
+	MaxUploadSize int64 `yaml:"maxUploadSize" env:"SERVER_MAX_UPLOAD_SIZE" flag:"max-upload-size" usage:"maximum size of HTTP request body of imports in bytes"`
+	MaxBatchSize  int   `yaml:"maxBatchSize" env:"SERVER_MAX_BATCH_SIZE" flag:"max-batch-size" usage:"maximum number of regNums added by one request"`
+	// TrustedProxies are addresses or CIDRs of proxies which set X-Forwarded-For, the address of
+	// the connection is the address of the client if it is empty
+	TrustedProxies []string  `yaml:"trustedProxies" env:"SERVER_TRUSTED_PROXIES" flag:"trusted-proxies" usage:"comma-separated addresses or CIDRs of proxies setting X-Forwarded-For"`
+	RateLimit      RateLimit `yaml:"rateLimit"`
+	// IdempotencyTTL is the time while responses of requests with Idempotency-Key are replayed
+	IdempotencyTTL time.Duration `yaml:"idempotencyTTL" env:"SERVER_IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"time while responses to requests with Idempotency-Key are replayed"`
+	// CacheMaxAge is max-age of Cache-Control of cars, clients revalidate them with ETags on every
+	// request if it is zero
+	CacheMaxAge time.Duration `yaml:"cacheMaxAge" env:"SERVER_CACHE_MAX_AGE" flag:"cache-max-age" usage:"time while clients may use cars without revalidation"`
+	CORS        CORS          `yaml:"cors"`
+	TLS         TLS           `yaml:"tls"`
+}
+
+// CORS allows browsers to call the API from AllowedOrigins, * allows any origin but can't be used
+// with AllowCredentials. CORS headers are not sent if AllowedOrigins is empty
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowedOrigins" env:"SERVER_CORS_ALLOWED_ORIGINS" flag:"cors-allowed-origins" usage:"comma-separated origins allowed to call the API, e.g. https://dashboard.example.com"`
+	AllowedMethods   []string      `yaml:"allowedMethods" env:"SERVER_CORS_ALLOWED_METHODS" flag:"cors-allowed-methods" usage:"comma-separated methods allowed for other origins"`
+	AllowedHeaders   []string      `yaml:"allowedHeaders" env:"SERVER_CORS_ALLOWED_HEADERS" flag:"cors-allowed-headers" usage:"comma-separated request headers allowed for other origins"`
+	AllowCredentials bool          `yaml:"allowCredentials" env:"SERVER_CORS_ALLOW_CREDENTIALS" flag:"cors-allow-credentials" usage:"allow other origins to send cookies and client certificates"`
+	MaxAge           time.Duration `yaml:"maxAge" env:"SERVER_CORS_MAX_AGE" flag:"cors-max-age" usage:"time while browsers cache preflight responses"`
+}
+
+// TLS makes the HTTP server serve HTTPS if CertFile and KeyFile are set, they are loaded again
+// when the files are changed. ClientAuth is none, optional to verify client certificates if they
+// are presented or require to reject clients without them, ClientCAFile verifies them
+type TLS struct {
+	CertFile     string `yaml:"certFile" env:"SERVER_TLS_CERT_FILE" flag:"tls-cert-file" usage:"file of the server certificate chain"`
+	KeyFile      string `yaml:"keyFile" env:"SERVER_TLS_KEY_FILE" flag:"tls-key-file" usage:"file of the server private key"`
+	ClientAuth   string `yaml:"clientAuth" env:"SERVER_TLS_CLIENT_AUTH" flag:"tls-client-auth" usage:"client certificates: none, optional or require"`
+	ClientCAFile string `yaml:"clientCAFile" env:"SERVER_TLS_CLIENT_CA_FILE" flag:"tls-client-ca-file" usage:"file of CA certificates of clients"`
+}
+
+// RateLimit limits requests of every client in fixed windows of Period, clients sending one of Keys
+// in KeyHeader have budgets of their keys and the rest of clients budgets of their IPs. BulkLimit
+// applies to adding and importing of cars and ReadLimit to the rest of routes, zero limit disables
+// its budget. Store is memory or postgres to share counters between instances
+type RateLimit struct {
+	Store     string        `yaml:"store" env:"RATE_LIMIT_STORE" flag:"rate-limit-store" usage:"storage of rate limit counters: memory or postgres"`
+	KeyHeader string        `yaml:"keyHeader" env:"RATE_LIMIT_KEY_HEADER" flag:"rate-limit-key-header" usage:"header with API key identifying clients"`
+	Keys      []string      `yaml:"keys" env:"RATE_LIMIT_KEYS" secret:"true"`
+	Period    time.Duration `yaml:"period" env:"RATE_LIMIT_PERIOD" flag:"rate-limit-period" usage:"window of rate limits"`
+	ReadLimit int           `yaml:"readLimit" env:"RATE_LIMIT_READ" flag:"rate-limit-read" usage:"requests per window except bulk inserts, 0 disables the limit"`
+	BulkLimit int           `yaml:"bulkLimit" env:"RATE_LIMIT_BULK" flag:"rate-limit-bulk" usage:"bulk inserts per window, 0 disables the limit"`
+}
+
+// Postgres configures the pool of connections, DSN replaces all connection fields from Host to
+// PassFile if it is set, pool settings, ApplicationName and StatementTimeout are applied to it too
+type Postgres struct {
+	DSN      string `yaml:"dsn" env:"POSTGRES_DB_DSN" secret:"true"`
+	Host     string `yaml:"host" env:"POSTGRES_DB_HOST" flag:"db-host" usage:"host of postgres"`
+	Port     int    `yaml:"port" env:"POSTGRES_DB_PORT" flag:"db-port" usage:"port of postgres"`
+	Name     string `yaml:"name" env:"POSTGRES_DB_NAME" flag:"db-name" usage:"name of the database"`
+	Username string `yaml:"username" env:"POSTGRES_DB_USERNAME" flag:"db-username" usage:"user of the database"`
+	Password string `yaml:"password" env:"POSTGRES_DB_PASSWORD" secret:"true"`
+	SSLMode  string `yaml:"sslMode" env:"POSTGRES_DB_SSLMODE" flag:"db-sslmode" usage:"sslmode of connections"`
+	// SSLRootCert is the custom CA verifying the server in verify-ca and verify-full modes
+	SSLRootCert string `yaml:"sslRootCert" env:"POSTGRES_DB_SSLROOTCERT" flag:"db-sslrootcert" usage:"file of CA certificates of the server"`
+	SSLCert     string `yaml:"sslCert" env:"POSTGRES_DB_SSLCERT" flag:"db-sslcert" usage:"file of client certificate"`
+	SSLKey      string `yaml:"sslKey" env:"POSTGRES_DB_SSLKEY" flag:"db-sslkey" usage:"file of client private key"`
+	// PassFile is used to look up the password if Password is empty
+	PassFile string `yaml:"passFile" env:"PGPASSFILE" flag:"db-passfile" usage:"password file in the format of .pgpass"`
+
+	ApplicationName  string        `yaml:"applicationName" env:"POSTGRES_DB_APPLICATION_NAME" flag:"db-application-name" usage:"application_name of connections"`
+	StatementTimeout time.Duration `yaml:"statementTimeout" env:"POSTGRES_DB_STATEMENT_TIMEOUT" flag:"db-statement-timeout" usage:"statement_timeout of connections, 0 disables it"`
+
+	MaxConns          int32         `yaml:"maxConns" env:"POSTGRES_DB_MAX_CONNS" flag:"db-max-conns" usage:"maximum size of the pool"`
+	MinConns          int32         `yaml:"minConns" env:"POSTGRES_DB_MIN_CONNS" flag:"db-min-conns" usage:"minimum number of idle connections"`
+	MaxConnLifetime   time.Duration `yaml:"maxConnLifetime" env:"POSTGRES_DB_MAX_CONN_LIFETIME" flag:"db-max-conn-lifetime" usage:"time after which a connection is closed"`
+	MaxConnIdleTime   time.Duration `yaml:"maxConnIdleTime" env:"POSTGRES_DB_MAX_CONN_IDLE_TIME" flag:"db-max-conn-idle-time" usage:"time after which an idle connection is closed"`
+	HealthCheckPeriod time.Duration `yaml:"healthCheckPeriod" env:"POSTGRES_DB_HEALTH_CHECK_PERIOD" flag:"db-health-check-period" usage:"period of checking idle connections"`
+
+	ConnectTimeout time.Duration `yaml:"connectTimeout" env:"POSTGRES_DB_CONNECT_TIMEOUT" flag:"db-connect-timeout" usage:"timeout of a single attempt to connect"`
+	StartupTimeout time.Duration `yaml:"startupTimeout" env:"POSTGRES_DB_STARTUP_TIMEOUT" flag:"db-startup-timeout" usage:"time to wait for postgres on startup"`
+
+	// Migrate applies new migrations on startup, otherwise the server does not start until they
+	// are applied by carsctl migrate
+	Migrate bool `yaml:"migrate" env:"POSTGRES_DB_MIGRATE" flag:"db-migrate" usage:"apply new migrations on startup"`
+
+	// Replicas are DSNs of read replicas getting the same pool settings, in the environment
+	// variable they are separated by commas
+	Replicas             []string      `yaml:"replicas" env:"POSTGRES_DB_REPLICAS" secret:"true"`
+	ReplicaCheckInterval time.Duration `yaml:"replicaCheckInterval" env:"POSTGRES_DB_REPLICA_CHECK_INTERVAL" flag:"db-replica-check-interval" usage:"interval of health checks of replicas"`
+}
+
+// Api is the outer API providing data of cars by their regNums, Providers replace Addr if they
+// are set, they are configured only in the file
+type Api struct {
+	Addr    string        `yaml:"addr" env:"API_ADDR" flag:"api-addr" usage:"url of the outer API"`
+	Timeout time.Duration `yaml:"timeout" env:"API_TIMEOUT" flag:"api-timeout" usage:"timeout of requests to the outer API"`
+	// Mode is priority to query providers one by one until all fields of the car are found or
+	// parallel to query all of them at once, fields of earlier providers win in both modes
+	Mode      string     `yaml:"mode" env:"API_MODE" flag:"api-mode" usage:"querying of providers: priority or parallel"`
+	Auth      Auth       `yaml:"auth"`
+	Providers []Provider `yaml:"providers"`
+}
+
+// Auth is the authentication of requests to the outer API, Type is empty for none, apiKey to send
+// APIKey in APIKeyHeader, oauth2 to get tokens of the client credentials grant from TokenURL or
+// mtls to present the client certificate. Tokens are requested again RefreshBefore their expiry,
+// a minute before it if RefreshBefore is zero. CAFile verifies the server with any type.
+// Environment variables and flags set only Auth of Api, providers are configured in the file
+type Auth struct {
+	Type          string        `yaml:"type" env:"API_AUTH_TYPE" flag:"api-auth-type" usage:"authentication of requests to the outer API: apiKey, oauth2, mtls or empty"`
+	APIKeyHeader  string        `yaml:"apiKeyHeader" env:"API_AUTH_API_KEY_HEADER" flag:"api-auth-api-key-header" usage:"header with the API key, X-Api-Key if empty"`
+	APIKey        string        `yaml:"apiKey" env:"API_AUTH_API_KEY" secret:"true"`
+	TokenURL      string        `yaml:"tokenUrl" env:"API_AUTH_TOKEN_URL" flag:"api-auth-token-url" usage:"url of OAuth2 tokens"`
+	ClientID      string        `yaml:"clientId" env:"API_AUTH_CLIENT_ID" flag:"api-auth-client-id" usage:"OAuth2 client id"`
+	ClientSecret  string        `yaml:"clientSecret" env:"API_AUTH_CLIENT_SECRET" secret:"true"`
+	Scopes        []string      `yaml:"scopes" env:"API_AUTH_SCOPES" flag:"api-auth-scopes" usage:"comma-separated OAuth2 scopes"`
+	RefreshBefore time.Duration `yaml:"refreshBefore" env:"API_AUTH_REFRESH_BEFORE" flag:"api-auth-refresh-before" usage:"time before expiry of OAuth2 token when it is requested again, 1m if 0"`
+	CertFile      string        `yaml:"certFile" env:"API_AUTH_CERT_FILE" flag:"api-auth-cert-file" usage:"file of client certificate for mtls"`
+	KeyFile       string        `yaml:"keyFile" env:"API_AUTH_KEY_FILE" flag:"api-auth-key-file" usage:"file of client private key for mtls"`
+	CAFile        string        `yaml:"caFile" env:"API_AUTH_CA_FILE" flag:"api-auth-ca-file" usage:"file of CA certificates of the outer API"`
+}
+
+// Provider is one of outer APIs, Name is recorded as the source of fields it supplies and
+// Timeout of Api is used if Timeout is zero
+type Provider struct {
+	Name    string        `yaml:"name"`
+	Addr    string        `yaml:"addr"`
+	Timeout time.Duration `yaml:"timeout"`
+	// QueryParam is the name of the query parameter with the regNum, regNum if it is empty
+	QueryParam string `yaml:"queryParam"`
+	// Headers are added to every request, e.g. the token of the provider
+	Headers map[string]string `yaml:"headers" secret:"true"`
+	Mapping Mapping           `yaml:"mapping"`
+	Auth    Auth              `yaml:"auth"`
+}
+
+// Mapping is the schema of the response of the provider, fields are paths of values in JSON like
+// $.person.name or documents[0].number, empty fields have paths of the original outer API.
+// Numbers and booleans are read as strings and the year may be a numeric string
+type Mapping struct {
+	RegNum          string `yaml:"regNum"`
+	Mark            string `yaml:"mark"`
+	Model           string `yaml:"model"`
+	Year            string `yaml:"year"`
+	OwnerName       string `yaml:"ownerName"`
+	OwnerSurname    string `yaml:"ownerSurname"`
+	OwnerPatronymic string `yaml:"ownerPatronymic"`
+}
+
+type Log struct {
+	Level string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"minimum level of logs: debug, info, error or fatal"`
+}
+
+type Import struct {
+	Workers int `yaml:"workers" env:"IMPORT_WORKERS" flag:"import-workers" usage:"number of concurrent workers of import jobs"`
+}
+
+// Outbox configures the sink of change events, events are kept in the outbox if Sink is empty
+type Outbox struct {
+	Sink     string `yaml:"sink" env:"OUTBOX_SINK" flag:"outbox-sink" usage:"sink of change events: http, file or empty"`
+	SinkURL  string `yaml:"sinkUrl" env:"OUTBOX_SINK_URL" flag:"outbox-sink-url" usage:"url of http sink"`
+	SinkFile string `yaml:"sinkFile" env:"OUTBOX_SINK_FILE" flag:"outbox-sink-file" usage:"file of file sink"`
+}
+
+// Refresh configures the background re-sync of cars with providers, changes of stale cars are
+// applied at once if AutoApply is set, otherwise they are queued for review
+type Refresh struct {
+	Interval  time.Duration `yaml:"interval" env:"REFRESH_INTERVAL" flag:"refresh-interval" usage:"interval of checks for stale cars, 0 disables them"`
+	MaxAge    time.Duration `yaml:"maxAge" env:"REFRESH_MAX_AGE" flag:"refresh-max-age" usage:"time after which data of the car is stale"`
+	BatchSize int           `yaml:"batchSize" env:"REFRESH_BATCH_SIZE" flag:"refresh-batch-size" usage:"maximum number of cars refreshed per check"`
+	// QuietHours is the local time of day when providers are not queried, e.g. 08:00-20:00,
+	// it may wrap around midnight
+	QuietHours string `yaml:"quietHours" env:"REFRESH_QUIET_HOURS" flag:"refresh-quiet-hours" usage:"local time when stale cars are not refreshed, e.g. 08:00-20:00"`
+	AutoApply  bool   `yaml:"autoApply" env:"REFRESH_AUTO_APPLY" flag:"refresh-auto-apply" usage:"apply changes of stale cars instead of queueing them for review"`
+}
+
+// Cache configures the in-process cache of cars read by id, zero Size disables it. Cars changed by
+// other instances are seen after TTL
+type Cache struct {
+	Size int           `yaml:"size" env:"CACHE_SIZE" flag:"cache-size" usage:"maximum number of cached cars, 0 disables the cache"`
+	TTL  time.Duration `yaml:"ttl" env:"CACHE_TTL" flag:"cache-ttl" usage:"time while cached cars are used"`
+}
+
+// Default returns configuration used for values missing in all sources
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:               "localhost:8080",
+			GRPCAddr:           "localhost:9090",
+			ShutdownTimeout:    30 * time.Second,
+			ReadTimeout:        time.Minute,
+			WriteTimeout:       3 * time.Minute,
+			IdleTimeout:        2 * time.Minute,
+			RequestTimeout:     30 * time.Second,
+			LongRequestTimeout: 2 * time.Minute,
+			MaxBodySize:        1 << 20,
+			MaxUploadSize:      32 << 20,
+			MaxBatchSize:       100,
+			RateLimit: RateLimit{
+				Store:     "memory",
+				KeyHeader: "X-Api-Key",
+				Period:    time.Minute,
+				ReadLimit: 600,
+				BulkLimit: 20,
+			},
+			IdempotencyTTL: 24 * time.Hour,
+			CORS: CORS{
+				AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+				AllowedHeaders: []string{"Content-Type", "X-Api-Key", "Idempotency-Key", "If-None-Match"},
+				MaxAge:         10 * time.Minute,
+			},
+			TLS: TLS{
+				ClientAuth: "none",
+			},
+		},
+		Postgres: Postgres{
+			Host:              "localhost",
+			Port:              5432,
+			Name:              "cars-db",
+			SSLMode:           "disable",
+			ApplicationName:   "cars-service",
+			MaxConns:          10,
+			MinConns:          0,
+			MaxConnLifetime:   time.Hour,
+			MaxConnIdleTime:   30 * time.Minute,
+			HealthCheckPeriod: time.Minute,
+			ConnectTimeout:    5 * time.Second,
+			StartupTimeout:    30 * time.Second,
+
+			ReplicaCheckInterval: 5 * time.Second,
+		},
+		Api: Api{
+			Timeout: 10 * time.Second,
+			Mode:    "priority",
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Import: Import{
+			Workers: 8,
+		},
+		Refresh: Refresh{
+			Interval:  time.Hour,
+			MaxAge:    30 * 24 * time.Hour,
+			BatchSize: 100,
+		},
+		Cache: Cache{
+			TTL: time.Minute,
+		},
+	}
+}
+
+// Validate checks all fields and returns error listing every invalid one
+func (c Config) Validate() error {
+	var errs []string
+	check := func(ok bool, field string, reason string) {
+		if !ok {
+			errs = append(errs, field+": "+reason)
+		}
+	}
+
+	check(isHostPort(c.Server.Addr), "server.addr", "must be host:port")
+	check(isHostPort(c.Server.GRPCAddr), "server.grpcAddr", "must be host:port")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout", "must be positive")
+	check(c.Server.ReadTimeout > 0, "server.readTimeout", "must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idleTimeout", "must be positive")
+	check(c.Server.RequestTimeout > 0, "server.requestTimeout", "must be positive")
+	check(c.Server.LongRequestTimeout >= c.Server.RequestTimeout, "server.longRequestTimeout", "must not be less than requestTimeout")
+	// responses of requests hitting the deadline must still be written
+	check(c.Server.WriteTimeout > c.Server.LongRequestTimeout, "server.writeTimeout", "must be greater than longRequestTimeout")
+	check(c.Server.MaxBodySize > 0, "server.maxBodySize", "must be positive")
+	check(c.Server.MaxUploadSize > 0, "server.maxUploadSize", "must be positive")
+	check(c.Server.MaxBatchSize > 0, "server.maxBatchSize", "must be positive")
+	for _, proxy := range c.Server.TrustedProxies {
+		check(isIPOrCIDR(proxy), "server.trustedProxies", fmt.Sprintf("%q must be IP address or CIDR", proxy))
+	}
+	check(c.Server.RateLimit.Store == "memory" || c.Server.RateLimit.Store == "postgres", "server.rateLimit.store", "must be memory or postgres")
+	check(isHeaderName(c.Server.RateLimit.KeyHeader), "server.rateLimit.keyHeader", "must be valid header name")
+	check(c.Server.RateLimit.Period >= time.Second, "server.rateLimit.period", "must be at least 1s")
+	check(c.Server.RateLimit.ReadLimit >= 0, "server.rateLimit.readLimit", "must not be negative")
+	check(c.Server.RateLimit.BulkLimit >= 0, "server.rateLimit.bulkLimit", "must not be negative")
+	check(c.Server.IdempotencyTTL > 0, "server.idempotencyTTL", "must be positive")
+	check(c.Server.CacheMaxAge >= 0, "server.cacheMaxAge", "must not be negative")
+	for _, origin := range c.Server.CORS.AllowedOrigins {
+		check(origin == "*" || isOrigin(origin), "server.cors.allowedOrigins", fmt.Sprintf("%q must be * or scheme://host[:port]", origin))
+	}
+	check(!c.Server.CORS.AllowCredentials || !slices.Contains(c.Server.CORS.AllowedOrigins, "*"), "server.cors.allowCredentials", "must not be set if any origin is allowed")
+	for _, method := range c.Server.CORS.AllowedMethods {
+		check(isHeaderName(method), "server.cors.allowedMethods", fmt.Sprintf("%q must be method name", method))
+	}
+	for _, header := range c.Server.CORS.AllowedHeaders {
+		check(isHeaderName(header), "server.cors.allowedHeaders", fmt.Sprintf("%q must be valid header name", header))
+	}
+	check(c.Server.CORS.MaxAge >= 0, "server.cors.maxAge", "must not be negative")
+	tls := c.Server.TLS
+	if tls.CertFile != "" || tls.KeyFile != "" {
+		check(isFile(tls.CertFile), "server.tls.certFile", "must be existing file if keyFile is set")
+		check(isFile(tls.KeyFile), "server.tls.keyFile", "must be existing file if certFile is set")
+	}
+	switch tls.ClientAuth {
+	case "none":
+	case "optional", "require":
+		check(tls.CertFile != "", "server.tls.clientAuth", "requires certFile and keyFile")
+		check(isFile(tls.ClientCAFile), "server.tls.clientCAFile", "must be existing file if client certificates are verified")
+	default:
+		check(false, "server.tls.clientAuth", "must be none, optional or require")
+	}
+
+	if c.Postgres.DSN == "" {
+		check(c.Postgres.Host != "", "postgres.host", "must not be empty")
+		check(c.Postgres.Port > 0 && c.Postgres.Port <= 65535, "postgres.port", "must be between 1 and 65535")
+		check(c.Postgres.Name != "", "postgres.name", "must not be empty")
+		check(c.Postgres.Username != "", "postgres.username", "must not be empty")
+		switch c.Postgres.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			check(false, "postgres.sslMode", "must be one of disable, allow, prefer, require, verify-ca, verify-full")
+		}
+		check(c.Postgres.SSLRootCert == "" || isFile(c.Postgres.SSLRootCert), "postgres.sslRootCert", "must be existing file")
+		check(c.Postgres.SSLCert == "" || isFile(c.Postgres.SSLCert), "postgres.sslCert", "must be existing file")
+		check(c.Postgres.SSLKey == "" || isFile(c.Postgres.SSLKey), "postgres.sslKey", "must be existing file")
+		check((c.Postgres.SSLCert == "") == (c.Postgres.SSLKey == ""), "postgres.sslKey", "must be set together with sslCert")
+	}
+	check(c.Postgres.StatementTimeout >= 0, "postgres.statementTimeout", "must not be negative")
+	check(c.Postgres.MaxConns > 0, "postgres.maxConns", "must be positive")
+	check(c.Postgres.MinConns >= 0 && c.Postgres.MinConns <= c.Postgres.MaxConns, "postgres.minConns", "must be between 0 and maxConns")
+	check(c.Postgres.MaxConnLifetime > 0, "postgres.maxConnLifetime", "must be positive")
+	check(c.Postgres.MaxConnIdleTime > 0, "postgres.maxConnIdleTime", "must be positive")
+	check(c.Postgres.HealthCheckPeriod > 0, "postgres.healthCheckPeriod", "must be positive")
+	check(c.Postgres.ConnectTimeout > 0, "postgres.connectTimeout", "must be positive")
+	check(c.Postgres.StartupTimeout >= c.Postgres.ConnectTimeout, "postgres.startupTimeout", "must not be less than connectTimeout")
+	check(c.Postgres.ReplicaCheckInterval > 0, "postgres.replicaCheckInterval", "must be positive")
+
+	if len(c.Api.Providers) == 0 {
+		check(isHTTPURL(c.Api.Addr), "api.addr", "must be absolute http or https url")
+	}
+	check(c.Api.Timeout > 0, "api.timeout", "must be positive")
+	check(c.Api.Mode == "priority" || c.Api.Mode == "parallel", "api.mode", "must be priority or parallel")
+	if len(c.Api.Providers) == 0 {
+		checkAuth(check, "api.auth.", c.Api.Auth)
+	}
+	names := make(map[string]bool, len(c.Api.Providers))
+	for i, p := range c.Api.Providers {
+		prefix := fmt.Sprintf("api.providers[%d].", i)
+		check(p.Name != "" && !names[p.Name], prefix+"name", "must be unique and not empty")
+		// the source of fields entered by users
+		check(p.Name != "manual", prefix+"name", "must not be manual")
+		check(isHTTPURL(p.Addr), prefix+"addr", "must be absolute http or https url")
+		check(p.Timeout >= 0, prefix+"timeout", "must not be negative")
+		headers := make([]string, 0, len(p.Headers))
+		for name := range p.Headers {
+			headers = append(headers, name)
+		}
+		sort.Strings(headers)
+		for _, name := range headers {
+			check(isHeaderName(name), prefix+"headers", fmt.Sprintf("%q must be valid header name", name))
+		}
+		for _, m := range []struct{ field, path string }{
+			{"regNum", p.Mapping.RegNum},
+			{"mark", p.Mapping.Mark},
+			{"model", p.Mapping.Model},
+			{"year", p.Mapping.Year},
+			{"ownerName", p.Mapping.OwnerName},
+			{"ownerSurname", p.Mapping.OwnerSurname},
+			{"ownerPatronymic", p.Mapping.OwnerPatronymic},
+		} {
+			check(m.path == "" || jsonPath.MatchString(m.path), prefix+"mapping."+m.field, "must be path like $.person.name or documents[0].number")
+		}
+		checkAuth(check, prefix+"auth.", p.Auth)
+		names[p.Name] = true
+	}
+
+	_, err := logrus.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level", "must be one of debug, info, error, fatal")
+
+	check(c.Import.Workers > 0, "import.workers", "must be positive")
+
+	switch c.Outbox.Sink {
+	case "":
+	case "http":
+		check(isHTTPURL(c.Outbox.SinkURL), "outbox.sinkUrl", "must be absolute http or https url for http sink")
+	case "file":
+		check(c.Outbox.SinkFile != "", "outbox.sinkFile", "must not be empty for file sink")
+	default:
+		check(false, "outbox.sink", "must be http, file or empty")
+	}
+
+	check(c.Refresh.Interval >= 0, "refresh.interval", "must not be negative")
+	check(c.Refresh.MaxAge > 0, "refresh.maxAge", "must be positive")
+	check(c.Refresh.BatchSize > 0, "refresh.batchSize", "must be positive")
+	_, _, err = ParseQuietHours(c.Refresh.QuietHours)
+	check(err == nil, "refresh.quietHours", "must be empty or HH:MM-HH:MM")
+
+	check(c.Cache.Size >= 0, "cache.size", "must not be negative")
+	check(c.Cache.TTL > 0, "cache.ttl", "must be positive")
+
+	if len(errs) != 0 {
+		return errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// checkAuth checks fields of a which are required by its type
+func checkAuth(check func(ok bool, field string, reason string), prefix string, a Auth) {
+	switch a.Type {
+	case "":
+	case "apiKey":
+		check(a.APIKey != "", prefix+"apiKey", "must not be empty for apiKey auth")
+		check(a.APIKeyHeader == "" || isHeaderName(a.APIKeyHeader), prefix+"apiKeyHeader", "must be valid header name")
+	case "oauth2":
+		check(isHTTPURL(a.TokenURL), prefix+"tokenUrl", "must be absolute http or https url for oauth2 auth")
+		check(a.ClientID != "", prefix+"clientId", "must not be empty for oauth2 auth")
+		check(a.RefreshBefore >= 0, prefix+"refreshBefore", "must not be negative")
+	case "mtls":
+		check(isFile(a.CertFile), prefix+"certFile", "must be existing file for mtls auth")
+		check(isFile(a.KeyFile), prefix+"keyFile", "must be existing file for mtls auth")
+	default:
+		check(false, prefix+"type", "must be apiKey, oauth2, mtls or empty")
+	}
+	check(a.CAFile == "" || isFile(a.CAFile), prefix+"caFile", "must be existing file")
+}
+
+// ParseQuietHours returns the start and the end of quiet hours as offsets from midnight, both are
+// zero if s is empty
+func ParseQuietHours(s string) (from time.Duration, to time.Duration, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, errors.New("quiet hours must be HH:MM-HH:MM")
+	}
+	if from, err = parseTimeOfDay(start); err != nil {
+		return 0, 0, err
+	}
+	if to, err = parseTimeOfDay(end); err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+// parseTimeOfDay returns HH:MM as the offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// jsonPath is the syntax of paths of Mapping, dot-separated keys each followed by optional indexes
+var jsonPath = regexp.MustCompile(`^(\$\.)?[^.\[\]]+(\[[0-9]+\])*(\.[^.\[\]]+(\[[0-9]+\])*)*$`)
+
+// isHeaderName reports whether s is a token of RFC 7230
+func isHeaderName(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return r > '~' || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+	}) < 0
+}
+
+func isIPOrCIDR(s string) bool {
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return true
+	}
+	return net.ParseIP(s) != nil
+}
+
+func isHostPort(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && port != ""
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// isOrigin reports whether s is the origin of browsers like https://example.com:8443
+func isOrigin(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
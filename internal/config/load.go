@@ -0,0 +1,219 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces values of secret fields on printing
+const redacted = "******"
+
+// field is a leaf field of Config with its sources
+type field struct {
+	path   string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// fields returns leaf fields of v which must be a pointer to struct
+func fields(v reflect.Value, prefix string) []field {
+	v = v.Elem()
+	var res []field
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		path := prefix + sf.Tag.Get("yaml")
+		if sf.Type.Kind() == reflect.Struct {
+			res = append(res, fields(v.Field(i).Addr(), path+".")...)
+			continue
+		}
+		res = append(res, field{
+			path:   path,
+			env:    sf.Tag.Get("env"),
+			flag:   sf.Tag.Get("flag"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return res
+}
+
+// Load registers flags of all fields and -config flag with the path of YAML file in flags,
+// parses args and returns defaults overridden by the file, environment variables and flags.
+// The result is not validated, so it can be printed before Validate
+func Load(flags *flag.FlagSet, args []string, defaults Config) (Config, error) {
+	cfg := defaults
+	all := fields(reflect.ValueOf(&cfg), "")
+
+	configPath := flags.String("config", "", "YAML file with configuration")
+	flagValues := make(map[string]string)
+	for _, f := range all {
+		if f.flag == "" {
+			continue
+		}
+		name := f.flag
+		flags.Func(name, fmt.Sprintf("%s (%s)", f.usage, f.env), func(s string) error {
+			flagValues[name] = s
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *configPath != "" {
+		if err := readFile(*configPath, &cfg); err != nil {
+			return Config{}, fmt.Errorf("unable to read config file: %w", err)
+		}
+	}
+
+	var errs []string
+	for _, f := range all {
+		if s, ok := os.LookupEnv(f.env); ok && f.env != "" {
+			if err := setValue(f.value, s); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", f.env, err))
+			}
+		}
+	}
+	for _, f := range all {
+		if s, ok := flagValues[f.flag]; ok {
+			if err := setValue(f.value, s); err != nil {
+				errs = append(errs, fmt.Sprintf("-%s: %v", f.flag, err))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return Config{}, errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+	return cfg, nil
+}
+
+// readFile decodes YAML file into cfg, unknown keys are reported as errors so typos are not ignored
+func readFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err = dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be integer")
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be boolean")
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var values []string
+		for _, value := range strings.Split(s, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		v.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Redacted returns YAML with all values of the configuration, secrets are replaced if they are set
+func (c Config) Redacted() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(redactedNode(reflect.ValueOf(c), false)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// redactedNode returns YAML node of the value of the field, the value is replaced if it is secret,
+// nested structs, items of lists and values of maps are secret if their field is secret. The
+// nodes have the types of the fields, so the printed config can be loaded back
+func redactedNode(v reflect.Value, secret bool) *yaml.Node {
+	switch {
+	case v.Kind() == reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: sf.Tag.Get("yaml")},
+				redactedNode(v.Field(i), secret || sf.Tag.Get("secret") == "true"))
+		}
+		return node
+	case v.Kind() == reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			node.Content = append(node.Content, redactedNode(v.Index(i), secret))
+		}
+		// empty lists are printed as [] rather than null
+		if v.Len() == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		return node
+	case v.Kind() == reflect.Map:
+		// keys are sorted, so the printed config is the same every time
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range keys {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key.String()},
+				redactedNode(v.MapIndex(key), secret))
+		}
+		return node
+	}
+
+	value := fmt.Sprint(v.Interface())
+	if secret && value != "" {
+		value = redacted
+	}
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if value == "" {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	return node
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRedactedLoadsBack(t *testing.T) {
+	cfg := Default()
+	cfg.Server.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+	cfg.Server.RateLimit.Keys = []string{"first-key", "second-key"}
+	cfg.Server.TLS.CertFile = "true"
+	cfg.Postgres.Name = "123"
+	cfg.Postgres.Password = "secret"
+	cfg.Postgres.Migrate = true
+	cfg.Api.Auth.Scopes = []string{"cars:read"}
+	cfg.Api.Providers = []Provider{{
+		Name:    "registry",
+		Addr:    "https://registry.example.com",
+		Timeout: 3 * time.Second,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	}}
+
+	out, err := cfg.Redacted()
+	if err != nil {
+		t.Fatalf("Redacted: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err = os.WriteFile(path, out, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path}, Config{})
+	if err != nil {
+		t.Fatalf("Load of printed config: %v\n%s", err, out)
+	}
+
+	// the loaded config is printed the same, secrets stay redacted
+	again, err := loaded.Redacted()
+	if err != nil {
+		t.Fatalf("Redacted: %v", err)
+	}
+	if string(again) != string(out) {
+		t.Errorf("printed loaded config:\n%s\nwant:\n%s", again, out)
+	}
+	if !reflect.DeepEqual(loaded.Server.TrustedProxies, cfg.Server.TrustedProxies) ||
+		!reflect.DeepEqual(loaded.Server.RateLimit.Keys, []string{redacted, redacted}) ||
+		len(loaded.Server.CORS.AllowedOrigins) != 0 {
+		t.Errorf("loaded lists %v %v %v, want %v, redacted keys and none", loaded.Server.TrustedProxies,
+			loaded.Server.RateLimit.Keys, loaded.Server.CORS.AllowedOrigins, cfg.Server.TrustedProxies)
+	}
+	if loaded.Server.TLS.CertFile != "true" || loaded.Postgres.Name != "123" || loaded.Postgres.Password != redacted ||
+		!loaded.Postgres.Migrate || loaded.Api.Providers[0].Timeout != 3*time.Second {
+		t.Errorf("loaded config %+v differs from printed:\n%s", loaded, out)
+	}
+}
@@ -1,13 +1,54 @@
 package model
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
 	ErrDuplicateRegNum = errors.New("duplicate registration number")
 	ErrInvalidInput    = errors.New("invalid input")
 	ErrValidation      = errors.New("validation error")
 	ErrCarNotFound     = errors.New("car not found")
+	ErrCarChanged      = errors.New("car is changed by another request")
 	ErrDatabaseError   = errors.New("database error")
 	ErrApiError        = errors.New("outer api error")
+	ErrApiUnavailable  = errors.New("outer api is unavailable")
 	ErrServiceError    = errors.New("unknown service error")
+	ErrJobNotFound     = errors.New("import job not found")
+	ErrJobFinished     = errors.New("import job is already finished")
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrOwnerNotFound   = errors.New("owner not found")
+	ErrRefreshNotFound = errors.New("refresh not found")
+	ErrRefreshResolved = errors.New("refresh is already resolved")
+	ErrBodyTooLarge    = errors.New("request body is too large")
+	ErrBatchTooLarge   = errors.New("too many registration numbers")
+	ErrRateLimited     = errors.New("too many requests")
+	ErrDeadline        = errors.New("request deadline exceeded")
+
+	ErrIdempotencyMismatch   = errors.New("idempotency key is used with another request")
+	ErrIdempotencyInProgress = errors.New("request with the idempotency key is in progress")
 )
+
+// FieldError describes why a single field of the car failed validation
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+// ValidationError contains every failing field of the car, it matches ErrValidation with errors.Is
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		reasons[i] = f.Field + ": " + f.Reason
+	}
+	return ErrValidation.Error() + ": " + strings.Join(reasons, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
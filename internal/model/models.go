@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type Car struct {
 	Id     uint64
 	RegNum string
@@ -7,8 +9,24 @@ type Car struct {
 	Model  string
 	Year   int
 	Owner
+	// Sources maps fields of the car to the names of providers which supplied them, it is empty
+	// for cars added before providers were tracked
+	Sources map[string]string
+	// Version starts from 1 and is incremented on every change of the car
+	Version uint64
 }
 
+// fields of the car with tracked sources, the owner is supplied as a whole
+const (
+	FieldMark  = "mark"
+	FieldModel = "model"
+	FieldYear  = "year"
+	FieldOwner = "owner"
+)
+
+// SourceManual is the source of fields entered by users instead of providers
+const SourceManual = "manual"
+
 type Owner struct {
 	Id         uint64
 	Name       string
@@ -35,4 +53,167 @@ type Filter struct {
 	ByOwnerName       bool
 	ByOwnerSurname    bool
 	ByOwnerPatronymic bool
+
+	// SortBy is a field cars are ordered by, cars are ordered by id if it is empty
+	SortBy   CarSortField
+	SortDesc bool
+}
+
+type CarSortField string
+
+const (
+	SortById     CarSortField = "id"
+	SortByRegNum CarSortField = "regNum"
+	SortByMark   CarSortField = "mark"
+	SortByModel  CarSortField = "model"
+	SortByYear   CarSortField = "year"
+)
+
+// Ownership is a period when the car belonged to the owner, Till is zero for the current owner
+type Ownership struct {
+	CarId uint64
+	Owner Owner
+	From  time.Time
+	Till  time.Time
+}
+
+type ImportStatus string
+
+const (
+	ImportPending   ImportStatus = "pending"
+	ImportRunning   ImportStatus = "running"
+	ImportDone      ImportStatus = "done"
+	ImportCancelled ImportStatus = "cancelled"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportJob is an asynchronous bulk import of cars by their regNums
+type ImportJob struct {
+	Id        uint64
+	Status    ImportStatus
+	Total     int
+	Processed int
+	Succeeded int
+	Failed    int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Items     []ImportItem
+}
+
+// ImportItem is a result of importing a single regNum, its status is one of
+// ImportPending, ImportDone or ImportFailed
+type ImportItem struct {
+	Id     uint64
+	RegNum string
+	Status ImportStatus
+	CarId  uint64
+	Error  string
+	// Attempts is a number of attempts failed with temporary errors
+	Attempts int
+}
+
+type EventType string
+
+const (
+	EventCarCreated   EventType = "car.created"
+	EventCarUpdated   EventType = "car.updated"
+	EventCarDeleted   EventType = "car.deleted"
+	EventOwnerChanged EventType = "owner.changed"
+)
+
+// Event is a change of the car published to downstream systems, Car is the state of the car
+// after the change or before deletion, PreviousOwner is set only for EventOwnerChanged
+type Event struct {
+	Id            uint64
+	Type          EventType
+	CarId         uint64
+	Car           Car
+	PreviousOwner *Owner
+	CreatedAt     time.Time
+	// Attempts is a number of failed deliveries of the event from the outbox
+	Attempts int
+}
+
+// Webhook is a subscription of the partner to events, Mark and Model filter events by the car
+// if they are not empty
+type Webhook struct {
+	Id        uint64
+	Url       string
+	Events    []EventType
+	Mark      string
+	Model     string
+	Secret    string
+	Active    bool
+	Failures  int
+	CreatedAt time.Time
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an attempt to send the event to the webhook, StatusCode and Error
+// describe the last attempt
+type WebhookDelivery struct {
+	Id            uint64
+	WebhookId     uint64
+	Event         Event
+	Status        DeliveryStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	StatusCode    int
+	Error         string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type RefreshStatus string
+
+const (
+	// RefreshUnchanged is the status of the refresh which found no changes, it is not saved
+	RefreshUnchanged  RefreshStatus = "unchanged"
+	RefreshPending    RefreshStatus = "pending"
+	RefreshApplied    RefreshStatus = "applied"
+	RefreshRejected   RefreshStatus = "rejected"
+	RefreshSuperseded RefreshStatus = "superseded"
+)
+
+// FieldChange is a difference between the stored field of the car and the data of providers,
+// values of the owner are the full name
+type FieldChange struct {
+	Field  string
+	Old    string
+	New    string
+	Source string
+}
+
+// Refresh is a result of querying providers again for the stored car, Car is the car with all
+// changes applied. Pending refreshes wait for review and are superseded by the next pending
+// refresh of the same car
+type Refresh struct {
+	Id         uint64
+	CarId      uint64
+	Status     RefreshStatus
+	Changes    []FieldChange
+	Car        Car
+	CreatedAt  time.Time
+	ResolvedAt time.Time
+}
+
+// IdempotentRequest is a mutating request with Idempotency-Key, Fingerprint is the hash of the
+// request and the response is set after the request is completed. Repeats of the request get
+// the saved response until ExpiresAt
+type IdempotentRequest struct {
+	Key         string
+	Fingerprint string
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
 }
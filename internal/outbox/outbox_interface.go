@@ -0,0 +1,59 @@
+package outbox
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Sink is an interface of the destination of events, adapters for message brokers
+// should implement it as well
+type Sink interface {
+	// Deliver sends the event, the event is sent again later if an error is returned,
+	// so the receiver should deduplicate events by their id
+	Deliver(ctx context.Context, event model.Event) error
+}
+
+// Relay is an interface of the background process which delivers events from the outbox to the Sink
+type Relay interface {
+	// Run delivers events until ctx is done, events of the same car are delivered in order
+	// of their creation and at least once. Failed events are retried with growing delays and
+	// dead-lettered after many attempts, relays of several instances share the outbox
+	Run(ctx context.Context)
+}
+
+// NewRelay creates Relay implementation which checks the outbox with the given interval
+func NewRelay(r repo.OutboxRepo, sink Sink, logs logger.Logger, interval time.Duration) Relay {
+	return &relayImpl{
+		OutboxRepo: r,
+		Sink:       sink,
+		logs:       logs,
+		interval:   interval,
+		now:        time.Now,
+	}
+}
+
+// NewHTTPSink creates Sink which sends events as JSON in POST requests to the webhook url
+func NewHTTPSink(url string) Sink {
+	return &httpSink{
+		url: url,
+		Client: http.Client{
+			Timeout: httpSinkTimeout,
+		},
+	}
+}
+
+// NewFileSink creates Sink which appends events as JSON lines to the file, it is useful for testing
+func NewFileSink(path string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{
+		f: f,
+	}, nil
+}
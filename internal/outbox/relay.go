@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// relayBatchSize is a number of events claimed from the outbox at once
+	relayBatchSize = 100
+
+	// relayLease is a time for delivering the claimed batch, events which are not delivered by then
+	// may be claimed by other relays
+	relayLease = 5 * time.Minute
+
+	// relayRetryBackoff is a delay before the first retry of the event, it is doubled after every
+	// failed attempt up to relayMaxBackoff, the event is dead-lettered after relayMaxAttempts
+	relayRetryBackoff = time.Second
+	relayMaxBackoff   = 10 * time.Minute
+	relayMaxAttempts  = 20
+)
+
+type relayImpl struct {
+	repo.OutboxRepo
+	Sink
+	logs     logger.Logger
+	interval time.Duration
+	now      func() time.Time
+}
+
+func (r *relayImpl) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// the outbox is read again without waiting while events are delivered, the next events
+		// of their cars may be claimed now
+		if r.deliverBatch(ctx) != 0 && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverBatch delivers claimed events and returns the number of delivered ones. The batch has
+// at most one event of every car, so a failing car does not hold back events of other cars
+func (r *relayImpl) deliverBatch(ctx context.Context) int {
+	ctx, cancel := context.WithTimeout(ctx, relayLease)
+	defer cancel()
+
+	events, err := r.ClaimEvents(ctx, relayBatchSize, r.now().Add(relayLease))
+	if err != nil {
+		r.logError(0, err)
+		return 0
+	}
+
+	delivered := 0
+	for _, event := range events {
+		// events left claimed are delivered after the lease
+		if ctx.Err() != nil {
+			return delivered
+		}
+
+		if err = r.Deliver(ctx, event); err != nil {
+			r.logError(event.Id, err)
+			if event.Attempts+1 >= relayMaxAttempts {
+				err = r.MarkDead(ctx, event.Id)
+			} else {
+				err = r.MarkFailed(ctx, event.Id, r.now().Add(relayBackoff(event.Attempts)))
+			}
+			if err != nil {
+				r.logError(event.Id, err)
+			}
+			continue
+		}
+		if err = r.MarkDelivered(ctx, event.Id); err != nil {
+			// the event will be delivered again, it is allowed by at least once guarantee
+			r.logError(event.Id, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// relayBackoff returns the delay before the retry of the event failed the given number of times
+// before
+func relayBackoff(attempts int) time.Duration {
+	backoff := relayRetryBackoff
+	for i := 0; i < attempts && backoff < relayMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, relayMaxBackoff)
+}
+
+func (r *relayImpl) logError(eventId uint64, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	r.logs.Error(logger.Fields{
+		"Method":  "OutboxRelay",
+		"EventId": eventId,
+	}, err.Error())
+}
@@ -0,0 +1,99 @@
+package outbox
+
+import (
+	"cars-service/internal/model"
+	"cars-service/pkg/logger"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// outboxRepo returns the same claimed events and records results of their delivery
+type outboxRepo struct {
+	events    []model.Event
+	delivered []uint64
+	failed    map[uint64]time.Time
+	dead      []uint64
+}
+
+func (r *outboxRepo) ClaimEvents(context.Context, uint, time.Time) ([]model.Event, error) {
+	return r.events, nil
+}
+
+func (r *outboxRepo) MarkDelivered(_ context.Context, id uint64) error {
+	r.delivered = append(r.delivered, id)
+	return nil
+}
+
+func (r *outboxRepo) MarkFailed(_ context.Context, id uint64, retryAt time.Time) error {
+	r.failed[id] = retryAt
+	return nil
+}
+
+func (r *outboxRepo) MarkDead(_ context.Context, id uint64) error {
+	r.dead = append(r.dead, id)
+	return nil
+}
+
+func TestRelayRetries(t *testing.T) {
+	logs, err := logger.NewWithLevel("fatal")
+	if err != nil {
+		t.Fatalf("NewWithLevel: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := &outboxRepo{
+		events: []model.Event{
+			{Id: 1, CarId: 1, Attempts: 3},
+			{Id: 2, CarId: 2},
+			{Id: 3, CarId: 3, Attempts: relayMaxAttempts - 1},
+			{Id: 4, CarId: 4},
+		},
+		failed: make(map[uint64]time.Time),
+	}
+	// the receiver of events of cars 1 and 3 is down
+	sink := sinkFunc(func(_ context.Context, event model.Event) error {
+		if event.CarId == 1 || event.CarId == 3 {
+			return errors.New("receiver is down")
+		}
+		return nil
+	})
+	relay := NewRelay(r, sink, logs, time.Second).(*relayImpl)
+	relay.now = func() time.Time { return now }
+
+	// events of other cars are delivered while the failing ones wait for their retries
+	if n := relay.deliverBatch(context.Background()); n != 2 {
+		t.Errorf("deliverBatch = %d, want 2", n)
+	}
+	if len(r.delivered) != 2 || r.delivered[0] != 2 || r.delivered[1] != 4 {
+		t.Errorf("delivered %v, want [2 4]", r.delivered)
+	}
+	if want := now.Add(8 * time.Second); !r.failed[1].Equal(want) || len(r.failed) != 1 {
+		t.Errorf("failed %v, want event 1 retried at %v", r.failed, want)
+	}
+	if len(r.dead) != 1 || r.dead[0] != 3 {
+		t.Errorf("dead %v, want [3]", r.dead)
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		0:  time.Second,
+		1:  2 * time.Second,
+		5:  32 * time.Second,
+		9:  512 * time.Second,
+		10: relayMaxBackoff,
+		19: relayMaxBackoff,
+	} {
+		if got := relayBackoff(attempts); got != want {
+			t.Errorf("relayBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+// sinkFunc is Sink delivering events with the function
+type sinkFunc func(ctx context.Context, event model.Event) error
+
+func (f sinkFunc) Deliver(ctx context.Context, event model.Event) error {
+	return f(ctx, event)
+}
@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"bytes"
+	"cars-service/internal/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// httpSinkTimeout is a timeout of a single request to the webhook
+const httpSinkTimeout = 10 * time.Second
+
+type httpSink struct {
+	url string
+	http.Client
+}
+
+func (s *httpSink) Deliver(ctx context.Context, event model.Event) error {
+	body, err := EncodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatUint(event.Id, 10))
+	req.Header.Set("X-Event-Type", string(event.Type))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type fileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (s *fileSink) Deliver(_ context.Context, event model.Event) error {
+	line, err := EncodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(line, '\n'))
+	return err
+}
+
+// EncodeEvent returns JSON representation of the event which is sent to all receivers of events
+func EncodeEvent(event model.Event) ([]byte, error) {
+	return json.Marshal(eventToEventData(event))
+}
+
+// eventData is a struct of the event sent to sinks
+type eventData struct {
+	Id            uint64     `json:"id"`
+	Type          string     `json:"type"`
+	CarId         uint64     `json:"carId"`
+	Car           carData    `json:"car"`
+	PreviousOwner *ownerData `json:"previousOwner,omitempty"`
+	OccurredAt    time.Time  `json:"occurredAt"`
+}
+
+type carData struct {
+	Id     uint64    `json:"id"`
+	RegNum string    `json:"regNum"`
+	Mark   string    `json:"mark"`
+	Model  string    `json:"model"`
+	Year   int       `json:"year"`
+	Owner  ownerData `json:"owner"`
+}
+
+type ownerData struct {
+	Name       string `json:"name"`
+	Surname    string `json:"surname"`
+	Patronymic string `json:"patronymic"`
+}
+
+func eventToEventData(event model.Event) eventData {
+	data := eventData{
+		Id:    event.Id,
+		Type:  string(event.Type),
+		CarId: event.CarId,
+		Car: carData{
+			Id:     event.Car.Id,
+			RegNum: event.Car.RegNum,
+			Mark:   event.Car.Mark,
+			Model:  event.Car.Model,
+			Year:   event.Car.Year,
+			Owner:  ownerToOwnerData(event.Car.Owner),
+		},
+		OccurredAt: event.CreatedAt,
+	}
+	if event.PreviousOwner != nil {
+		prev := ownerToOwnerData(*event.PreviousOwner)
+		data.PreviousOwner = &prev
+	}
+	return data
+}
+
+func ownerToOwnerData(owner model.Owner) ownerData {
+	return ownerData{
+		Name:       owner.Name,
+		Surname:    owner.Surname,
+		Patronymic: owner.Patronymic,
+	}
+}
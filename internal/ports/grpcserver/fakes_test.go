@@ -0,0 +1,50 @@
+package grpcserver_test
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"context"
+)
+
+// faultyRepo fails every method used by CarsService with err
+type faultyRepo struct {
+	repo.Repo
+	err error
+}
+
+func (r faultyRepo) GetCarById(context.Context, uint64) (model.Car, error) {
+	return model.Car{}, r.err
+}
+
+func (r faultyRepo) GetCars(context.Context, model.Filter) ([]model.Car, error) {
+	return []model.Car{}, r.err
+}
+
+func (r faultyRepo) IterateCars(context.Context, model.Filter, func(model.Car) error) error {
+	return r.err
+}
+
+func (r faultyRepo) AddCar(context.Context, model.Car) (model.Car, error) {
+	return model.Car{}, r.err
+}
+
+func (r faultyRepo) UpdateCar(context.Context, uint64, model.Car) (model.Car, error) {
+	return model.Car{}, r.err
+}
+
+func (r faultyRepo) DeleteCar(context.Context, uint64) error {
+	return r.err
+}
+
+// panicRepo panics on reading cars to check that the server survives panics of handlers
+type panicRepo struct {
+	repo.Repo
+}
+
+func (r panicRepo) GetCarById(context.Context, uint64) (model.Car, error) {
+	panic("unexpected nil pointer")
+}
+
+func (r panicRepo) IterateCars(context.Context, model.Filter, func(model.Car) error) error {
+	panic("unexpected nil pointer")
+}
@@ -0,0 +1,73 @@
+package grpcserver
+
+import (
+	"cars-service/internal/app"
+	"cars-service/internal/model"
+	"cars-service/internal/ports/grpcserver/pb"
+	"context"
+)
+
+type carsServer struct {
+	pb.UnimplementedCarsServiceServer
+	app.App
+	maxBatchSize int
+}
+
+func (s *carsServer) GetCar(ctx context.Context, req *pb.GetCarRequest) (*pb.Car, error) {
+	car, err := s.GetCarById(ctx, req.GetId())
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return carToPb(car), nil
+}
+
+func (s *carsServer) GetCars(ctx context.Context, req *pb.GetCarsRequest) (*pb.GetCarsResponse, error) {
+	// unlike ListCars the page size is required, so the whole catalog is not loaded into memory
+	if req.GetLimit() == 0 {
+		return nil, errorToStatus(model.ErrInvalidInput)
+	}
+	cars, err := s.App.GetCars(ctx, pbToFilter(req))
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &pb.GetCarsResponse{Cars: carsToPb(cars)}, nil
+}
+
+func (s *carsServer) ListCars(req *pb.GetCarsRequest, stream pb.CarsService_ListCarsServer) error {
+	err := s.IterateCars(stream.Context(), pbToFilter(req), func(car model.Car) error {
+		return stream.Send(carToPb(car))
+	})
+	if err != nil {
+		return errorToStatus(err)
+	}
+	return nil
+}
+
+func (s *carsServer) AddCars(ctx context.Context, req *pb.AddCarsRequest) (*pb.AddCarsResponse, error) {
+	if len(req.GetRegNums()) > s.maxBatchSize {
+		return nil, batchTooLargeStatus(s.maxBatchSize)
+	}
+	cars, err := s.App.AddCars(ctx, req.GetRegNums())
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &pb.AddCarsResponse{Cars: carsToPb(cars)}, nil
+}
+
+func (s *carsServer) UpdateCar(ctx context.Context, req *pb.UpdateCarRequest) (*pb.Car, error) {
+	if req.GetCar() == nil {
+		return nil, errorToStatus(model.ErrInvalidInput)
+	}
+	car, err := s.App.UpdateCar(ctx, req.GetId(), pbToCar(req.GetCar()))
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return carToPb(car), nil
+}
+
+func (s *carsServer) DeleteCar(ctx context.Context, req *pb.DeleteCarRequest) (*pb.DeleteCarResponse, error) {
+	if err := s.App.DeleteCar(ctx, req.GetId()); err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &pb.DeleteCarResponse{}, nil
+}
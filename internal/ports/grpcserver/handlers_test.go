@@ -0,0 +1,126 @@
+package grpcserver_test
+
+import (
+	"cars-service/internal/ports/grpcserver/pb"
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"testing"
+)
+
+func TestGetCar(t *testing.T) {
+	c := newTestClient(t, nil)
+
+	car, err := c.GetCar(context.Background(), &pb.GetCarRequest{Id: 1})
+	if err != nil {
+		t.Fatalf("GetCar: %v", err)
+	}
+	assertCars(t, []*pb.Car{car}, vestaPb)
+}
+
+func TestGetCars(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	resp, err := c.GetCars(ctx, &pb.GetCarsRequest{Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("GetCars: %v", err)
+	}
+	assertCars(t, resp.GetCars(), rioPb)
+
+	// only fields set in the filter are compared, so the empty patronymic is a filter too
+	mark, patronymic := "Lada", ""
+	resp, err = c.GetCars(ctx, &pb.GetCarsRequest{Limit: 10, Filter: &pb.Filter{Mark: &mark}})
+	if err != nil {
+		t.Fatalf("GetCars: %v", err)
+	}
+	assertCars(t, resp.GetCars(), vestaPb)
+	resp, err = c.GetCars(ctx, &pb.GetCarsRequest{Limit: 10, Filter: &pb.Filter{OwnerPatronymic: &patronymic}})
+	if err != nil {
+		t.Fatalf("GetCars: %v", err)
+	}
+	assertCars(t, resp.GetCars())
+}
+
+func TestListCars(t *testing.T) {
+	c := newTestClient(t, nil)
+
+	// pagination is optional for streaming
+	stream, err := c.ListCars(context.Background(), &pb.GetCarsRequest{})
+	if err != nil {
+		t.Fatalf("ListCars: %v", err)
+	}
+	cars, err := receiveCars(t, stream)
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	assertCars(t, cars, vestaPb, rioPb)
+}
+
+func TestAddCars(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	// regNums unknown to the outer API are skipped
+	resp, err := c.AddCars(ctx, &pb.AddCarsRequest{RegNums: []string{"E555EE199", "X000XX00"}})
+	if err != nil {
+		t.Fatalf("AddCars: %v", err)
+	}
+	assertCars(t, resp.GetCars(), audiPb)
+
+	// invalid regNums fail the whole request
+	_, err = c.AddCars(ctx, &pb.AddCarsRequest{RegNums: []string{"E555EE199", "invalid"}})
+	assertStatus(t, err, codes.InvalidArgument, "validation error")
+}
+
+func TestAddCarsBatchTooLarge(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	// regNums unknown to the outer API count too
+	regNums := []string{"E555EE199", "X000XX00", "X001XX00", "X002XX00"}
+	_, err := c.AddCars(ctx, &pb.AddCarsRequest{RegNums: regNums})
+	assertStatus(t, err, codes.InvalidArgument, "too many registration numbers")
+	assertViolations(t, err, "regNums")
+
+	// nothing is added from the rejected batch
+	_, err = c.GetCar(ctx, &pb.GetCarRequest{Id: 3})
+	assertStatus(t, err, codes.NotFound, "car not found")
+
+	resp, err := c.AddCars(ctx, &pb.AddCarsRequest{RegNums: regNums[:testMaxBatchSize]})
+	if err != nil {
+		t.Fatalf("AddCars: %v", err)
+	}
+	assertCars(t, resp.GetCars(), audiPb)
+}
+
+func TestUpdateCar(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	car := proto.Clone(rioPb).(*pb.Car)
+	car.Year = 2019
+	updated, err := c.UpdateCar(ctx, &pb.UpdateCarRequest{Id: 2, Car: car})
+	if err != nil {
+		t.Fatalf("UpdateCar: %v", err)
+	}
+	assertCars(t, []*pb.Car{updated}, car)
+
+	_, err = c.UpdateCar(ctx, &pb.UpdateCarRequest{Id: 2})
+	assertStatus(t, err, codes.InvalidArgument, "invalid input")
+	_, err = c.UpdateCar(ctx, &pb.UpdateCarRequest{Id: 9, Car: car})
+	assertStatus(t, err, codes.NotFound, "car not found")
+}
+
+func TestDeleteCar(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	if _, err := c.DeleteCar(ctx, &pb.DeleteCarRequest{Id: 1}); err != nil {
+		t.Fatalf("DeleteCar: %v", err)
+	}
+	_, err := c.GetCar(ctx, &pb.GetCarRequest{Id: 1})
+	assertStatus(t, err, codes.NotFound, "car not found")
+	_, err = c.DeleteCar(ctx, &pb.DeleteCarRequest{Id: 1})
+	assertStatus(t, err, codes.NotFound, "car not found")
+}
@@ -0,0 +1,63 @@
+package grpcserver
+
+import (
+	"cars-service/internal/model"
+	"cars-service/pkg/logger"
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func loggingUnaryInterceptor(logs logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		logs.Info(logger.Fields{
+			"method": info.FullMethod,
+			"code":   status.Code(err).String(),
+		}, "")
+		return resp, err
+	}
+}
+
+func loggingStreamInterceptor(logs logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		logs.Info(logger.Fields{
+			"method": info.FullMethod,
+			"code":   status.Code(err).String(),
+		}, "")
+		return err
+	}
+}
+
+func panicUnaryInterceptor(logs logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logs.Error(logger.Fields{
+					"method": info.FullMethod,
+				}, fmt.Sprintf("panic: %v", r))
+
+				resp, err = nil, status.Error(codes.Internal, model.ErrServiceError.Error())
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func panicStreamInterceptor(logs logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logs.Error(logger.Fields{
+					"method": info.FullMethod,
+				}, fmt.Sprintf("panic: %v", r))
+
+				err = status.Error(codes.Internal, model.ErrServiceError.Error())
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
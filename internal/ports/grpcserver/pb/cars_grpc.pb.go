@@ -0,0 +1,332 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: cars.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	CarsService_GetCar_FullMethodName    = "/cars.v1.CarsService/GetCar"
+	CarsService_GetCars_FullMethodName   = "/cars.v1.CarsService/GetCars"
+	CarsService_ListCars_FullMethodName  = "/cars.v1.CarsService/ListCars"
+	CarsService_AddCars_FullMethodName   = "/cars.v1.CarsService/AddCars"
+	CarsService_UpdateCar_FullMethodName = "/cars.v1.CarsService/UpdateCar"
+	CarsService_DeleteCar_FullMethodName = "/cars.v1.CarsService/DeleteCar"
+)
+
+// CarsServiceClient is the client API for CarsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CarsServiceClient interface {
+	GetCar(ctx context.Context, in *GetCarRequest, opts ...grpc.CallOption) (*Car, error)
+	// GetCars returns a page of filtered cars
+	GetCars(ctx context.Context, in *GetCarsRequest, opts ...grpc.CallOption) (*GetCarsResponse, error)
+	// ListCars streams filtered cars while they are read from the database,
+	// limit and offset are optional
+	ListCars(ctx context.Context, in *GetCarsRequest, opts ...grpc.CallOption) (CarsService_ListCarsClient, error)
+	// AddCars gets data of cars from the outer API by their regNums and adds them,
+	// regNums which can't be found are skipped, invalid ones fail the whole request
+	AddCars(ctx context.Context, in *AddCarsRequest, opts ...grpc.CallOption) (*AddCarsResponse, error)
+	UpdateCar(ctx context.Context, in *UpdateCarRequest, opts ...grpc.CallOption) (*Car, error)
+	DeleteCar(ctx context.Context, in *DeleteCarRequest, opts ...grpc.CallOption) (*DeleteCarResponse, error)
+}
+
+type carsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCarsServiceClient(cc grpc.ClientConnInterface) CarsServiceClient {
+	return &carsServiceClient{cc}
+}
+
+func (c *carsServiceClient) GetCar(ctx context.Context, in *GetCarRequest, opts ...grpc.CallOption) (*Car, error) {
+	out := new(Car)
+	err := c.cc.Invoke(ctx, CarsService_GetCar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *carsServiceClient) GetCars(ctx context.Context, in *GetCarsRequest, opts ...grpc.CallOption) (*GetCarsResponse, error) {
+	out := new(GetCarsResponse)
+	err := c.cc.Invoke(ctx, CarsService_GetCars_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *carsServiceClient) ListCars(ctx context.Context, in *GetCarsRequest, opts ...grpc.CallOption) (CarsService_ListCarsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CarsService_ServiceDesc.Streams[0], CarsService_ListCars_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &carsServiceListCarsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CarsService_ListCarsClient interface {
+	Recv() (*Car, error)
+	grpc.ClientStream
+}
+
+type carsServiceListCarsClient struct {
+	grpc.ClientStream
+}
+
+func (x *carsServiceListCarsClient) Recv() (*Car, error) {
+	m := new(Car)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *carsServiceClient) AddCars(ctx context.Context, in *AddCarsRequest, opts ...grpc.CallOption) (*AddCarsResponse, error) {
+	out := new(AddCarsResponse)
+	err := c.cc.Invoke(ctx, CarsService_AddCars_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *carsServiceClient) UpdateCar(ctx context.Context, in *UpdateCarRequest, opts ...grpc.CallOption) (*Car, error) {
+	out := new(Car)
+	err := c.cc.Invoke(ctx, CarsService_UpdateCar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *carsServiceClient) DeleteCar(ctx context.Context, in *DeleteCarRequest, opts ...grpc.CallOption) (*DeleteCarResponse, error) {
+	out := new(DeleteCarResponse)
+	err := c.cc.Invoke(ctx, CarsService_DeleteCar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CarsServiceServer is the server API for CarsService service.
+// All implementations must embed UnimplementedCarsServiceServer
+// for forward compatibility
+type CarsServiceServer interface {
+	GetCar(context.Context, *GetCarRequest) (*Car, error)
+	// GetCars returns a page of filtered cars
+	GetCars(context.Context, *GetCarsRequest) (*GetCarsResponse, error)
+	// ListCars streams filtered cars while they are read from the database,
+	// limit and offset are optional
+	ListCars(*GetCarsRequest, CarsService_ListCarsServer) error
+	// AddCars gets data of cars from the outer API by their regNums and adds them,
+	// regNums which can't be found are skipped, invalid ones fail the whole request
+	AddCars(context.Context, *AddCarsRequest) (*AddCarsResponse, error)
+	UpdateCar(context.Context, *UpdateCarRequest) (*Car, error)
+	DeleteCar(context.Context, *DeleteCarRequest) (*DeleteCarResponse, error)
+	mustEmbedUnimplementedCarsServiceServer()
+}
+
+// UnimplementedCarsServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCarsServiceServer struct {
+}
+
+func (UnimplementedCarsServiceServer) GetCar(context.Context, *GetCarRequest) (*Car, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCar not implemented")
+}
+func (UnimplementedCarsServiceServer) GetCars(context.Context, *GetCarsRequest) (*GetCarsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCars not implemented")
+}
+func (UnimplementedCarsServiceServer) ListCars(*GetCarsRequest, CarsService_ListCarsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListCars not implemented")
+}
+func (UnimplementedCarsServiceServer) AddCars(context.Context, *AddCarsRequest) (*AddCarsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddCars not implemented")
+}
+func (UnimplementedCarsServiceServer) UpdateCar(context.Context, *UpdateCarRequest) (*Car, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCar not implemented")
+}
+func (UnimplementedCarsServiceServer) DeleteCar(context.Context, *DeleteCarRequest) (*DeleteCarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCar not implemented")
+}
+func (UnimplementedCarsServiceServer) mustEmbedUnimplementedCarsServiceServer() {}
+
+// UnsafeCarsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CarsServiceServer will
+// result in compilation errors.
+type UnsafeCarsServiceServer interface {
+	mustEmbedUnimplementedCarsServiceServer()
+}
+
+func RegisterCarsServiceServer(s grpc.ServiceRegistrar, srv CarsServiceServer) {
+	s.RegisterService(&CarsService_ServiceDesc, srv)
+}
+
+func _CarsService_GetCar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CarsServiceServer).GetCar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CarsService_GetCar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CarsServiceServer).GetCar(ctx, req.(*GetCarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CarsService_GetCars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCarsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CarsServiceServer).GetCars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CarsService_GetCars_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CarsServiceServer).GetCars(ctx, req.(*GetCarsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CarsService_ListCars_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetCarsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CarsServiceServer).ListCars(m, &carsServiceListCarsServer{stream})
+}
+
+type CarsService_ListCarsServer interface {
+	Send(*Car) error
+	grpc.ServerStream
+}
+
+type carsServiceListCarsServer struct {
+	grpc.ServerStream
+}
+
+func (x *carsServiceListCarsServer) Send(m *Car) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CarsService_AddCars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddCarsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CarsServiceServer).AddCars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CarsService_AddCars_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CarsServiceServer).AddCars(ctx, req.(*AddCarsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CarsService_UpdateCar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CarsServiceServer).UpdateCar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CarsService_UpdateCar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CarsServiceServer).UpdateCar(ctx, req.(*UpdateCarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CarsService_DeleteCar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CarsServiceServer).DeleteCar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CarsService_DeleteCar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CarsServiceServer).DeleteCar(ctx, req.(*DeleteCarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CarsService_ServiceDesc is the grpc.ServiceDesc for CarsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CarsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cars.v1.CarsService",
+	HandlerType: (*CarsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCar",
+			Handler:    _CarsService_GetCar_Handler,
+		},
+		{
+			MethodName: "GetCars",
+			Handler:    _CarsService_GetCars_Handler,
+		},
+		{
+			MethodName: "AddCars",
+			Handler:    _CarsService_AddCars_Handler,
+		},
+		{
+			MethodName: "UpdateCar",
+			Handler:    _CarsService_UpdateCar_Handler,
+		},
+		{
+			MethodName: "DeleteCar",
+			Handler:    _CarsService_DeleteCar_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListCars",
+			Handler:       _CarsService_ListCars_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cars.proto",
+}
@@ -0,0 +1,124 @@
+package grpcserver
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/ports/grpcserver/pb"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func carToPb(car model.Car) *pb.Car {
+	return &pb.Car{
+		Id:     car.Id,
+		RegNum: car.RegNum,
+		Mark:   car.Mark,
+		Model:  car.Model,
+		Year:   int32(car.Year),
+		Owner: &pb.Owner{
+			Name:       car.Owner.Name,
+			Surname:    car.Owner.Surname,
+			Patronymic: car.Owner.Patronymic,
+		},
+	}
+}
+
+func carsToPb(cars []model.Car) []*pb.Car {
+	res := make([]*pb.Car, len(cars))
+	for i, car := range cars {
+		res[i] = carToPb(car)
+	}
+	return res
+}
+
+func pbToCar(car *pb.Car) model.Car {
+	return model.Car{
+		RegNum: car.GetRegNum(),
+		Mark:   car.GetMark(),
+		Model:  car.GetModel(),
+		Year:   int(car.GetYear()),
+		Owner: model.Owner{
+			Name:       car.GetOwner().GetName(),
+			Surname:    car.GetOwner().GetSurname(),
+			Patronymic: car.GetOwner().GetPatronymic(),
+		},
+	}
+}
+
+// pbToFilter converts request to model.Filter, only fields set in the request are used for filtering
+func pbToFilter(req *pb.GetCarsRequest) model.Filter {
+	f := req.GetFilter()
+	filter := model.Filter{
+		Limit:  uint(req.GetLimit()),
+		Offset: uint(req.GetOffset()),
+
+		RegNum:          f.GetRegNum(),
+		Mark:            f.GetMark(),
+		Model:           f.GetModel(),
+		Year:            int(f.GetYear()),
+		OwnerName:       f.GetOwnerName(),
+		OwnerSurname:    f.GetOwnerSurname(),
+		OwnerPatronymic: f.GetOwnerPatronymic(),
+	}
+	if f != nil {
+		filter.ByRegNum = f.RegNum != nil
+		filter.ByMark = f.Mark != nil
+		filter.ByModel = f.Model != nil
+		filter.ByYear = f.Year != nil
+		filter.ByOwnerName = f.OwnerName != nil
+		filter.ByOwnerSurname = f.OwnerSurname != nil
+		filter.ByOwnerPatronymic = f.OwnerPatronymic != nil
+	}
+	return filter
+}
+
+// errorToStatus maps errors of the app to gRPC status with the same messages as in the HTTP API,
+// failed fields of the validation error are returned as BadRequest details
+func errorToStatus(err error) error {
+	var validationErr *model.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(validationErr.Fields))
+		for i, field := range validationErr.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Reason,
+			}
+		}
+		return badRequestStatus(model.ErrValidation, violations)
+	case errors.Is(err, model.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, model.ErrInvalidInput.Error())
+	case errors.Is(err, model.ErrCarNotFound):
+		return status.Error(codes.NotFound, model.ErrCarNotFound.Error())
+	case errors.Is(err, model.ErrDuplicateRegNum):
+		return status.Error(codes.AlreadyExists, model.ErrDuplicateRegNum.Error())
+	// calls interrupted by the deadline of the client are reported as failures of the database too
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, model.ErrDeadline.Error())
+	case errors.Is(err, model.ErrDatabaseError):
+		return status.Error(codes.Internal, model.ErrDatabaseError.Error())
+	default:
+		return status.Error(codes.Internal, model.ErrServiceError.Error())
+	}
+}
+
+// batchTooLargeStatus is the status of AddCars with more than maxBatchSize regNums
+func batchTooLargeStatus(maxBatchSize int) error {
+	return badRequestStatus(model.ErrBatchTooLarge, []*errdetails.BadRequest_FieldViolation{{
+		Field:       "regNums",
+		Description: fmt.Sprintf("must contain at most %d items", maxBatchSize),
+	}})
+}
+
+// badRequestStatus returns InvalidArgument status with the message of err and failed fields as
+// BadRequest details
+func badRequestStatus(err error, violations []*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(codes.InvalidArgument, err.Error())
+	if withDetails, detailsErr := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); detailsErr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
@@ -0,0 +1,25 @@
+package grpcserver
+
+import (
+	"cars-service/internal/app"
+	"cars-service/internal/ports/grpcserver/pb"
+	"cars-service/pkg/logger"
+	"google.golang.org/grpc"
+)
+
+// New creates gRPC server with CarsService registered, AddCars accepts at most maxBatchSize regNums
+// like the HTTP API
+func New(a app.App, logs logger.Logger, maxBatchSize int) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			loggingUnaryInterceptor(logs),
+			panicUnaryInterceptor(logs),
+		),
+		grpc.ChainStreamInterceptor(
+			loggingStreamInterceptor(logs),
+			panicStreamInterceptor(logs),
+		),
+	)
+	pb.RegisterCarsServiceServer(srv, &carsServer{App: a, maxBatchSize: maxBatchSize})
+	return srv
+}
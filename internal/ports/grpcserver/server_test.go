@@ -0,0 +1,301 @@
+package grpcserver_test
+
+import (
+	"cars-service/internal/adapters/api"
+	"cars-service/internal/app"
+	"cars-service/internal/model"
+	"cars-service/internal/ports/grpcserver"
+	"cars-service/internal/ports/grpcserver/pb"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	ivanov = model.Owner{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"}
+	petrov = model.Owner{Name: "Пётр", Surname: "Петров", Patronymic: "Петрович"}
+	sidrov = model.Owner{Name: "Сидор", Surname: "Сидоров"}
+)
+
+// seedCars are added to the repo of every test server with ids 1 and 2
+var seedCars = []model.Car{
+	{RegNum: "A111AA150", Mark: "Lada", Model: "Vesta", Year: 2020, Owner: ivanov},
+	{RegNum: "B222BB50", Mark: "Kia", Model: "Rio", Year: 2018, Owner: petrov},
+}
+
+// outerCars are the cars known by the stub of the outer API, new cars are added with id 3
+var outerCars = []model.Car{
+	seedCars[0],
+	seedCars[1],
+	{RegNum: "E555EE199", Mark: "Audi", Model: "A4", Year: 2015, Owner: sidrov},
+}
+
+// seedCars and the new car from the outer API in responses
+var (
+	vestaPb = &pb.Car{Id: 1, RegNum: "A111AA150", Mark: "Lada", Model: "Vesta", Year: 2020,
+		Owner: &pb.Owner{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"}}
+	rioPb = &pb.Car{Id: 2, RegNum: "B222BB50", Mark: "Kia", Model: "Rio", Year: 2018,
+		Owner: &pb.Owner{Name: "Пётр", Surname: "Петров", Patronymic: "Петрович"}}
+	audiPb = &pb.Car{Id: 3, RegNum: "E555EE199", Mark: "Audi", Model: "A4", Year: 2015,
+		Owner: &pb.Owner{Name: "Сидор", Surname: "Сидоров"}}
+)
+
+// testMaxBatchSize is the limit of regNums added by one request to the test server
+const testMaxBatchSize = 3
+
+// newTestClient starts the server with the app over r, the memory repo with seedCars is used if
+// r is nil, and returns the client connected to it through the in-memory listener
+func newTestClient(t *testing.T, r repo.Repo) pb.CarsServiceClient {
+	t.Helper()
+	if r == nil {
+		r = repo.NewMemoryRepo()
+		for _, car := range seedCars {
+			if _, err := r.AddCar(context.Background(), car); err != nil {
+				t.Fatalf("AddCar(%s): %v", car.RegNum, err)
+			}
+		}
+	}
+	outer := httptest.NewServer(http.HandlerFunc(handleOuterApi))
+	t.Cleanup(outer.Close)
+
+	// only fatal messages are written, so logs of handled errors do not clutter the output
+	logs, err := logger.NewWithLevel("fatal")
+	if err != nil {
+		t.Fatalf("NewWithLevel: %v", err)
+	}
+	a := app.New(r, nil, nil, nil, api.New(outer.URL, time.Second), logs)
+
+	listener := bufconn.Listen(1 << 20)
+	srv := grpcserver.New(a, logs, testMaxBatchSize)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return pb.NewCarsServiceClient(conn)
+}
+
+// handleOuterApi is the stub of the outer API which knows outerCars
+func handleOuterApi(w http.ResponseWriter, r *http.Request) {
+	regNum := r.URL.Query().Get("regNum")
+	for _, car := range outerCars {
+		if car.RegNum == regNum {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"regNum": car.RegNum,
+				"mark":   car.Mark,
+				"model":  car.Model,
+				"year":   car.Year,
+				"owner": map[string]string{
+					"name":       car.Owner.Name,
+					"surname":    car.Owner.Surname,
+					"patronymic": car.Owner.Patronymic,
+				},
+			})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func assertStatus(t *testing.T, err error, code codes.Code, message string) {
+	t.Helper()
+	st, _ := status.FromError(err)
+	if st.Code() != code || st.Message() != message {
+		t.Errorf("status = %v %q, want %v %q", st.Code(), st.Message(), code, message)
+	}
+}
+
+func assertCars(t *testing.T, got []*pb.Car, want ...*pb.Car) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d cars, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("car %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// assertViolations checks that BadRequest details of the status of err contain described fields
+func assertViolations(t *testing.T, err error, fields ...string) {
+	t.Helper()
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = append(violations, badRequest.GetFieldViolations()...)
+		}
+	}
+	if len(violations) != len(fields) {
+		t.Fatalf("got violations %v, want fields %v", violations, fields)
+	}
+	for i, field := range fields {
+		if violations[i].GetField() != field || violations[i].GetDescription() == "" {
+			t.Errorf("violation %d = %v, want field %s with description", i, violations[i], field)
+		}
+	}
+}
+
+// receiveCars reads the stream of ListCars to the end
+func receiveCars(t *testing.T, stream pb.CarsService_ListCarsClient) ([]*pb.Car, error) {
+	t.Helper()
+	var cars []*pb.Car
+	for {
+		car, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return cars, nil
+		} else if err != nil {
+			return cars, err
+		}
+		cars = append(cars, car)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		repo    repo.Repo
+		call    func(ctx context.Context, c pb.CarsServiceClient) error
+		code    codes.Code
+		message string
+	}{
+		{
+			name: "not found",
+			call: func(ctx context.Context, c pb.CarsServiceClient) error {
+				_, err := c.GetCar(ctx, &pb.GetCarRequest{Id: 9})
+				return err
+			},
+			code:    codes.NotFound,
+			message: "car not found",
+		},
+		{
+			name: "invalid input",
+			call: func(ctx context.Context, c pb.CarsServiceClient) error {
+				_, err := c.GetCars(ctx, &pb.GetCarsRequest{})
+				return err
+			},
+			code:    codes.InvalidArgument,
+			message: "invalid input",
+		},
+		{
+			name: "duplicate",
+			call: func(ctx context.Context, c pb.CarsServiceClient) error {
+				car := proto.Clone(rioPb).(*pb.Car)
+				car.RegNum = vestaPb.RegNum
+				_, err := c.UpdateCar(ctx, &pb.UpdateCarRequest{Id: 2, Car: car})
+				return err
+			},
+			code:    codes.AlreadyExists,
+			message: "duplicate registration number",
+		},
+		{
+			name: "database error",
+			repo: faultyRepo{err: model.ErrDatabaseError},
+			call: func(ctx context.Context, c pb.CarsServiceClient) error {
+				_, err := c.GetCar(ctx, &pb.GetCarRequest{Id: 1})
+				return err
+			},
+			code:    codes.Internal,
+			message: "database error",
+		},
+		{
+			name: "deadline",
+			repo: faultyRepo{err: errors.Join(model.ErrDatabaseError, context.DeadlineExceeded)},
+			call: func(ctx context.Context, c pb.CarsServiceClient) error {
+				_, err := c.GetCar(ctx, &pb.GetCarRequest{Id: 1})
+				return err
+			},
+			code:    codes.DeadlineExceeded,
+			message: "request deadline exceeded",
+		},
+		{
+			name: "unknown error",
+			repo: faultyRepo{err: errors.New("unexpected")},
+			call: func(ctx context.Context, c pb.CarsServiceClient) error {
+				_, err := c.DeleteCar(ctx, &pb.DeleteCarRequest{Id: 1})
+				return err
+			},
+			code:    codes.Internal,
+			message: "unknown service error",
+		},
+		{
+			name: "stream error",
+			repo: faultyRepo{err: model.ErrDatabaseError},
+			call: func(ctx context.Context, c pb.CarsServiceClient) error {
+				stream, err := c.ListCars(ctx, &pb.GetCarsRequest{})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+			code:    codes.Internal,
+			message: "database error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, tt.repo)
+			assertStatus(t, tt.call(context.Background(), c), tt.code, tt.message)
+		})
+	}
+}
+
+func TestErrorStatusValidation(t *testing.T) {
+	c := newTestClient(t, nil)
+
+	// every failed field is returned in details
+	car := proto.Clone(vestaPb).(*pb.Car)
+	car.Year = 1800
+	car.Mark = ""
+	_, err := c.UpdateCar(context.Background(), &pb.UpdateCarRequest{Id: 1, Car: car})
+	assertStatus(t, err, codes.InvalidArgument, "validation error")
+	assertViolations(t, err, "year", "mark")
+}
+
+func TestPanicRecovery(t *testing.T) {
+	c := newTestClient(t, panicRepo{Repo: repo.NewMemoryRepo()})
+	ctx := context.Background()
+
+	_, err := c.GetCar(ctx, &pb.GetCarRequest{Id: 1})
+	assertStatus(t, err, codes.Internal, "unknown service error")
+
+	stream, err := c.ListCars(ctx, &pb.GetCarsRequest{})
+	if err != nil {
+		t.Fatalf("ListCars: %v", err)
+	}
+	_, err = receiveCars(t, stream)
+	assertStatus(t, err, codes.Internal, "unknown service error")
+
+	// the server keeps serving after panics
+	_, err = c.GetCars(ctx, &pb.GetCarsRequest{Limit: 10})
+	assertStatus(t, err, codes.OK, "")
+}
@@ -0,0 +1,389 @@
+package httpserver_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetCarById(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "found",
+			method: http.MethodGet,
+			path:   "/api/v1/cars/1",
+			status: http.StatusOK,
+			want:   `{"data":` + vestaJSON + `,"error":null}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			path:   "/api/v1/cars/42",
+			status: http.StatusNotFound,
+			want:   carNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodGet,
+			path:   "/api/v1/cars/abc",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestGetCars(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "all",
+			method: http.MethodGet,
+			path:   "/api/v1/cars?limit=10&offset=0",
+			status: http.StatusOK,
+			want:   `{"data":[` + vestaJSON + `,` + rioJSON + `],"error":null}`,
+		},
+		{
+			name:   "page",
+			method: http.MethodGet,
+			path:   "/api/v1/cars?limit=1&offset=1",
+			status: http.StatusOK,
+			want:   `{"data":[` + rioJSON + `],"error":null}`,
+		},
+		{
+			name:   "filter",
+			method: http.MethodGet,
+			path:   "/api/v1/cars?limit=10&offset=0&mark=Lada&year=2020&ownerSurname=%D0%98%D0%B2%D0%B0%D0%BD%D0%BE%D0%B2",
+			status: http.StatusOK,
+			want:   `{"data":[` + vestaJSON + `],"error":null}`,
+		},
+		{
+			name:   "nothing found",
+			method: http.MethodGet,
+			path:   "/api/v1/cars?limit=10&offset=0&mark=Audi",
+			status: http.StatusOK,
+			want:   `{"data":[],"error":null}`,
+		},
+		{
+			name:   "without pagination",
+			method: http.MethodGet,
+			path:   "/api/v1/cars",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+		{
+			name:   "negative limit",
+			method: http.MethodGet,
+			path:   "/api/v1/cars?limit=-1&offset=0",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+		{
+			name:   "invalid year",
+			method: http.MethodGet,
+			path:   "/api/v1/cars?limit=10&offset=0&year=new",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestGetCarsStream(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	// pagination is optional for streaming
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars", "", map[string]string{"Accept": "application/x-ndjson"})
+	if resp.status != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body: %s", resp.status, http.StatusOK, resp.body)
+	}
+	if ct := resp.header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(resp.body), "\n"), "\n")
+	want := []string{vestaJSON, rioJSON}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %s", len(lines), len(want), resp.body)
+	}
+	for i, line := range lines {
+		assertJSON(t, []byte(line), want[i])
+	}
+}
+
+func TestAddCars(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "added",
+			method: http.MethodPost,
+			path:   "/api/v1/cars",
+			body:   `{"regNums":[" E555EE199 "]}`,
+			status: http.StatusOK,
+			want:   `{"data":[` + audiJSON + `],"error":null}`,
+		},
+		{
+			name:   "unknown and failed regNums are skipped",
+			method: http.MethodPost,
+			path:   "/api/v1/cars",
+			body:   `{"regNums":["X000XX00","` + brokenRegNum + `"]}`,
+			status: http.StatusOK,
+			want:   `{"data":[],"error":null}`,
+		},
+		{
+			name:   "invalid regNums",
+			method: http.MethodPost,
+			path:   "/api/v1/cars",
+			body:   `{"regNums":["E555"," 123","E555EE199"]}`,
+			status: http.StatusBadRequest,
+			want: `{"data":null,"error":"validation error","details":[` +
+				`{"field":"regNums","reason":"E555: must match format X000XX00 or X000XX000"},` +
+				`{"field":"regNums","reason":"123: must match format X000XX00 or X000XX000"}]}`,
+		},
+		{
+			name:   "duplicate",
+			method: http.MethodPost,
+			path:   "/api/v1/cars",
+			body:   `{"regNums":["A111AA150"]}`,
+			status: http.StatusConflict,
+			want:   duplicateRegNumJSON,
+		},
+		{
+			name:   "repeated regNums are added once",
+			method: http.MethodPost,
+			path:   "/api/v1/cars",
+			body:   `{"regNums":["E555EE199"," E555EE199","X000XX00","X000XX00","E555EE199"]}`,
+			status: http.StatusOK,
+			want:   `{"data":[` + audiJSON + `],"duplicates":["E555EE199","X000XX00"],"error":null}`,
+		},
+		{
+			name:   "too many regNums",
+			method: http.MethodPost,
+			path:   "/api/v1/cars",
+			body:   `{"regNums":[` + strings.Repeat(`"E555EE199",`, 100) + `"E555EE199"]}`,
+			status: http.StatusBadRequest,
+			want: `{"data":null,"error":"too many registration numbers","details":[` +
+				`{"field":"regNums","reason":"must contain at most 100 items"}]}`,
+		},
+		{
+			name:   "body too large",
+			method: http.MethodPost,
+			path:   "/api/v1/cars",
+			body:   `{"regNums":["` + strings.Repeat(" ", 1<<20) + `"]}`,
+			status: http.StatusRequestEntityTooLarge,
+			want:   bodyTooLargeJSON,
+		},
+		{
+			name:   "invalid body",
+			method: http.MethodPost,
+			path:   "/api/v1/cars",
+			body:   `{"regNums":`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestBodyTooLargeWithoutContentLength(t *testing.T) {
+	ts := newTestServer(t, nil)
+	// the length of the chunked body is unknown until it is read
+	body := io.MultiReader(strings.NewReader(`{"regNums":["`), strings.NewReader(strings.Repeat(" ", 1<<20)))
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/cars", body)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	assertJSONResponse(t, response{status: resp.StatusCode, header: resp.Header, body: respBody}, http.StatusRequestEntityTooLarge, bodyTooLargeJSON)
+}
+
+func TestUpdateCar(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "updated",
+			method: http.MethodPut,
+			path:   "/api/v1/cars/2",
+			body: `{"regNum":"B222BB50","mark":"Kia","model":"Rio","year":2018,` +
+				`"owner":{"name":"Сидор","surname":"Сидоров"}}`,
+			status: http.StatusOK,
+			want: `{"data":{"id":2,"regNum":"B222BB50","mark":"Kia","model":"Rio","year":2018,` +
+				`"owner":{"name":"Сидор","surname":"Сидоров","patronymic":""},"sources":{"owner":"manual"}},"error":null}`,
+		},
+		{
+			name:   "invalid fields",
+			method: http.MethodPut,
+			path:   "/api/v1/cars/2",
+			body:   `{"regNum":"B222","mark":" ","model":"Rio","year":2018,"owner":{"name":"Пётр","surname":"Петров"}}`,
+			status: http.StatusBadRequest,
+			want: `{"data":null,"error":"validation error","details":[` +
+				`{"field":"regNum","reason":"must match format X000XX00 or X000XX000"},` +
+				`{"field":"mark","reason":"must not be empty"}]}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodPut,
+			path:   "/api/v1/cars/42",
+			body:   `{"regNum":"E555EE199","mark":"Audi","model":"A4","year":2015,"owner":{"name":"Сидор","surname":"Сидоров"}}`,
+			status: http.StatusNotFound,
+			want:   carNotFoundJSON,
+		},
+		{
+			name:   "duplicate",
+			method: http.MethodPut,
+			path:   "/api/v1/cars/2",
+			body:   `{"regNum":"A111AA150","mark":"Kia","model":"Rio","year":2018,"owner":{"name":"Пётр","surname":"Петров"}}`,
+			status: http.StatusConflict,
+			want:   duplicateRegNumJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodPut,
+			path:   "/api/v1/cars/abc",
+			body:   `{"regNum":"B222BB50","mark":"Kia","model":"Rio","year":2018,"owner":{"name":"Пётр","surname":"Петров"}}`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+		{
+			name:   "invalid body",
+			method: http.MethodPut,
+			path:   "/api/v1/cars/2",
+			body:   `{"year":"2018"}`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestDeleteCar(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "deleted",
+			method: http.MethodDelete,
+			path:   "/api/v1/cars/1",
+			status: http.StatusOK,
+			want:   okJSON,
+		},
+		{
+			name: "already deleted",
+			setup: func(t *testing.T, ts *testServer) {
+				ts.do(t, http.MethodDelete, "/api/v1/cars/1", "", nil)
+			},
+			method: http.MethodGet,
+			path:   "/api/v1/cars/1",
+			status: http.StatusNotFound,
+			want:   carNotFoundJSON,
+		},
+		{
+			name:   "not found",
+			method: http.MethodDelete,
+			path:   "/api/v1/cars/42",
+			status: http.StatusNotFound,
+			want:   carNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodDelete,
+			path:   "/api/v1/cars/-1",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestExportCars(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	t.Run("csv", func(t *testing.T) {
+		resp := ts.do(t, http.MethodGet, "/api/v1/cars/export?mark=Kia", "", nil)
+		if resp.status != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body: %s", resp.status, http.StatusOK, resp.body)
+		}
+		if ct := resp.header.Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+			t.Errorf("Content-Type = %q, want text/csv", ct)
+		}
+		if cd := resp.header.Get("Content-Disposition"); cd != `attachment; filename="cars.csv"` {
+			t.Errorf("Content-Disposition = %q", cd)
+		}
+		want := "id,regNum,mark,model,year,ownerName,ownerSurname,ownerPatronymic\n" +
+			"2,B222BB50,Kia,Rio,2018,Пётр,Петров,Петрович\n"
+		if string(resp.body) != want {
+			t.Errorf("body = %q, want %q", resp.body, want)
+		}
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		resp := ts.do(t, http.MethodGet, "/api/v1/cars/export?format=xlsx", "", nil)
+		if resp.status != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body: %s", resp.status, http.StatusOK, resp.body)
+		}
+		if ct := resp.header.Get("Content-Type"); ct != "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" {
+			t.Errorf("Content-Type = %q, want xlsx", ct)
+		}
+		// XLSX is a zip archive
+		if !strings.HasPrefix(string(resp.body), "PK") {
+			t.Errorf("body is not XLSX file")
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		resp := ts.do(t, http.MethodGet, "/api/v1/cars/export?format=pdf", "", nil)
+		assertJSONResponse(t, resp, http.StatusBadRequest, invalidInputJSON)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		resp := ts.do(t, http.MethodGet, "/api/v1/cars/export?limit=many", "", nil)
+		assertJSONResponse(t, resp, http.StatusBadRequest, invalidInputJSON)
+	})
+}
+
+func TestImportCars(t *testing.T) {
+	file, contentType := multipartFile(t, "file", "cars.csv",
+		"regNum,mark,model,year,ownerName,ownerSurname,ownerPatronymic\n"+
+			"E555EE199\n"+
+			"A111AA150,Lada,Vesta,2020,Иван,Иванов,Иванович\n"+
+			"C333CC77,Toyota,Camry,new,Пётр,Петров,Петрович\n"+
+			"X000XX00\n")
+	noRegNums, noRegNumsType := multipartFile(t, "file", "cars.csv", "mark,model\nLada,Vesta\n")
+	noFile, noFileType := multipartFile(t, "document", "cars.csv", "regNum\nE555EE199\n")
+
+	runRouteTests(t, []routeTest{
+		{
+			name:   "imported",
+			method: http.MethodPost,
+			path:   "/api/v1/cars/import",
+			body:   file,
+			header: map[string]string{"Content-Type": contentType},
+			status: http.StatusOK,
+			want: `{"data":[` +
+				`{"row":2,"regNum":"E555EE199","status":"done","carId":3},` +
+				`{"row":3,"regNum":"A111AA150","status":"failed","error":"duplicate registration number"},` +
+				`{"row":4,"regNum":"C333CC77","status":"failed","error":"invalid input: year must be a number"},` +
+				`{"row":5,"regNum":"X000XX00","status":"failed","error":"outer api error"}` +
+				`],"error":null}`,
+		},
+		{
+			name:   "without regNum column",
+			method: http.MethodPost,
+			path:   "/api/v1/cars/import",
+			body:   noRegNums,
+			header: map[string]string{"Content-Type": noRegNumsType},
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+		{
+			name:   "without file",
+			method: http.MethodPost,
+			path:   "/api/v1/cars/import",
+			body:   noFile,
+			header: map[string]string{"Content-Type": noFileType},
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
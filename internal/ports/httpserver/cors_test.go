@@ -0,0 +1,97 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/config"
+	"net/http"
+	"testing"
+)
+
+const dashboardOrigin = "https://dashboard.example.com"
+
+func newCORSServer(t *testing.T) *testServer {
+	t.Helper()
+	cfg := config.Default().Server
+	cfg.CORS.AllowedOrigins = []string{dashboardOrigin}
+	cfg.CORS.AllowCredentials = true
+	return newTestServerWithConfig(t, nil, cfg)
+}
+
+func assertHeaders(t *testing.T, resp response, want map[string]string) {
+	t.Helper()
+	for name, value := range want {
+		if got := resp.header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	ts := newCORSServer(t)
+
+	resp := ts.do(t, http.MethodOptions, "/api/v1/cars/1", "", map[string]string{
+		"Origin":                         dashboardOrigin,
+		"Access-Control-Request-Method":  http.MethodPut,
+		"Access-Control-Request-Headers": "Content-Type, Idempotency-Key",
+	})
+	if resp.status != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204: %s", resp.status, resp.body)
+	}
+	assertHeaders(t, resp, map[string]string{
+		"Access-Control-Allow-Origin":      dashboardOrigin,
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE",
+		"Access-Control-Allow-Headers":     "Content-Type, X-Api-Key, Idempotency-Key, If-None-Match",
+		"Access-Control-Max-Age":           "600",
+		"Vary":                             "Origin",
+	})
+
+	resp = ts.do(t, http.MethodOptions, "/api/v1/cars/1", "", map[string]string{
+		"Origin":                        "https://evil.example.com",
+		"Access-Control-Request-Method": http.MethodDelete,
+	})
+	if resp.status != http.StatusForbidden {
+		t.Errorf("status of other origin = %d, want 403", resp.status)
+	}
+	assertHeaders(t, resp, map[string]string{"Access-Control-Allow-Origin": ""})
+}
+
+func TestCORSRequest(t *testing.T) {
+	ts := newCORSServer(t)
+
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars/1", "", map[string]string{"Origin": dashboardOrigin})
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+vestaJSON+`,"error":null}`)
+	assertHeaders(t, resp, map[string]string{
+		"Access-Control-Allow-Origin":      dashboardOrigin,
+		"Access-Control-Allow-Credentials": "true",
+	})
+	if resp.header.Get("Access-Control-Expose-Headers") == "" {
+		t.Error("Access-Control-Expose-Headers is not set")
+	}
+
+	// requests of other origins are served, but browsers do not give responses to their scripts
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/1", "", map[string]string{"Origin": "https://evil.example.com"})
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+vestaJSON+`,"error":null}`)
+	assertHeaders(t, resp, map[string]string{"Access-Control-Allow-Origin": ""})
+}
+
+func TestCORSDisabled(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars/1", "", map[string]string{"Origin": dashboardOrigin})
+	assertHeaders(t, resp, map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""})
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	for _, path := range []string{"/api/v1/cars/1", "/api/v1/cars/100"} {
+		resp := ts.do(t, http.MethodGet, path, "", nil)
+		assertHeaders(t, resp, map[string]string{
+			"X-Content-Type-Options":    "nosniff",
+			"X-Frame-Options":           "DENY",
+			"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+			"Referrer-Policy":           "no-referrer",
+			"Strict-Transport-Security": "",
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/config"
+	"cars-service/internal/repo"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const deadlineJSON = `{"data":null,"error":"request deadline exceeded"}`
+
+func newHangingServer(t *testing.T) *testServer {
+	t.Helper()
+	cfg := config.Default().Server
+	cfg.RequestTimeout = 50 * time.Millisecond
+	return newTestServerWithConfig(t, hangingRepo{Repo: repo.NewMemoryRepo()}, cfg)
+}
+
+func TestRequestDeadline(t *testing.T) {
+	ts := newHangingServer(t)
+
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars/1", "", nil)
+	assertJSONResponse(t, resp, http.StatusGatewayTimeout, deadlineJSON)
+
+	// requests which do not hang are not affected
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars?limit=10&offset=0", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":[],"error":null}`)
+}
+
+func TestRequestDeadlineAddCars(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.LongRequestTimeout = 50 * time.Millisecond
+	ts := newTestServerWithConfig(t, nil, cfg)
+
+	// cars fetched before the deadline are not added without the rest
+	resp := ts.do(t, http.MethodPost, "/api/v1/cars", `{"regNums":["E555EE199","`+hangingRegNum+`"]}`, nil)
+	assertJSONResponse(t, resp, http.StatusGatewayTimeout, deadlineJSON)
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/3", "", nil)
+	assertJSONResponse(t, resp, http.StatusNotFound, carNotFoundJSON)
+}
+
+func TestRequestDeadlineStream(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.RequestTimeout = 50 * time.Millisecond
+	ts := newTestServerWithConfig(t, slowStreamRepo{Repo: repo.NewMemoryRepo(), delay: 100 * time.Millisecond}, cfg)
+
+	// streams of the whole catalog have the long deadline
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars", "", map[string]string{"Accept": "application/x-ndjson"})
+	if resp.status != http.StatusOK {
+		t.Errorf("status = %d, want %d, body: %s", resp.status, http.StatusOK, resp.body)
+	}
+}
+
+func TestRequestDeadlineGraphQL(t *testing.T) {
+	ts := newHangingServer(t)
+
+	resp := ts.do(t, http.MethodPost, "/api/v1/graphql", `{"query":"{ car(id: 1) { regNum } }"}`, nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"errors":[{"message":"request deadline exceeded",`+
+		`"extensions":{"code":"DEADLINE_EXCEEDED"}}]}`)
+}
+
+func TestRequestDeadlineGraphQLMutation(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.LongRequestTimeout = 50 * time.Millisecond
+	ts := newTestServerWithConfig(t, nil, cfg)
+
+	// mutations have the deadline of POST /cars
+	resp := ts.do(t, http.MethodPost, "/api/v1/graphql", `{"query":"mutation { addCars(regNums: [\"`+hangingRegNum+`\"]) { id } }"}`, nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"errors":[{"message":"request deadline exceeded",`+
+		`"extensions":{"code":"DEADLINE_EXCEEDED"}}]}`)
+}
@@ -0,0 +1,96 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/config"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const rioUpdateJSON = `{"regNum":"B222BB50","mark":"Kia","model":"Rio","year":2019,` +
+	`"owner":{"name":"Пётр","surname":"Петров","patronymic":"Петрович"}}`
+
+func assertNotModified(t *testing.T, resp response, etag string) {
+	t.Helper()
+	if resp.status != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304: %s", resp.status, resp.body)
+	}
+	if len(resp.body) != 0 {
+		t.Errorf("body of 304 = %s, want empty", resp.body)
+	}
+	if got := resp.header.Get("ETag"); got != etag {
+		t.Errorf("ETag = %q, want %q", got, etag)
+	}
+}
+
+func TestGetCarByIdETag(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars/2", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+rioJSON+`,"error":null}`)
+	etag := resp.header.Get("ETag")
+	if etag != `"2-1"` {
+		t.Errorf("ETag = %q, want \"2-1\"", etag)
+	}
+	if got := resp.header.Get("Cache-Control"); got != "private, no-cache" {
+		t.Errorf("Cache-Control = %q, want private, no-cache", got)
+	}
+
+	for _, ifNoneMatch := range []string{etag, `"1-1", W/` + etag, "*"} {
+		resp = ts.do(t, http.MethodGet, "/api/v1/cars/2", "", map[string]string{"If-None-Match": ifNoneMatch})
+		assertNotModified(t, resp, etag)
+	}
+
+	// the update makes the ETag stale
+	resp = ts.do(t, http.MethodPut, "/api/v1/cars/2", rioUpdateJSON, nil)
+	if resp.status != http.StatusOK {
+		t.Fatalf("PUT status = %d, want 200: %s", resp.status, resp.body)
+	}
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/2", "", map[string]string{"If-None-Match": etag})
+	if resp.status != http.StatusOK {
+		t.Fatalf("status after update = %d, want 200: %s", resp.status, resp.body)
+	}
+	if got := resp.header.Get("ETag"); got != `"2-2"` {
+		t.Errorf("ETag after update = %q, want \"2-2\"", got)
+	}
+}
+
+func TestGetCarsETag(t *testing.T) {
+	ts := newTestServer(t, nil)
+	path := "/api/v1/cars?limit=10&offset=0"
+
+	resp := ts.do(t, http.MethodGet, path, "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":[`+vestaJSON+`,`+rioJSON+`],"error":null}`)
+	etag := resp.header.Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag is not set")
+	}
+	if got := resp.header.Get("Vary"); got != "Accept" {
+		t.Errorf("Vary = %q, want Accept", got)
+	}
+	resp = ts.do(t, http.MethodGet, path, "", map[string]string{"If-None-Match": etag})
+	assertNotModified(t, resp, etag)
+
+	// a change of any car of the page changes the ETag
+	if resp = ts.do(t, http.MethodPut, "/api/v1/cars/2", rioUpdateJSON, nil); resp.status != http.StatusOK {
+		t.Fatalf("PUT status = %d, want 200: %s", resp.status, resp.body)
+	}
+	resp = ts.do(t, http.MethodGet, path, "", map[string]string{"If-None-Match": etag})
+	if resp.status != http.StatusOK {
+		t.Fatalf("status after update = %d, want 200: %s", resp.status, resp.body)
+	}
+	if got := resp.header.Get("ETag"); got == etag {
+		t.Errorf("ETag after update = %q, want another one", got)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.CacheMaxAge = time.Minute
+	ts := newTestServerWithConfig(t, nil, cfg)
+
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars/1", "", nil)
+	if got := resp.header.Get("Cache-Control"); got != "private, max-age=60" {
+		t.Errorf("Cache-Control = %q, want private, max-age=60", got)
+	}
+}
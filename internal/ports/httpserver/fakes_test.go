@@ -0,0 +1,521 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// testTime is the time of every record made by fakes, so responses can be compared as a whole
+var testTime = time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+// faultyRepo fails every method used by car routes with err
+type faultyRepo struct {
+	repo.Repo
+	err error
+}
+
+func (r faultyRepo) GetCarById(context.Context, uint64) (model.Car, error) {
+	return model.Car{}, r.err
+}
+
+func (r faultyRepo) GetCars(context.Context, model.Filter) ([]model.Car, error) {
+	return []model.Car{}, r.err
+}
+
+func (r faultyRepo) IterateCars(context.Context, model.Filter, func(model.Car) error) error {
+	return r.err
+}
+
+func (r faultyRepo) AddCar(context.Context, model.Car) (model.Car, error) {
+	return model.Car{}, r.err
+}
+
+func (r faultyRepo) UpdateCar(context.Context, uint64, model.Car) (model.Car, error) {
+	return model.Car{}, r.err
+}
+
+func (r faultyRepo) DeleteCar(context.Context, uint64) error {
+	return r.err
+}
+
+// filterRepo records the filter of the last page of cars
+type filterRepo struct {
+	repo.Repo
+	filter *model.Filter
+}
+
+func (r filterRepo) GetCars(ctx context.Context, filter model.Filter) ([]model.Car, error) {
+	*r.filter = filter
+	return r.Repo.GetCars(ctx, filter)
+}
+
+// panicRepo panics on reading a car to check that the server survives panics of handlers
+type panicRepo struct {
+	repo.Repo
+}
+
+func (r panicRepo) GetCarById(context.Context, uint64) (model.Car, error) {
+	panic("unexpected nil pointer")
+}
+
+// hangingRepo blocks on reading a car until ctx is done like the database which does not respond
+type hangingRepo struct {
+	repo.Repo
+}
+
+func (r hangingRepo) GetCarById(ctx context.Context, _ uint64) (model.Car, error) {
+	<-ctx.Done()
+	return model.Car{}, errors.Join(model.ErrDatabaseError, ctx.Err())
+}
+
+// slowStreamRepo starts iterating cars after delay like the database reading the whole catalog
+type slowStreamRepo struct {
+	repo.Repo
+	delay time.Duration
+}
+
+func (r slowStreamRepo) IterateCars(ctx context.Context, filter model.Filter, fn func(model.Car) error) error {
+	select {
+	case <-ctx.Done():
+		return errors.Join(model.ErrDatabaseError, ctx.Err())
+	case <-time.After(r.delay):
+	}
+	return r.Repo.IterateCars(ctx, filter, fn)
+}
+
+// jobRepo keeps import jobs in memory, all its methods fail with err if it is set
+type jobRepo struct {
+	mu     sync.Mutex
+	jobs   map[uint64]model.ImportJob
+	lastId uint64
+	err    error
+}
+
+func newJobRepo() *jobRepo {
+	return &jobRepo{
+		jobs: make(map[uint64]model.ImportJob),
+	}
+}
+
+func (r *jobRepo) CreateJob(_ context.Context, regNums []string) (model.ImportJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return model.ImportJob{}, r.err
+	}
+
+	r.lastId++
+	job := model.ImportJob{
+		Id:        r.lastId,
+		Status:    model.ImportPending,
+		Total:     len(regNums),
+		CreatedAt: testTime,
+		UpdatedAt: testTime,
+		Items:     make([]model.ImportItem, len(regNums)),
+	}
+	for i, regNum := range regNums {
+		job.Items[i] = model.ImportItem{
+			Id:     uint64(i + 1),
+			RegNum: regNum,
+			Status: model.ImportPending,
+		}
+	}
+	r.jobs[job.Id] = job
+	return job, nil
+}
+
+func (r *jobRepo) GetJob(_ context.Context, id uint64) (model.ImportJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return model.ImportJob{}, r.err
+	}
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return model.ImportJob{}, model.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (r *jobRepo) GetJobStatus(ctx context.Context, id uint64) (model.ImportStatus, error) {
+	job, err := r.GetJob(ctx, id)
+	return job.Status, err
+}
+
+func (r *jobRepo) SetJobStatus(_ context.Context, id uint64, status model.ImportStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	job, ok := r.jobs[id]
+	switch {
+	case !ok:
+		return model.ErrJobNotFound
+	case job.Status != model.ImportPending && job.Status != model.ImportRunning:
+		return model.ErrJobFinished
+	}
+	job.Status = status
+	r.jobs[id] = job
+	return nil
+}
+
+func (r *jobRepo) CancelJob(ctx context.Context, id uint64) error {
+	return r.SetJobStatus(ctx, id, model.ImportCancelled)
+}
+
+func (r *jobRepo) GetActiveJobs(context.Context) ([]model.ImportJob, error) {
+	return nil, r.err
+}
+
+func (r *jobRepo) GetPendingItems(context.Context, uint64, uint) ([]model.ImportItem, error) {
+	return nil, r.err
+}
+
+func (r *jobRepo) HasPendingItems(context.Context, uint64) (bool, error) {
+	return false, r.err
+}
+
+func (r *jobRepo) RetryItem(context.Context, uint64, time.Time) error {
+	return r.err
+}
+
+func (r *jobRepo) SetItemResult(context.Context, model.ImportItem) error {
+	return r.err
+}
+
+// webhookRepo keeps webhooks and their deliveries in memory, all its methods fail with err if it is set
+type webhookRepo struct {
+	mu             sync.Mutex
+	webhooks       map[uint64]model.Webhook
+	deliveries     []model.WebhookDelivery
+	lastId         uint64
+	lastDeliveryId uint64
+	err            error
+}
+
+func newWebhookRepo() *webhookRepo {
+	return &webhookRepo{
+		webhooks: make(map[uint64]model.Webhook),
+	}
+}
+
+func (r *webhookRepo) CreateWebhook(_ context.Context, webhook model.Webhook) (model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return model.Webhook{}, r.err
+	}
+
+	r.lastId++
+	webhook.Id = r.lastId
+	webhook.CreatedAt = testTime
+	r.webhooks[webhook.Id] = webhook
+	return webhook, nil
+}
+
+func (r *webhookRepo) GetWebhook(_ context.Context, id uint64) (model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return model.Webhook{}, r.err
+	}
+
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return model.Webhook{}, model.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (r *webhookRepo) GetWebhooks(context.Context) ([]model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.sortedWebhooks(), nil
+}
+
+func (r *webhookRepo) UpdateWebhook(_ context.Context, webhook model.Webhook) (model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return model.Webhook{}, r.err
+	}
+
+	prev, ok := r.webhooks[webhook.Id]
+	if !ok {
+		return model.Webhook{}, model.ErrWebhookNotFound
+	}
+	webhook.Secret = prev.Secret
+	webhook.CreatedAt = prev.CreatedAt
+	if !webhook.Active {
+		webhook.Failures = prev.Failures
+	}
+	r.webhooks[webhook.Id] = webhook
+	return webhook, nil
+}
+
+func (r *webhookRepo) DeleteWebhook(_ context.Context, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	if _, ok := r.webhooks[id]; !ok {
+		return model.ErrWebhookNotFound
+	}
+	delete(r.webhooks, id)
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(d model.WebhookDelivery) bool {
+		return d.WebhookId == id
+	})
+	return nil
+}
+
+func (r *webhookRepo) GetDeliveries(_ context.Context, webhookId uint64, limit uint) ([]model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// the latest deliveries go first
+	deliveries := []model.WebhookDelivery{}
+	for i := len(r.deliveries) - 1; i >= 0 && uint(len(deliveries)) < limit; i-- {
+		if r.deliveries[i].WebhookId == webhookId {
+			deliveries = append(deliveries, r.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepo) ClaimDeliveries(context.Context, uint, time.Time) ([]model.WebhookDelivery, error) {
+	return nil, r.err
+}
+
+func (r *webhookRepo) SetDeliveryResult(context.Context, model.WebhookDelivery, int) error {
+	return r.err
+}
+
+// addDelivery saves the pending delivery of the event like mutations of the postgres repo do
+func (r *webhookRepo) addDelivery(webhookId uint64, event model.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastDeliveryId++
+	r.deliveries = append(r.deliveries, model.WebhookDelivery{
+		Id:            r.lastDeliveryId,
+		WebhookId:     webhookId,
+		Event:         event,
+		Status:        model.DeliveryPending,
+		NextAttemptAt: testTime,
+		CreatedAt:     testTime,
+		UpdatedAt:     testTime,
+	})
+}
+
+// sortedWebhooks returns all webhooks ordered by id, the lock must be held
+func (r *webhookRepo) sortedWebhooks() []model.Webhook {
+	webhooks := make([]model.Webhook, 0, len(r.webhooks))
+	for _, webhook := range r.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	slices.SortFunc(webhooks, func(a, b model.Webhook) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return webhooks
+}
+
+// refreshRepo keeps refreshes in memory, all its methods fail with err if it is set
+type refreshRepo struct {
+	mu        sync.Mutex
+	refreshes map[uint64]model.Refresh
+	synced    map[uint64]time.Time
+	lastId    uint64
+	err       error
+}
+
+func newRefreshRepo() *refreshRepo {
+	return &refreshRepo{
+		refreshes: make(map[uint64]model.Refresh),
+		synced:    make(map[uint64]time.Time),
+	}
+}
+
+func (r *refreshRepo) GetStaleCars(context.Context, time.Time, uint) ([]model.Car, error) {
+	return nil, r.err
+}
+
+func (r *refreshRepo) SetCarSynced(_ context.Context, carId uint64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	r.synced[carId] = at
+	return nil
+}
+
+func (r *refreshRepo) AddRefresh(ctx context.Context, refresh model.Refresh, save func(ctx context.Context) (model.Car, error)) (model.Refresh, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return model.Refresh{}, r.err
+	}
+
+	if save != nil {
+		var err error
+		if refresh.Car, err = save(ctx); err != nil {
+			return model.Refresh{}, err
+		}
+	}
+	if refresh.Status == model.RefreshPending {
+		for id, other := range r.refreshes {
+			if other.CarId == refresh.CarId && other.Status == model.RefreshPending {
+				other.Status = model.RefreshSuperseded
+				other.ResolvedAt = testTime
+				r.refreshes[id] = other
+			}
+		}
+	} else {
+		refresh.ResolvedAt = testTime
+	}
+	r.lastId++
+	refresh.Id = r.lastId
+	refresh.CreatedAt = testTime
+	r.refreshes[refresh.Id] = refresh
+	return refresh, nil
+}
+
+func (r *refreshRepo) GetRefresh(_ context.Context, id uint64) (model.Refresh, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return model.Refresh{}, r.err
+	}
+
+	refresh, ok := r.refreshes[id]
+	if !ok {
+		return model.Refresh{}, model.ErrRefreshNotFound
+	}
+	return refresh, nil
+}
+
+func (r *refreshRepo) GetRefreshes(_ context.Context, status model.RefreshStatus, limit uint) ([]model.Refresh, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var refreshes []model.Refresh
+	for _, refresh := range r.refreshes {
+		if refresh.Status == status {
+			refreshes = append(refreshes, refresh)
+		}
+	}
+	slices.SortFunc(refreshes, func(a, b model.Refresh) int {
+		return cmp.Compare(b.Id, a.Id)
+	})
+	return refreshes[:min(uint(len(refreshes)), limit)], nil
+}
+
+func (r *refreshRepo) ResolveRefresh(_ context.Context, id uint64, status model.RefreshStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	refresh, ok := r.refreshes[id]
+	switch {
+	case !ok:
+		return model.ErrRefreshNotFound
+	case refresh.Status != model.RefreshPending:
+		return model.ErrRefreshResolved
+	}
+	refresh.Status = status
+	refresh.ResolvedAt = testTime
+	r.refreshes[id] = refresh
+	return nil
+}
+
+func (r *refreshRepo) ApplyRefresh(ctx context.Context, id uint64, save func(ctx context.Context) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	refresh, ok := r.refreshes[id]
+	switch {
+	case !ok:
+		return model.ErrRefreshNotFound
+	case refresh.Status != model.RefreshPending:
+		return model.ErrRefreshResolved
+	}
+	if err := save(ctx); err != nil {
+		return err
+	}
+	refresh.Status = model.RefreshApplied
+	refresh.ResolvedAt = testTime
+	r.refreshes[id] = refresh
+	return nil
+}
+
+// idempotencyRepo keeps requests with idempotency keys in memory, expiry is not needed by tests
+type idempotencyRepo struct {
+	mu       sync.Mutex
+	requests map[string]model.IdempotentRequest
+}
+
+func newIdempotencyRepo() *idempotencyRepo {
+	return &idempotencyRepo{
+		requests: make(map[string]model.IdempotentRequest),
+	}
+}
+
+func (r *idempotencyRepo) StartRequest(_ context.Context, key string, fingerprint string, expiresAt time.Time, _ time.Time) (model.IdempotentRequest, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if saved, ok := r.requests[key]; ok {
+		return saved, false, nil
+	}
+	req := model.IdempotentRequest{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   testTime,
+		ExpiresAt:   expiresAt,
+	}
+	r.requests[key] = req
+	return req, true, nil
+}
+
+func (r *idempotencyRepo) CompleteRequest(_ context.Context, req model.IdempotentRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	req.Completed = true
+	r.requests[req.Key] = req
+	return nil
+}
+
+func (r *idempotencyRepo) DeleteRequest(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.requests, key)
+	return nil
+}
@@ -0,0 +1,266 @@
+package httpserver
+
+import (
+	"bytes"
+	"cars-service/internal/app"
+	"cars-service/internal/model"
+	"cars-service/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const graphqlSchema = `
+	schema {
+		query: Query
+		mutation: Mutation
+	}
+
+	scalar Time
+
+	type Query {
+		car(id: ID!): Car
+		cars(filter: CarFilter, sort: CarSort, page: Page!): [Car!]!
+		owner(id: ID!): Owner
+	}
+
+	type Mutation {
+		addCars(regNums: [String!]!): [Car!]!
+		updateCar(id: ID!, input: CarInput!): Car!
+		deleteCar(id: ID!): ID!
+	}
+
+	type Car {
+		id: ID!
+		regNum: String!
+		mark: String!
+		model: String!
+		year: Int!
+		owner: Owner!
+		ownershipHistory: [Ownership!]!
+	}
+
+	type Owner {
+		id: ID!
+		name: String!
+		surname: String!
+		patronymic: String!
+		cars: [Car!]!
+	}
+
+	type Ownership {
+		owner: Owner!
+		from: Time!
+		till: Time
+	}
+
+	input CarFilter {
+		regNum: String
+		mark: String
+		model: String
+		year: Int
+		ownerName: String
+		ownerSurname: String
+		ownerPatronymic: String
+	}
+
+	enum CarSortField {
+		ID
+		REG_NUM
+		MARK
+		MODEL
+		YEAR
+	}
+
+	enum SortDirection {
+		ASC
+		DESC
+	}
+
+	input CarSort {
+		field: CarSortField!
+		direction: SortDirection = ASC
+	}
+
+	input Page {
+		limit: Int!
+		offset: Int = 0
+	}
+
+	input CarInput {
+		regNum: String!
+		mark: String!
+		model: String!
+		year: Int!
+		owner: OwnerInput!
+	}
+
+	input OwnerInput {
+		name: String!
+		surname: String!
+		patronymic: String = ""
+	}
+`
+
+// graphqlMaxDepth limits nesting of queries like owner { cars { owner { cars ... } } }
+const graphqlMaxDepth = 10
+
+// newGraphQLSchema parses the schema with resolvers calling a
+func newGraphQLSchema(a app.App, logs logger.Logger, maxBatchSize int) *graphql.Schema {
+	panics := &graphqlPanicHandler{logs: logs}
+	return graphql.MustParseSchema(graphqlSchema, &rootResolver{App: a, maxBatchSize: maxBatchSize},
+		graphql.MaxDepth(graphqlMaxDepth),
+		graphql.MaxParallelism(loaderMaxBatch),
+		graphql.Logger(panics),
+		graphql.PanicHandler(panics),
+	)
+}
+
+type graphqlRequest struct {
+	Query         string         `json:"query" binding:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// @Summary		GraphQL API
+// @Description	Выполняет GraphQL-запрос к каталогу: car(id), cars(filter, sort, page), owner(id) с автомобилями владельца и историей владения, а также мутации addCars, updateCar и deleteCar. Ошибки возвращаются в поле errors с кодом в extensions.code
+// @Accept			json
+// @Produce		json
+// @Param			input	body		graphqlRequest	true	"GraphQL-запрос"
+// @Success		200		{object}	object			"Результат запроса"
+// @Failure		400		{object}	carResponse		"Неверный формат входных данных"
+// @Failure		413		{object}	carResponse		"Слишком большое тело запроса"
+// @Router			/graphql [post]
+func handleGraphQL(schema *graphql.Schema, a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req graphqlRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortBodyError(c, err)
+			return
+		}
+
+		// resolvers run in goroutines, so the context of the request is used instead of gin.Context
+		ctx := withLoaders(c.Request.Context(), a)
+		resp := schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+		// graphql-go replaces the result by the error of the done context, it gets a code like other errors
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			for _, err := range resp.Errors {
+				if err.Extensions == nil && err.Message == context.DeadlineExceeded.Error() {
+					err.Message = model.ErrDeadline.Error()
+					err.Extensions = map[string]any{"code": "DEADLINE_EXCEEDED"}
+				}
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// graphqlMutationKey is the key of gin.Context which is true for documents with mutations
+const graphqlMutationKey = "graphqlMutation"
+
+// graphqlOperationMiddleware marks requests with mutations, so graphqlMutationMiddleware applies
+// to them the same limits as to REST routes changing cars. The body is limited by
+// bodyLimitMiddleware before and restored for the handler, invalid requests are left to it
+func graphqlOperationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortBodyError(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var req graphqlRequest
+		if json.Unmarshal(body, &req) == nil {
+			c.Set(graphqlMutationKey, hasGraphQLMutation(req.Query))
+		}
+		c.Next()
+	}
+}
+
+// graphqlMutationMiddleware applies mutation to requests marked by graphqlOperationMiddleware and
+// query to the rest
+func graphqlMutationMiddleware(query, mutation gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(graphqlMutationKey) {
+			mutation(c)
+			return
+		}
+		query(c)
+	}
+}
+
+// hasGraphQLMutation reports whether any operation of the document is a mutation. Only keywords
+// starting definitions at the top level are checked, names in selections, arguments, strings and
+// comments are skipped
+func hasGraphQLMutation(query string) bool {
+	depth := 0
+	definition := true
+	for i := 0; i < len(query); i++ {
+		switch ch := query[i]; {
+		case ch == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], `"""`):
+			end := strings.Index(strings.ReplaceAll(query[i+3:], `\"""`, "    "), `"""`)
+			if end < 0 {
+				return false
+			}
+			i += end + 5
+		case ch == '"':
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+		case ch == '{' || ch == '(' || ch == '[':
+			depth++
+			definition = false
+		case ch == '}' || ch == ')' || ch == ']':
+			depth--
+			// the next name at the top level starts the next definition
+			definition = depth == 0 && ch == '}'
+		case ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z':
+			start := i
+			for i+1 < len(query) && isGraphQLNameChar(query[i+1]) {
+				i++
+			}
+			if depth == 0 && definition {
+				if query[start:i+1] == "mutation" {
+					return true
+				}
+				definition = false
+			}
+		}
+	}
+	return false
+}
+
+func isGraphQLNameChar(ch byte) bool {
+	return ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9'
+}
+
+// graphqlPanicHandler logs panics of resolvers and hides their details from the client
+type graphqlPanicHandler struct {
+	logs logger.Logger
+}
+
+func (h *graphqlPanicHandler) LogPanic(_ context.Context, value any) {
+	h.logs.Error(logger.Fields{
+		"path": "/graphql",
+	}, fmt.Sprintf("panic: %v", value))
+}
+
+func (h *graphqlPanicHandler) MakePanicError(_ context.Context, _ any) *gqlerrors.QueryError {
+	err := gqlerrors.Errorf("%s", model.ErrServiceError.Error())
+	err.Extensions = map[string]any{"code": "INTERNAL"}
+	return err
+}
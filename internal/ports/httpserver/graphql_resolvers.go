@@ -0,0 +1,333 @@
+package httpserver
+
+import (
+	"cars-service/internal/app"
+	"cars-service/internal/model"
+	"context"
+	"errors"
+	"github.com/graph-gophers/graphql-go"
+	"strconv"
+)
+
+// rootResolver resolves queries and mutations of graphqlSchema
+type rootResolver struct {
+	app.App
+	// maxBatchSize limits regNums of addCars as in POST /cars
+	maxBatchSize int
+}
+
+type carFilterInput struct {
+	RegNum          *string
+	Mark            *string
+	Model           *string
+	Year            *int32
+	OwnerName       *string
+	OwnerSurname    *string
+	OwnerPatronymic *string
+}
+
+type carSortInput struct {
+	Field     string
+	Direction string
+}
+
+type pageInput struct {
+	Limit  int32
+	Offset int32
+}
+
+type carInput struct {
+	RegNum string
+	Mark   string
+	Model  string
+	Year   int32
+	Owner  ownerInput
+}
+
+type ownerInput struct {
+	Name       string
+	Surname    string
+	Patronymic string
+}
+
+// sortFields maps values of CarSortField enum to fields of model.Filter
+var sortFields = map[string]model.CarSortField{
+	"ID":      model.SortById,
+	"REG_NUM": model.SortByRegNum,
+	"MARK":    model.SortByMark,
+	"MODEL":   model.SortByModel,
+	"YEAR":    model.SortByYear,
+}
+
+func (r *rootResolver) Car(ctx context.Context, args struct{ Id graphql.ID }) (*carResolver, error) {
+	id, err := parseGraphQLId(args.Id)
+	if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	car, err := r.GetCarById(ctx, id)
+	if errors.Is(err, model.ErrCarNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	return &carResolver{car: car}, nil
+}
+
+func (r *rootResolver) Cars(ctx context.Context, args struct {
+	Filter *carFilterInput
+	Sort   *carSortInput
+	Page   pageInput
+}) ([]*carResolver, error) {
+	if args.Page.Limit < 0 || args.Page.Offset < 0 {
+		return nil, graphqlErrorOf(model.ErrInvalidInput)
+	}
+	filter := model.Filter{
+		Limit:  min(uint(args.Page.Limit), maxPageLimit),
+		Offset: uint(args.Page.Offset),
+	}
+	if f := args.Filter; f != nil {
+		filter.RegNum, filter.ByRegNum = deref(f.RegNum)
+		filter.Mark, filter.ByMark = deref(f.Mark)
+		filter.Model, filter.ByModel = deref(f.Model)
+		filter.OwnerName, filter.ByOwnerName = deref(f.OwnerName)
+		filter.OwnerSurname, filter.ByOwnerSurname = deref(f.OwnerSurname)
+		filter.OwnerPatronymic, filter.ByOwnerPatronymic = deref(f.OwnerPatronymic)
+		var year int32
+		year, filter.ByYear = deref(f.Year)
+		filter.Year = int(year)
+	}
+	if args.Sort != nil {
+		filter.SortBy = sortFields[args.Sort.Field]
+		filter.SortDesc = args.Sort.Direction == "DESC"
+	}
+
+	cars, err := r.GetCars(ctx, filter)
+	if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	return carsToResolvers(cars), nil
+}
+
+func (r *rootResolver) Owner(ctx context.Context, args struct{ Id graphql.ID }) (*ownerResolver, error) {
+	id, err := parseGraphQLId(args.Id)
+	if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	owner, err := r.GetOwnerById(ctx, id)
+	if errors.Is(err, model.ErrOwnerNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	return &ownerResolver{owner: owner}, nil
+}
+
+func (r *rootResolver) AddCars(ctx context.Context, args struct{ RegNums []string }) ([]*carResolver, error) {
+	if len(args.RegNums) > r.maxBatchSize {
+		return nil, &graphqlError{
+			err:     model.ErrBatchTooLarge,
+			code:    "BAD_USER_INPUT",
+			details: batchTooLargeResponse(r.maxBatchSize).Details,
+		}
+	}
+	cars, err := r.App.AddCars(ctx, args.RegNums)
+	if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	return carsToResolvers(cars), nil
+}
+
+func (r *rootResolver) UpdateCar(ctx context.Context, args struct {
+	Id    graphql.ID
+	Input carInput
+}) (*carResolver, error) {
+	id, err := parseGraphQLId(args.Id)
+	if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	car, err := r.App.UpdateCar(ctx, id, model.Car{
+		RegNum: args.Input.RegNum,
+		Mark:   args.Input.Mark,
+		Model:  args.Input.Model,
+		Year:   int(args.Input.Year),
+		Owner: model.Owner{
+			Name:       args.Input.Owner.Name,
+			Surname:    args.Input.Owner.Surname,
+			Patronymic: args.Input.Owner.Patronymic,
+		},
+	})
+	if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	return &carResolver{car: car}, nil
+}
+
+func (r *rootResolver) DeleteCar(ctx context.Context, args struct{ Id graphql.ID }) (graphql.ID, error) {
+	id, err := parseGraphQLId(args.Id)
+	if err != nil {
+		return "", graphqlErrorOf(err)
+	}
+	if err = r.App.DeleteCar(ctx, id); err != nil {
+		return "", graphqlErrorOf(err)
+	}
+	return args.Id, nil
+}
+
+type carResolver struct {
+	car model.Car
+}
+
+func carsToResolvers(cars []model.Car) []*carResolver {
+	res := make([]*carResolver, len(cars))
+	for i, car := range cars {
+		res[i] = &carResolver{car: car}
+	}
+	return res
+}
+
+func (r *carResolver) Id() graphql.ID {
+	return formatGraphQLId(r.car.Id)
+}
+
+func (r *carResolver) RegNum() string {
+	return r.car.RegNum
+}
+
+func (r *carResolver) Mark() string {
+	return r.car.Mark
+}
+
+func (r *carResolver) Model() string {
+	return r.car.Model
+}
+
+func (r *carResolver) Year() int32 {
+	return int32(r.car.Year)
+}
+
+func (r *carResolver) Owner() *ownerResolver {
+	return &ownerResolver{owner: r.car.Owner}
+}
+
+func (r *carResolver) OwnershipHistory(ctx context.Context) ([]*ownershipResolver, error) {
+	history, err := loadersFrom(ctx).ownershipHistory.Load(ctx, r.car.Id)
+	if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	res := make([]*ownershipResolver, len(history))
+	for i, o := range history {
+		res[i] = &ownershipResolver{ownership: o}
+	}
+	return res, nil
+}
+
+type ownerResolver struct {
+	owner model.Owner
+}
+
+func (r *ownerResolver) Id() graphql.ID {
+	return formatGraphQLId(r.owner.Id)
+}
+
+func (r *ownerResolver) Name() string {
+	return r.owner.Name
+}
+
+func (r *ownerResolver) Surname() string {
+	return r.owner.Surname
+}
+
+func (r *ownerResolver) Patronymic() string {
+	return r.owner.Patronymic
+}
+
+func (r *ownerResolver) Cars(ctx context.Context) ([]*carResolver, error) {
+	cars, err := loadersFrom(ctx).carsByOwner.Load(ctx, r.owner.Id)
+	if err != nil {
+		return nil, graphqlErrorOf(err)
+	}
+	return carsToResolvers(cars), nil
+}
+
+type ownershipResolver struct {
+	ownership model.Ownership
+}
+
+func (r *ownershipResolver) Owner() *ownerResolver {
+	return &ownerResolver{owner: r.ownership.Owner}
+}
+
+func (r *ownershipResolver) From() graphql.Time {
+	return graphql.Time{Time: r.ownership.From}
+}
+
+func (r *ownershipResolver) Till() *graphql.Time {
+	if r.ownership.Till.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: r.ownership.Till}
+}
+
+// graphqlError is returned by resolvers instead of errors of the app, so messages are the same
+// as in the REST API and the code of the error is added to extensions
+type graphqlError struct {
+	err     error
+	code    string
+	details []fieldErrorData
+}
+
+func (e *graphqlError) Error() string {
+	return e.err.Error()
+}
+
+func (e *graphqlError) Extensions() map[string]any {
+	ext := map[string]any{"code": e.code}
+	if len(e.details) != 0 {
+		ext["details"] = e.details
+	}
+	return ext
+}
+
+func graphqlErrorOf(err error) error {
+	switch {
+	case errors.Is(err, model.ErrValidation):
+		return &graphqlError{
+			err:     model.ErrValidation,
+			code:    "VALIDATION_ERROR",
+			details: validationErrorResponse(err).Details,
+		}
+	case errors.Is(err, model.ErrInvalidInput):
+		return &graphqlError{err: model.ErrInvalidInput, code: "BAD_USER_INPUT"}
+	case errors.Is(err, model.ErrCarNotFound):
+		return &graphqlError{err: model.ErrCarNotFound, code: "NOT_FOUND"}
+	case errors.Is(err, model.ErrDuplicateRegNum):
+		return &graphqlError{err: model.ErrDuplicateRegNum, code: "CONFLICT"}
+	case errors.Is(err, model.ErrDeadline) || errors.Is(err, context.DeadlineExceeded):
+		return &graphqlError{err: model.ErrDeadline, code: "DEADLINE_EXCEEDED"}
+	case errors.Is(err, model.ErrDatabaseError):
+		return &graphqlError{err: model.ErrDatabaseError, code: "INTERNAL"}
+	default:
+		return &graphqlError{err: model.ErrServiceError, code: "INTERNAL"}
+	}
+}
+
+func parseGraphQLId(id graphql.ID) (uint64, error) {
+	res, err := strconv.ParseUint(string(id), 10, 64)
+	if err != nil {
+		return 0, model.ErrInvalidInput
+	}
+	return res, nil
+}
+
+func formatGraphQLId(id uint64) graphql.ID {
+	return graphql.ID(strconv.FormatUint(id, 10))
+}
+
+// deref returns the value and true if v is set
+func deref[T any](v *T) (T, bool) {
+	if v == nil {
+		var zero T
+		return zero, false
+	}
+	return *v, true
+}
@@ -0,0 +1,88 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "query",
+			method: http.MethodPost,
+			path:   "/api/v1/graphql",
+			body:   `{"query":"{ car(id: 1) { regNum owner { surname cars { regNum } } } }"}`,
+			status: http.StatusOK,
+			want:   `{"data":{"car":{"regNum":"A111AA150","owner":{"surname":"Иванов","cars":[{"regNum":"A111AA150"}]}}}}`,
+		},
+		{
+			name:   "mutation",
+			method: http.MethodPost,
+			path:   "/api/v1/graphql",
+			body:   `{"query":"mutation($id: ID!) { deleteCar(id: $id) }","variables":{"id":"2"}}`,
+			status: http.StatusOK,
+			want:   `{"data":{"deleteCar":"2"}}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodPost,
+			path:   "/api/v1/graphql",
+			body:   `{"query":"{ car(id: 42) { regNum } }"}`,
+			status: http.StatusOK,
+			want:   `{"data":{"car":null}}`,
+		},
+		{
+			name:   "error",
+			method: http.MethodPost,
+			path:   "/api/v1/graphql",
+			body:   `{"query":"{ car(id: \"first\") { regNum } }"}`,
+			status: http.StatusOK,
+			want: `{"errors":[{"message":"invalid input","path":["car"],"extensions":{"code":"BAD_USER_INPUT"}}],` +
+				`"data":{"car":null}}`,
+		},
+		{
+			name:   "invalid body",
+			method: http.MethodPost,
+			path:   "/api/v1/graphql",
+			body:   `{"query":`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestGraphQLPageLimit(t *testing.T) {
+	var filter model.Filter
+	ts := newTestServer(t, filterRepo{Repo: repo.NewMemoryRepo(), filter: &filter})
+
+	// limits above the maximum of GET /cars are reduced to it
+	resp := ts.do(t, http.MethodPost, "/api/v1/graphql", `{"query":"{ cars(page: {limit: 2000000000, offset: 0}) { id } }"}`, nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":{"cars":[]}}`)
+	if filter.Limit != 1000 {
+		t.Errorf("limit = %d, want 1000", filter.Limit)
+	}
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars?limit=2000000000&offset=0", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":[],"error":null}`)
+	if filter.Limit != 1000 {
+		t.Errorf("limit = %d, want 1000", filter.Limit)
+	}
+
+	resp = ts.do(t, http.MethodPost, "/api/v1/graphql", `{"query":"{ cars(page: {limit: 10, offset: 0}) { id } }"}`, nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":{"cars":[]}}`)
+	if filter.Limit != 10 {
+		t.Errorf("limit = %d, want 10", filter.Limit)
+	}
+}
+
+func TestGraphQLDeadlineError(t *testing.T) {
+	ts := newTestServer(t, faultyRepo{err: errors.Join(model.ErrDatabaseError, context.DeadlineExceeded)})
+
+	// the timeout of the database has the code of the request deadline, not INTERNAL
+	resp := ts.do(t, http.MethodPost, "/api/v1/graphql", `{"query":"{ car(id: 1) { regNum } }"}`, nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"errors":[{"message":"request deadline exceeded","path":["car"],`+
+		`"extensions":{"code":"DEADLINE_EXCEEDED"}}],"data":{"car":null}}`)
+}
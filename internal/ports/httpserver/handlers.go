@@ -3,22 +3,31 @@ package httpserver
 import (
 	"cars-service/internal/app"
 	"cars-service/internal/model"
+	"cars-service/internal/spreadsheet"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 // @Summary		Получение информации об автомобиле по id
-// @Description	Возвращает информацию об автомобиле и его владельце, если автомобиль с указанным id существует
+// @Description	Возвращает информацию об автомобиле и его владельце, если автомобиль с указанным id существует. Ответ содержит ETag, который меняется при каждом изменении автомобиля, с заголовком If-None-Match неизменённый автомобиль не передаётся повторно
 // @Produce		json
-// @Param			id	path		int			true	"id автомобиля"
-// @Success		200	{object}	carResponse	"Успешное получение информации"
-// @Failure		400	{object}	carResponse	"Неверный формат входных данных"
-// @Failure		404	{object}	carResponse	"Автомобиль с указанным id не найден"
-// @Failure		500	{object}	carResponse	"Ошибка на стороне сервера"
+// @Param			id				path		int			true	"id автомобиля"
+// @Param			If-None-Match	header		string		false	"ETag ранее полученного ответа"
+// @Success		200				{object}	carResponse	"Успешное получение информации"
+// @Header			200				{string}	ETag		"Версия автомобиля"
+// @Success		304				"Автомобиль не изменился"
+// @Failure		400				{object}	carResponse	"Неверный формат входных данных"
+// @Failure		404				{object}	carResponse	"Автомобиль с указанным id не найден"
+// @Failure		500				{object}	carResponse	"Ошибка на стороне сервера"
+// @Failure		504				{object}	carResponse	"Превышено время обработки запроса"
 // @Router			/cars/{id} [get]
-func handleGetCarById(a app.App) gin.HandlerFunc {
+func handleGetCarById(a app.App, cacheControl string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 		if err != nil {
@@ -30,6 +39,9 @@ func handleGetCarById(a app.App) gin.HandlerFunc {
 
 		switch {
 		case err == nil:
+			if notModified(c, carETag(car), cacheControl) {
+				return
+			}
 			data := carToCarData(car)
 			c.JSON(http.StatusOK, carResponse{
 				Data: &data,
@@ -46,8 +58,9 @@ func handleGetCarById(a app.App) gin.HandlerFunc {
 }
 
 // @Summary		Получение списка автомобилей
-// @Description	Возвращает список автомобилей, поддерживаются фильтрация и пагинация
+// @Description	Возвращает список автомобилей, поддерживаются фильтрация и пагинация. С заголовком Accept: application/x-ndjson автомобили передаются потоком по одному JSON-объекту на строку, а limit и offset необязательны. JSON-ответ содержит ETag, который меняется при изменении любого автомобиля страницы, с заголовком If-None-Match неизменённая страница не передаётся повторно
 // @Produce		json
+// @Produce		application/x-ndjson
 // @Param			limit			query		int			true	"limit"
 // @Param			offset			query		int			true	"offset"
 // @Param			regNum			query		string		false	"Регистрационный номер"
@@ -56,43 +69,37 @@ func handleGetCarById(a app.App) gin.HandlerFunc {
 // @Param			ownerName		query string		false		"Имя владельца"
 // @Param			ownerSurname	query string		false		"Фамилия владельца"
 // @Param			ownerPatronymic	query string		false		"Отчество владельца"
+// @Param			If-None-Match	header		string		false	"ETag ранее полученного ответа"
 // @Success		200				{object}	carResponse	"Успешное получение информации"
+// @Header			200				{string}	ETag		"Версия страницы"
+// @Success		304				"Страница не изменилась"
 // @Failure		400				{object}	carResponse	"Неверный формат входных данных"
 // @Failure		500				{object}	carResponse	"Ошибка на стороне сервера"
+// @Failure		504				{object}	carResponse	"Превышено время обработки запроса"
 // @Router			/cars [get]
-func handleGetCars(a app.App) gin.HandlerFunc {
+func handleGetCars(a app.App, cacheControl string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		limit, err := strconv.Atoi(c.Query("limit"))
+		// pagination is optional for streaming, so the whole catalog can be dumped at once
+		stream := wantsStream(c)
+		filter, err := parseFilter(c, !stream)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
 			return
 		}
-		offset, err := strconv.Atoi(c.Query("offset"))
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+		if stream {
+			streamCars(c, a, filter)
 			return
 		}
-		var filter model.Filter
-		filter.Limit = uint(limit)
-		filter.Offset = uint(offset)
-		filter.RegNum, filter.ByRegNum = c.GetQuery("regNum")
-		filter.Mark, filter.ByMark = c.GetQuery("mark")
-		filter.Model, filter.ByModel = c.GetQuery("model")
-		filter.OwnerName, filter.ByOwnerName = c.GetQuery("ownerName")
-		filter.OwnerSurname, filter.ByOwnerSurname = c.GetQuery("ownerSurname")
-		filter.OwnerPatronymic, filter.ByOwnerPatronymic = c.GetQuery("ownerPatronymic")
-		if _, filter.ByYear = c.GetQuery("year"); filter.ByYear {
-			filter.Year, err = strconv.Atoi(c.Query("year"))
-			if err != nil {
-				c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
-				return
-			}
-		}
 
 		cars, err := a.GetCars(c, filter)
 
 		switch {
 		case err == nil:
+			// the same URL is streamed with another Accept
+			c.Writer.Header().Add("Vary", "Accept")
+			if notModified(c, carsETag(cars), cacheControl) {
+				return
+			}
 			data := carsToCarsData(cars)
 			c.JSON(http.StatusOK, carsResponse{
 				Data: data,
@@ -106,37 +113,91 @@ func handleGetCars(a app.App) gin.HandlerFunc {
 	}
 }
 
+const (
+	ndjsonContentType = "application/x-ndjson"
+
+	// ndjsonFlushEvery is a number of streamed cars after which the response is flushed
+	ndjsonFlushEvery = 100
+)
+
+// wantsStream reports whether the client accepts cars as newline delimited JSON rather than a page
+func wantsStream(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, ndjsonContentType) == ndjsonContentType
+}
+
+// streamCars writes filtered cars to the response as newline delimited JSON while they are read
+// from the database
+func streamCars(c *gin.Context, a app.App, filter model.Filter) {
+	c.Header("Content-Type", ndjsonContentType)
+	enc := json.NewEncoder(c.Writer)
+	n := 0
+	err := a.IterateCars(c, filter, func(car model.Car) error {
+		if err := enc.Encode(carToCarData(car)); err != nil {
+			return err
+		}
+		if n++; n%ndjsonFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		c.Status(http.StatusOK)
+	case c.Writer.Written():
+		// the status is already sent, so the client gets truncated stream
+		_ = c.Error(err)
+		c.Abort()
+	case errors.Is(err, model.ErrDatabaseError):
+		c.Header("Content-Type", "")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+	default:
+		c.Header("Content-Type", "")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+	}
+}
+
 // @Summary		Добавление новых автомобилей
-// @Description	Получает на вход номера автомобилей, выполняет запрос во внешний API для получения недостающих данных и добавляет информацию о новых автомобилях
+// @Description	Получает на вход номера автомобилей, выполняет запрос во внешний API для получения недостающих данных и добавляет информацию о новых автомобилях. Повторы номеров в запросе обрабатываются один раз и возвращаются в поле duplicates, при неверном формате любого из номеров автомобили не добавляются, а неверные номера перечисляются в поле details
 // @Accept			json
 // @Produce		json
-// @Param			input	body		addCarsRequest	true	"Регистрационные номера"
-// @Success		200		{object}	carsResponse	"Успешное добавление информации"
-// @Failure		400		{object}	carsResponse	"Неверный формат входных данных"
-// @Failure		409		{object}	carsResponse	"Попытка добавления существующего номера"
-// @Failure		500		{object}	carsResponse	"Ошибка на стороне сервера"
+// @Param			input	body		addCarsRequest		true	"Регистрационные номера"
+// @Success		200		{object}	addCarsResponse		"Успешное добавление информации"
+// @Failure		400		{object}	addCarsResponse		"Неверный формат входных данных или номеров, слишком много номеров"
+// @Failure		409		{object}	addCarsResponse		"Попытка добавления существующего номера"
+// @Failure		413		{object}	addCarsResponse		"Слишком большое тело запроса"
+// @Failure		500		{object}	addCarsResponse		"Ошибка на стороне сервера"
+// @Failure		504		{object}	addCarsResponse		"Превышено время обработки запроса"
 // @Router			/cars [post]
-func handleAddCars(a app.App) gin.HandlerFunc {
+func handleAddCars(a app.App, maxBatchSize int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req addCarsRequest
-		if err := c.BindJSON(&req); err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortBodyError(c, err)
+			return
+		}
+		if len(req.RegNums) > maxBatchSize {
+			c.AbortWithStatusJSON(http.StatusBadRequest, batchTooLargeResponse(maxBatchSize))
 			return
 		}
+		regNums, duplicates := uniqueRegNums(req.RegNums)
 
-		cars, err := a.AddCars(c, req.RegNums)
+		cars, err := a.AddCars(c, regNums)
 
 		switch {
 		case err == nil:
 			data := carsToCarsData(cars)
-			c.JSON(http.StatusOK, carsResponse{
-				Data: data,
-				Err:  nil,
+			c.JSON(http.StatusOK, addCarsResponse{
+				Data:       data,
+				Duplicates: duplicates,
+				Err:        nil,
 			})
 		case errors.Is(err, model.ErrDatabaseError):
 			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
 		case errors.Is(err, model.ErrDuplicateRegNum):
 			c.AbortWithStatusJSON(http.StatusConflict, errorResponse(model.ErrDuplicateRegNum))
+		case errors.Is(err, model.ErrValidation):
+			c.AbortWithStatusJSON(http.StatusBadRequest, validationErrorResponse(err))
 		default:
 			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
 		}
@@ -150,10 +211,12 @@ func handleAddCars(a app.App) gin.HandlerFunc {
 // @Param			id		path		int			true	"id автомобиля"
 // @Param			input	body		carData		true	"Новая информация об автомобиле"
 // @Success		200		{object}	carResponse	"Успешное изменение информации"
-// @Failure		400		{object}	carResponse	"Неверный формат входных данных"
+// @Failure		400		{object}	carResponse	"Неверный формат входных данных или ошибка валидации полей"
 // @Failure		404		{object}	carResponse	"Автомобиль с указанным id не найден"
 // @Failure		409		{object}	carResponse	"Попытка добавления существующего номера"
+// @Failure		413		{object}	carResponse	"Слишком большое тело запроса"
 // @Failure		500		{object}	carResponse	"Ошибка на стороне сервера"
+// @Failure		504		{object}	carResponse	"Превышено время обработки запроса"
 // @Router			/cars/{id} [put]
 func handleUpdateCar(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -163,8 +226,8 @@ func handleUpdateCar(a app.App) gin.HandlerFunc {
 			return
 		}
 		var req carData
-		if err = c.BindJSON(&req); err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+		if err = c.ShouldBindJSON(&req); err != nil {
+			abortBodyError(c, err)
 			return
 		}
 
@@ -194,7 +257,7 @@ func handleUpdateCar(a app.App) gin.HandlerFunc {
 		case errors.Is(err, model.ErrDuplicateRegNum):
 			c.AbortWithStatusJSON(http.StatusConflict, errorResponse(model.ErrDuplicateRegNum))
 		case errors.Is(err, model.ErrValidation):
-			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrValidation))
+			c.AbortWithStatusJSON(http.StatusBadRequest, validationErrorResponse(err))
 		default:
 			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
 		}
@@ -209,6 +272,7 @@ func handleUpdateCar(a app.App) gin.HandlerFunc {
 // @Failure		400	{object}	carResponse	"Неверный формат входных данных"
 // @Failure		404	{object}	carResponse	"Автомобиль с указанным id не найден"
 // @Failure		500	{object}	carResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	carResponse	"Превышено время обработки запроса"
 // @Router			/cars/{id} [delete]
 func handleDeleteCar(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -232,3 +296,680 @@ func handleDeleteCar(a app.App) gin.HandlerFunc {
 		}
 	}
 }
+
+// @Summary		Создание задачи массового импорта
+// @Description	Принимает номера автомобилей в формате JSON или CSV (первая колонка, заголовок regNum необязателен) и сразу возвращает задачу импорта, которая выполняется в фоне
+// @Accept			json
+// @Accept			text/csv
+// @Produce		json
+// @Param			input	body		addCarsRequest		true	"Регистрационные номера"
+// @Success		202		{object}	importJobResponse	"Задача импорта создана"
+// @Failure		400		{object}	importJobResponse	"Неверный формат входных данных"
+// @Failure		413		{object}	importJobResponse	"Слишком большое тело запроса"
+// @Failure		500		{object}	importJobResponse	"Ошибка на стороне сервера"
+// @Failure		504		{object}	importJobResponse	"Превышено время обработки запроса"
+// @Router			/imports [post]
+func handleCreateImportJob(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var regNums []string
+		if c.ContentType() == "text/csv" {
+			var err error
+			if regNums, err = parseRegNumsCSV(c.Request.Body); err != nil {
+				abortBodyError(c, err)
+				return
+			}
+		} else {
+			var req addCarsRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				abortBodyError(c, err)
+				return
+			}
+			regNums = req.RegNums
+		}
+
+		job, err := a.CreateImportJob(c, regNums)
+
+		switch {
+		case err == nil:
+			data := importJobToImportJobData(job)
+			c.JSON(http.StatusAccepted, importJobResponse{
+				Data: &data,
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrInvalidInput):
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Получение состояния задачи импорта
+// @Description	Возвращает прогресс задачи импорта и результат обработки каждого номера
+// @Produce		json
+// @Param			id	path		int					true	"id задачи импорта"
+// @Success		200	{object}	importJobResponse	"Успешное получение информации"
+// @Failure		400	{object}	importJobResponse	"Неверный формат входных данных"
+// @Failure		404	{object}	importJobResponse	"Задача импорта с указанным id не найдена"
+// @Failure		500	{object}	importJobResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	importJobResponse	"Превышено время обработки запроса"
+// @Router			/imports/{id} [get]
+func handleGetImportJob(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		job, err := a.GetImportJob(c, id)
+
+		switch {
+		case err == nil:
+			data := importJobToImportJobData(job)
+			c.JSON(http.StatusOK, importJobResponse{
+				Data: &data,
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrJobNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrJobNotFound))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Отмена задачи импорта
+// @Description	Останавливает обработку задачи импорта, уже добавленные автомобили остаются в каталоге
+// @Produce		json
+// @Param			id	path		int					true	"id задачи импорта"
+// @Success		200	{object}	importJobResponse	"Задача импорта отменена"
+// @Failure		400	{object}	importJobResponse	"Неверный формат входных данных"
+// @Failure		404	{object}	importJobResponse	"Задача импорта с указанным id не найдена"
+// @Failure		409	{object}	importJobResponse	"Задача импорта уже завершена"
+// @Failure		500	{object}	importJobResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	importJobResponse	"Превышено время обработки запроса"
+// @Router			/imports/{id}/cancel [post]
+func handleCancelImportJob(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		job, err := a.CancelImportJob(c, id)
+
+		switch {
+		case err == nil:
+			data := importJobToImportJobData(job)
+			c.JSON(http.StatusOK, importJobResponse{
+				Data: &data,
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrJobNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrJobNotFound))
+		case errors.Is(err, model.ErrJobFinished):
+			c.AbortWithStatusJSON(http.StatusConflict, errorResponse(model.ErrJobFinished))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Выгрузка каталога автомобилей
+// @Description	Выгружает отфильтрованный каталог в формате CSV или XLSX, фильтры такие же, как у получения списка автомобилей, limit и offset необязательны
+// @Produce		text/csv
+// @Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param			format			query		string		false	"Формат файла: csv (по умолчанию) или xlsx"
+// @Param			limit			query		int			false	"limit"
+// @Param			offset			query		int			false	"offset"
+// @Param			regNum			query		string		false	"Регистрационный номер"
+// @Param			mark			query		string		false	"Марка"
+// @Param			model			query		string		false	"Модель"
+// @Param			ownerName		query		string		false	"Имя владельца"
+// @Param			ownerSurname	query		string		false	"Фамилия владельца"
+// @Param			ownerPatronymic	query		string		false	"Отчество владельца"
+// @Success		200				{file}		file		"Файл с каталогом"
+// @Failure		400				{object}	carsResponse	"Неверный формат входных данных"
+// @Failure		500				{object}	carsResponse	"Ошибка на стороне сервера"
+// @Failure		504				{object}	carsResponse	"Превышено время обработки запроса"
+// @Router			/cars/export [get]
+func handleExportCars(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseFilter(c, false)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		switch c.DefaultQuery("format", "csv") {
+		case "csv":
+			// rows are written to the response while they are read from the database
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename="cars.csv"`)
+			w := csv.NewWriter(c.Writer)
+			_ = w.Write(spreadsheet.Columns)
+			err = a.IterateCars(c, filter, func(car model.Car) error {
+				return w.Write(spreadsheet.Record(car))
+			})
+			if err == nil {
+				w.Flush()
+				err = w.Error()
+			}
+		case "xlsx":
+			var w *spreadsheet.XLSXWriter
+			if w, err = spreadsheet.NewXLSXWriter(); err == nil {
+				defer func() {
+					_ = w.Close()
+				}()
+				err = a.IterateCars(c, filter, w.Write)
+			}
+			if err == nil {
+				c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+				c.Header("Content-Disposition", `attachment; filename="cars.xlsx"`)
+				_, err = w.WriteTo(c.Writer)
+			}
+		default:
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		switch {
+		case err == nil:
+		case c.Writer.Written():
+			// the status is already sent, so the client gets truncated file
+			_ = c.Error(err)
+			c.Abort()
+		case errors.Is(err, model.ErrDatabaseError):
+			clearFileHeaders(c)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			clearFileHeaders(c)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Загрузка автомобилей из файла
+// @Description	Принимает файл CSV или XLSX с заголовком (колонки как при выгрузке, обязательна только regNum). Строки со всеми заполненными полями добавляются без запроса во внешний API, для остальных недостающие данные запрашиваются во внешнем API. Возвращает результат обработки каждой строки
+// @Accept			multipart/form-data
+// @Produce		json
+// @Param			file	formData	file				true	"Файл CSV или XLSX"
+// @Success		200		{object}	importRowsResponse	"Файл обработан"
+// @Failure		400		{object}	importRowsResponse	"Неверный формат входных данных"
+// @Failure		413		{object}	importRowsResponse	"Слишком большое тело запроса"
+// @Failure		504		{object}	importRowsResponse	"Превышено время обработки запроса"
+// @Router			/cars/import [post]
+func handleImportCars(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			abortBodyError(c, err)
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		rows, err := spreadsheet.Read(file, fileHeader.Filename)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		// only successfully parsed rows are imported, the rest are reported as failed
+		cars := make([]model.Car, 0, len(rows))
+		for _, row := range rows {
+			if row.Err == nil {
+				cars = append(cars, row.Car)
+			}
+		}
+		items := a.ImportCars(c, cars)
+
+		data := make([]importRowData, len(rows))
+		for i, row := range rows {
+			data[i] = importRowData{
+				Row:    row.Line,
+				RegNum: row.Car.RegNum,
+			}
+			if row.Err != nil {
+				data[i].Status = string(model.ImportFailed)
+				data[i].Error = row.Err.Error()
+				continue
+			}
+			data[i].Status = string(items[0].Status)
+			data[i].CarId = items[0].CarId
+			data[i].Error = items[0].Error
+			items = items[1:]
+		}
+		c.JSON(http.StatusOK, importRowsResponse{
+			Data: data,
+			Err:  nil,
+		})
+	}
+}
+
+// abortBodyError aborts the request whose body failed to be read or parsed, bodies above the limit
+// of bodyLimitMiddleware are reported with 413 and the rest with 400
+func abortBodyError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errorResponse(model.ErrBodyTooLarge))
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+}
+
+// notModified sets ETag and Cache-Control of the response and reports whether If-None-Match of the
+// request matches etag, the response is 304 without body then
+func notModified(c *gin.Context, etag string, cacheControl string) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl)
+	if !etagMatches(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether If-None-Match lists etag, tags are compared weakly as RFC 9110 requires
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// clearFileHeaders removes headers of the file download before the error response is written
+func clearFileHeaders(c *gin.Context) {
+	c.Header("Content-Type", "")
+	c.Header("Content-Disposition", "")
+}
+
+// @Summary		Создание подписки на события
+// @Description	Регистрирует URL, на который будут отправляться события car.created, car.updated, car.deleted и owner.changed. Тело запроса подписывается HMAC-SHA256 с секретом подписки (заголовок X-Webhook-Signature), секрет генерируется, если не передан, и возвращается только при создании
+// @Accept			json
+// @Produce		json
+// @Param			input	body		webhookRequest	true	"Параметры подписки"
+// @Success		201		{object}	webhookResponse	"Подписка создана"
+// @Failure		400		{object}	webhookResponse	"Неверный формат входных данных или ошибка валидации полей"
+// @Failure		413		{object}	webhookResponse	"Слишком большое тело запроса"
+// @Failure		500		{object}	webhookResponse	"Ошибка на стороне сервера"
+// @Failure		504		{object}	webhookResponse	"Превышено время обработки запроса"
+// @Router			/webhooks [post]
+func handleCreateWebhook(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req webhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortBodyError(c, err)
+			return
+		}
+
+		webhook, err := a.CreateWebhook(c, req.toWebhook())
+
+		switch {
+		case err == nil:
+			data := webhookToWebhookData(webhook, true)
+			c.JSON(http.StatusCreated, webhookResponse{
+				Data: &data,
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrValidation):
+			c.AbortWithStatusJSON(http.StatusBadRequest, validationErrorResponse(err))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Получение списка подписок на события
+// @Produce		json
+// @Success		200	{object}	webhooksResponse	"Успешное получение информации"
+// @Failure		500	{object}	webhooksResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	webhooksResponse	"Превышено время обработки запроса"
+// @Router			/webhooks [get]
+func handleGetWebhooks(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhooks, err := a.GetWebhooks(c)
+
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, webhooksResponse{
+				Data: webhooksToWebhooksData(webhooks),
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Получение подписки на события по id
+// @Produce		json
+// @Param			id	path		int				true	"id подписки"
+// @Success		200	{object}	webhookResponse	"Успешное получение информации"
+// @Failure		400	{object}	webhookResponse	"Неверный формат входных данных"
+// @Failure		404	{object}	webhookResponse	"Подписка с указанным id не найдена"
+// @Failure		500	{object}	webhookResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	webhookResponse	"Превышено время обработки запроса"
+// @Router			/webhooks/{id} [get]
+func handleGetWebhook(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		webhook, err := a.GetWebhook(c, id)
+
+		switch {
+		case err == nil:
+			data := webhookToWebhookData(webhook, false)
+			c.JSON(http.StatusOK, webhookResponse{
+				Data: &data,
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrWebhookNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrWebhookNotFound))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Изменение подписки на события
+// @Description	Изменяет URL, события и фильтры подписки, секрет не изменяется. Повторное включение отключённой подписки сбрасывает счётчик ошибок
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int				true	"id подписки"
+// @Param			input	body		webhookRequest	true	"Новые параметры подписки"
+// @Success		200		{object}	webhookResponse	"Подписка изменена"
+// @Failure		400		{object}	webhookResponse	"Неверный формат входных данных или ошибка валидации полей"
+// @Failure		404		{object}	webhookResponse	"Подписка с указанным id не найдена"
+// @Failure		413		{object}	webhookResponse	"Слишком большое тело запроса"
+// @Failure		500		{object}	webhookResponse	"Ошибка на стороне сервера"
+// @Failure		504		{object}	webhookResponse	"Превышено время обработки запроса"
+// @Router			/webhooks/{id} [put]
+func handleUpdateWebhook(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+		var req webhookRequest
+		if err = c.ShouldBindJSON(&req); err != nil {
+			abortBodyError(c, err)
+			return
+		}
+
+		webhook, err := a.UpdateWebhook(c, id, req.toWebhook())
+
+		switch {
+		case err == nil:
+			data := webhookToWebhookData(webhook, false)
+			c.JSON(http.StatusOK, webhookResponse{
+				Data: &data,
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrValidation):
+			c.AbortWithStatusJSON(http.StatusBadRequest, validationErrorResponse(err))
+		case errors.Is(err, model.ErrWebhookNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrWebhookNotFound))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Удаление подписки на события
+// @Description	Удаляет подписку вместе с журналом её доставок
+// @Produce		json
+// @Param			id	path		int				true	"id подписки"
+// @Success		200	{object}	webhookResponse	"Подписка удалена"
+// @Failure		400	{object}	webhookResponse	"Неверный формат входных данных"
+// @Failure		404	{object}	webhookResponse	"Подписка с указанным id не найдена"
+// @Failure		500	{object}	webhookResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	webhookResponse	"Превышено время обработки запроса"
+// @Router			/webhooks/{id} [delete]
+func handleDeleteWebhook(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		err = a.DeleteWebhook(c, id)
+
+		switch {
+		case err == nil:
+			c.AbortWithStatusJSON(http.StatusOK, errorResponse(nil))
+		case errors.Is(err, model.ErrWebhookNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrWebhookNotFound))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Журнал доставок подписки
+// @Description	Возвращает последние доставки событий подписки с количеством попыток, кодом ответа и ошибкой последней попытки
+// @Produce		json
+// @Param			id	path		int					true	"id подписки"
+// @Success		200	{object}	deliveriesResponse	"Успешное получение информации"
+// @Failure		400	{object}	deliveriesResponse	"Неверный формат входных данных"
+// @Failure		404	{object}	deliveriesResponse	"Подписка с указанным id не найдена"
+// @Failure		500	{object}	deliveriesResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	deliveriesResponse	"Превышено время обработки запроса"
+// @Router			/webhooks/{id}/deliveries [get]
+func handleGetWebhookDeliveries(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		deliveries, err := a.GetWebhookDeliveries(c, id)
+
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, deliveriesResponse{
+				Data: deliveriesToDeliveriesData(deliveries),
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrWebhookNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrWebhookNotFound))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Обновление данных автомобиля из внешнего API
+// @Description	Повторно запрашивает данные автомобиля у поставщиков и возвращает отличия от сохранённых данных. Изменения применяются сразу, если apply=true, иначе сохраняются для проверки. Если отличий нет, возвращается статус unchanged
+// @Produce		json
+// @Param			id		path		int				true	"id автомобиля"
+// @Param			apply	query		bool			false	"Применить изменения сразу"
+// @Success		200		{object}	refreshResponse	"Данные обновлены"
+// @Failure		400		{object}	refreshResponse	"Неверный формат входных данных"
+// @Failure		404		{object}	refreshResponse	"Автомобиль с указанным id не найден"
+// @Failure		409		{object}	refreshResponse	"Автомобиль изменён другим запросом во время обновления"
+// @Failure		500		{object}	refreshResponse	"Ошибка на стороне сервера"
+// @Failure		502		{object}	refreshResponse	"Ошибка внешнего API"
+// @Failure		504		{object}	refreshResponse	"Превышено время обработки запроса"
+// @Router			/cars/{id}/refresh [post]
+func handleRefreshCar(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+		apply, err := strconv.ParseBool(c.DefaultQuery("apply", "false"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		refresh, err := a.RefreshCar(c, id, apply)
+
+		switch {
+		case err == nil:
+			data := refreshToRefreshData(refresh)
+			c.JSON(http.StatusOK, refreshResponse{
+				Data: &data,
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrCarNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrCarNotFound))
+		case errors.Is(err, model.ErrCarChanged):
+			c.AbortWithStatusJSON(http.StatusConflict, errorResponse(model.ErrCarChanged))
+		// data of providers which does not pass validation is an error of the outer API too
+		case errors.Is(err, model.ErrApiError), errors.Is(err, model.ErrValidation):
+			c.AbortWithStatusJSON(http.StatusBadGateway, errorResponse(model.ErrApiError))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Получение списка обновлений данных автомобилей
+// @Description	Возвращает последние обновления с указанным статусом, по умолчанию ожидающие проверки
+// @Produce		json
+// @Param			status	query		string				false	"Статус: pending (по умолчанию), applied, rejected или superseded"
+// @Success		200		{object}	refreshesResponse	"Успешное получение информации"
+// @Failure		400		{object}	refreshesResponse	"Неверный формат входных данных"
+// @Failure		500		{object}	refreshesResponse	"Ошибка на стороне сервера"
+// @Failure		504		{object}	refreshesResponse	"Превышено время обработки запроса"
+// @Router			/refreshes [get]
+func handleGetRefreshes(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := model.RefreshStatus(c.DefaultQuery("status", string(model.RefreshPending)))
+
+		refreshes, err := a.GetRefreshes(c, status)
+
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, refreshesResponse{
+				Data: refreshesToRefreshesData(refreshes),
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrInvalidInput):
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
+
+// @Summary		Получение обновления данных автомобиля по id
+// @Produce		json
+// @Param			id	path		int				true	"id обновления"
+// @Success		200	{object}	refreshResponse	"Успешное получение информации"
+// @Failure		400	{object}	refreshResponse	"Неверный формат входных данных"
+// @Failure		404	{object}	refreshResponse	"Обновление с указанным id не найдено"
+// @Failure		500	{object}	refreshResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	refreshResponse	"Превышено время обработки запроса"
+// @Router			/refreshes/{id} [get]
+func handleGetRefresh(a app.App) gin.HandlerFunc {
+	return handleRefreshAction(a.GetRefresh)
+}
+
+// @Summary		Применение обновления данных автомобиля
+// @Description	Применяет изменения ожидающего проверки обновления к автомобилю
+// @Produce		json
+// @Param			id	path		int				true	"id обновления"
+// @Success		200	{object}	refreshResponse	"Изменения применены"
+// @Failure		400	{object}	refreshResponse	"Неверный формат входных данных"
+// @Failure		404	{object}	refreshResponse	"Обновление или автомобиль не найдены"
+// @Failure		409	{object}	refreshResponse	"Обновление уже применено или отклонено либо автомобиль изменён другим запросом"
+// @Failure		500	{object}	refreshResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	refreshResponse	"Превышено время обработки запроса"
+// @Router			/refreshes/{id}/apply [post]
+func handleApplyRefresh(a app.App) gin.HandlerFunc {
+	return handleRefreshAction(a.ApplyRefresh)
+}
+
+// @Summary		Отклонение обновления данных автомобиля
+// @Description	Отклоняет изменения ожидающего проверки обновления, данные автомобиля не изменяются
+// @Produce		json
+// @Param			id	path		int				true	"id обновления"
+// @Success		200	{object}	refreshResponse	"Изменения отклонены"
+// @Failure		400	{object}	refreshResponse	"Неверный формат входных данных"
+// @Failure		404	{object}	refreshResponse	"Обновление с указанным id не найдено"
+// @Failure		409	{object}	refreshResponse	"Обновление уже применено или отклонено"
+// @Failure		500	{object}	refreshResponse	"Ошибка на стороне сервера"
+// @Failure		504	{object}	refreshResponse	"Превышено время обработки запроса"
+// @Router			/refreshes/{id}/reject [post]
+func handleRejectRefresh(a app.App) gin.HandlerFunc {
+	return handleRefreshAction(a.RejectRefresh)
+}
+
+// handleRefreshAction returns handler of the refresh with id from the path, the handlers differ
+// only in the method of App
+func handleRefreshAction(action func(ctx context.Context, id uint64) (model.Refresh, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(model.ErrInvalidInput))
+			return
+		}
+
+		refresh, err := action(c, id)
+
+		switch {
+		case err == nil:
+			data := refreshToRefreshData(refresh)
+			c.JSON(http.StatusOK, refreshResponse{
+				Data: &data,
+				Err:  nil,
+			})
+		case errors.Is(err, model.ErrRefreshNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrRefreshNotFound))
+		case errors.Is(err, model.ErrCarNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(model.ErrCarNotFound))
+		case errors.Is(err, model.ErrRefreshResolved):
+			c.AbortWithStatusJSON(http.StatusConflict, errorResponse(model.ErrRefreshResolved))
+		case errors.Is(err, model.ErrCarChanged):
+			c.AbortWithStatusJSON(http.StatusConflict, errorResponse(model.ErrCarChanged))
+		case errors.Is(err, model.ErrDatabaseError):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrServiceError))
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/config"
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIdempotentAddCars(t *testing.T) {
+	ts := newTestServer(t, nil)
+	header := map[string]string{"Idempotency-Key": "add-audi"}
+	want := `{"data":[` + audiJSON + `],"error":null}`
+
+	resp := ts.do(t, http.MethodPost, "/api/v1/cars", `{"regNums":["E555EE199"]}`, header)
+	assertJSONResponse(t, resp, http.StatusOK, want)
+	if got := resp.header.Get("Idempotent-Replayed"); got != "" {
+		t.Errorf("Idempotent-Replayed of the first request = %q, want empty", got)
+	}
+
+	// the retry gets the first response instead of 409 of the duplicate
+	resp = ts.do(t, http.MethodPost, "/api/v1/cars", `{"regNums":["E555EE199"]}`, header)
+	assertJSONResponse(t, resp, http.StatusOK, want)
+	if got := resp.header.Get("Idempotent-Replayed"); got != "true" {
+		t.Errorf("Idempotent-Replayed = %q, want true", got)
+	}
+
+	resp = ts.do(t, http.MethodPost, "/api/v1/cars", `{"regNums":["E555EE199"]}`, nil)
+	assertJSONResponse(t, resp, http.StatusConflict, duplicateRegNumJSON)
+}
+
+func TestIdempotencyKeyMismatch(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.RateLimit.Keys = []string{"other"}
+	cfg.TrustedProxies = []string{"127.0.0.1"}
+	ts := newTestServerWithConfig(t, nil, cfg)
+	header := map[string]string{"Idempotency-Key": "update"}
+	car := func(year string) string {
+		return `{"regNum":"B222BB50","mark":"Kia","model":"Rio","year":` + year +
+			`,"owner":{"name":"Сидор","surname":"Сидоров"}}`
+	}
+
+	resp := ts.do(t, http.MethodPut, "/api/v1/cars/2", car("2018"), header)
+	if resp.status != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.status, resp.body)
+	}
+	resp = ts.do(t, http.MethodPut, "/api/v1/cars/2", car("2019"), header)
+	assertJSONResponse(t, resp, http.StatusUnprocessableEntity, `{"data":null,"error":"idempotency key is used with another request"}`)
+	resp = ts.do(t, http.MethodPut, "/api/v1/cars/3", car("2018"), header)
+	assertJSONResponse(t, resp, http.StatusUnprocessableEntity, `{"data":null,"error":"idempotency key is used with another request"}`)
+
+	// keys of clients without known API keys are scoped by their IP
+	header["X-Api-Key"] = "unknown"
+	resp = ts.do(t, http.MethodPut, "/api/v1/cars/2", car("2019"), header)
+	assertJSONResponse(t, resp, http.StatusUnprocessableEntity, `{"data":null,"error":"idempotency key is used with another request"}`)
+	header["X-Forwarded-For"] = "203.0.113.7"
+	resp = ts.do(t, http.MethodPut, "/api/v1/cars/2", car("2018"), header)
+	if resp.status != http.StatusOK || resp.header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("status of client from another IP = %d, replayed %q, want 200 without replay: %s",
+			resp.status, resp.header.Get("Idempotent-Replayed"), resp.body)
+	}
+	delete(header, "X-Forwarded-For")
+
+	// keys of clients with other API keys are independent
+	header["X-Api-Key"] = "other"
+	resp = ts.do(t, http.MethodPut, "/api/v1/cars/2", car("2019"), header)
+	if resp.status != http.StatusOK {
+		t.Errorf("status of other client = %d, want 200: %s", resp.status, resp.body)
+	}
+}
+
+func TestIdempotentDelete(t *testing.T) {
+	ts := newTestServer(t, nil)
+	header := map[string]string{"Idempotency-Key": "delete"}
+
+	resp := ts.do(t, http.MethodDelete, "/api/v1/cars/2", "", header)
+	assertJSONResponse(t, resp, http.StatusOK, okJSON)
+	resp = ts.do(t, http.MethodDelete, "/api/v1/cars/2", "", header)
+	assertJSONResponse(t, resp, http.StatusOK, okJSON)
+
+	// the request in progress is not processed twice
+	ts.idempotency.mu.Lock()
+	for key, req := range ts.idempotency.requests {
+		req.Completed = false
+		ts.idempotency.requests[key] = req
+	}
+	ts.idempotency.mu.Unlock()
+	resp = ts.do(t, http.MethodDelete, "/api/v1/cars/2", "", header)
+	assertJSONResponse(t, resp, http.StatusConflict, `{"data":null,"error":"request with the idempotency key is in progress"}`)
+}
+
+func TestIdempotencyErrorsAreNotSaved(t *testing.T) {
+	ts := newTestServer(t, faultyRepo{Repo: repo.NewMemoryRepo(), err: model.ErrDatabaseError})
+	header := map[string]string{"Idempotency-Key": "faulty"}
+
+	for i := 0; i < 2; i++ {
+		resp := ts.do(t, http.MethodDelete, "/api/v1/cars/1", "", header)
+		assertJSONResponse(t, resp, http.StatusInternalServerError, databaseErrorJSON)
+		if got := resp.header.Get("Idempotent-Replayed"); got != "" {
+			t.Errorf("Idempotent-Replayed = %q, want empty", got)
+		}
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	ts := newTestServer(t, nil)
+	header := map[string]string{"Idempotency-Key": strings.Repeat("k", 256)}
+
+	resp := ts.do(t, http.MethodDelete, "/api/v1/cars/2", "", header)
+	assertJSONResponse(t, resp, http.StatusBadRequest, `{"data":null,"error":"invalid input",`+
+		`"details":[{"field":"Idempotency-Key","reason":"must be at most 255 characters"}]}`)
+}
+
+func TestIdempotencyBodyLimit(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.MaxBodySize = 16
+	ts := newTestServerWithConfig(t, nil, cfg)
+	header := map[string]string{"Idempotency-Key": "delete"}
+
+	// bodies of routes which do not use them are limited too, as they are read to be compared
+	resp := ts.do(t, http.MethodDelete, "/api/v1/cars/2", strings.Repeat("x", 17), header)
+	assertJSONResponse(t, resp, http.StatusRequestEntityTooLarge, bodyTooLargeJSON)
+}
@@ -0,0 +1,130 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"net/http"
+	"testing"
+)
+
+// createJob adds the job with id 1 for E555EE199
+func createJob(t *testing.T, ts *testServer) {
+	t.Helper()
+	if _, err := ts.jobs.CreateJob(context.Background(), []string{"E555EE199"}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+}
+
+const (
+	jobNotFoundJSON = `{"data":null,"error":"import job not found"}`
+	pendingJobJSON  = `{"data":{"id":1,"status":"pending","total":1,"processed":0,"succeeded":0,"failed":0,` +
+		`"createdAt":"2024-05-01T12:00:00Z","updatedAt":"2024-05-01T12:00:00Z",` +
+		`"items":[{"regNum":"E555EE199","status":"pending"}]},"error":null}`
+)
+
+func TestCreateImportJob(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "json",
+			method: http.MethodPost,
+			path:   "/api/v1/imports",
+			body:   `{"regNums":["E555EE199"," "]}`,
+			status: http.StatusAccepted,
+			want:   pendingJobJSON,
+		},
+		{
+			name:   "csv",
+			method: http.MethodPost,
+			path:   "/api/v1/imports",
+			body:   "regNum\nE555EE199\n",
+			header: map[string]string{"Content-Type": "text/csv"},
+			status: http.StatusAccepted,
+			want:   pendingJobJSON,
+		},
+		{
+			name:   "no regNums",
+			method: http.MethodPost,
+			path:   "/api/v1/imports",
+			body:   `{"regNums":[" "]}`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+		{
+			name:   "invalid body",
+			method: http.MethodPost,
+			path:   "/api/v1/imports",
+			body:   `["E555EE199"]`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestGetImportJob(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "found",
+			setup:  createJob,
+			method: http.MethodGet,
+			path:   "/api/v1/imports/1",
+			status: http.StatusOK,
+			want:   pendingJobJSON,
+		},
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			path:   "/api/v1/imports/1",
+			status: http.StatusNotFound,
+			want:   jobNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodGet,
+			path:   "/api/v1/imports/first",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestCancelImportJob(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "cancelled",
+			setup:  createJob,
+			method: http.MethodPost,
+			path:   "/api/v1/imports/1/cancel",
+			status: http.StatusOK,
+			want: `{"data":{"id":1,"status":"cancelled","total":1,"processed":0,"succeeded":0,"failed":0,` +
+				`"createdAt":"2024-05-01T12:00:00Z","updatedAt":"2024-05-01T12:00:00Z",` +
+				`"items":[{"regNum":"E555EE199","status":"pending"}]},"error":null}`,
+		},
+		{
+			name: "finished",
+			setup: func(t *testing.T, ts *testServer) {
+				createJob(t, ts)
+				if err := ts.jobs.SetJobStatus(context.Background(), 1, model.ImportDone); err != nil {
+					t.Fatalf("SetJobStatus: %v", err)
+				}
+			},
+			method: http.MethodPost,
+			path:   "/api/v1/imports/1/cancel",
+			status: http.StatusConflict,
+			want:   `{"data":null,"error":"import job is already finished"}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodPost,
+			path:   "/api/v1/imports/1/cancel",
+			status: http.StatusNotFound,
+			want:   jobNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodPost,
+			path:   "/api/v1/imports/first/cancel",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
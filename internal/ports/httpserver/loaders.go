@@ -0,0 +1,111 @@
+package httpserver
+
+import (
+	"cars-service/internal/app"
+	"cars-service/internal/model"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// loaderWait is the time a loader collects keys from concurrent resolvers before fetching them
+	loaderWait = 2 * time.Millisecond
+
+	// loaderMaxBatch is the maximum number of keys fetched at once, it is also used as the
+	// parallelism of GraphQL execution so a full page of cars is fetched in a single batch
+	loaderMaxBatch = 100
+)
+
+// loader batches keys requested by concurrent resolvers into a single fetch and caches results
+// until the end of the request, so nested fields of a list do not produce N+1 queries
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	batch   *loaderBatch[K, V]
+	batches map[K]*loaderBatch[K, V]
+}
+
+type loaderBatch[K comparable, V any] struct {
+	keys   []K
+	values map[K]V
+	err    error
+	once   sync.Once
+	done   chan struct{}
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		fetch:   fetch,
+		batches: make(map[K]*loaderBatch[K, V]),
+	}
+}
+
+// Load returns the value of the key, the zero value is returned if fetch did not return the key
+func (l *loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	b, ok := l.batches[key]
+	if !ok {
+		if l.batch == nil {
+			// the timer gets its own variable, b is reassigned below
+			next := &loaderBatch[K, V]{done: make(chan struct{})}
+			l.batch = next
+			time.AfterFunc(loaderWait, func() {
+				l.dispatch(ctx, next)
+			})
+		}
+		b = l.batch
+		b.keys = append(b.keys, key)
+		l.batches[key] = b
+		if len(b.keys) >= loaderMaxBatch {
+			l.batch = nil
+			go l.dispatch(ctx, b)
+		}
+	}
+	l.mu.Unlock()
+
+	var zero V
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+	if b.err != nil {
+		return zero, b.err
+	}
+	return b.values[key], nil
+}
+
+// dispatch fetches keys of the batch once, new keys are collected to the next batch
+func (l *loader[K, V]) dispatch(ctx context.Context, b *loaderBatch[K, V]) {
+	l.mu.Lock()
+	if l.batch == b {
+		l.batch = nil
+	}
+	l.mu.Unlock()
+
+	b.once.Do(func() {
+		b.values, b.err = l.fetch(ctx, b.keys)
+		close(b.done)
+	})
+}
+
+// loaders are created for every GraphQL request, so cached data does not outlive the request
+type loaders struct {
+	carsByOwner      *loader[uint64, []model.Car]
+	ownershipHistory *loader[uint64, []model.Ownership]
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, a app.App) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		carsByOwner:      newLoader(a.GetCarsByOwnerIds),
+		ownershipHistory: newLoader(a.GetOwnershipHistory),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -1,11 +1,36 @@
 package httpserver
 
 import (
+	"bytes"
+	"cars-service/internal/config"
 	"cars-service/internal/model"
+	"cars-service/internal/repo"
 	"cars-service/pkg/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"io"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// idempotencyKeyHeader is the header with the key identifying retries of the same request
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLength is the maximum length of idempotencyKeyHeader
+	maxIdempotencyKeyLength = 255
+	// idempotencyLockMargin is added to the deadline of the route to get the time after which the
+	// pending request is considered abandoned, so the instance which crashed while processing it
+	// does not block retries until expiry and requests running until the deadline are not repeated
+	idempotencyLockMargin = 10 * time.Second
 )
 
 func loggingMiddleware(logs logger.Logger) gin.HandlerFunc {
@@ -34,3 +59,332 @@ func panicMiddleware(logs logger.Logger) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// sessionMiddleware makes reads of the request go to the primary database after its first write,
+// handlers pass gin.Context which falls back to the context of the request
+func sessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(repo.WithSession(c.Request.Context()))
+		c.Next()
+	}
+}
+
+// corsExposedHeaders are response headers which scripts of other origins can read
+var corsExposedHeaders = strings.Join([]string{
+	"ETag",
+	"Idempotent-Replayed",
+	"RateLimit-Policy",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"Retry-After",
+	"Content-Disposition",
+}, ", ")
+
+// corsMiddleware allows browsers to call the API from origins of cfg, preflight requests are answered
+// without calling handlers and are rejected with 403 for other origins. It is used by the engine
+// as preflight requests do not match any route
+func corsMiddleware(cfg config.CORS) gin.HandlerFunc {
+	if len(cfg.AllowedOrigins) == 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge / time.Second))
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		// responses depend on the origin, so caches must not share them between origins
+		c.Writer.Header().Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+			c.Next()
+			return
+		}
+		c.Header("Access-Control-Allow-Methods", methods)
+		c.Header("Access-Control-Allow-Headers", headers)
+		c.Header("Access-Control-Max-Age", maxAge)
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// securityHeadersMiddleware sets headers which forbid browsers to render responses of the API as
+// pages, hsts is set for HTTPS servers to make browsers use only HTTPS
+func securityHeadersMiddleware(hsts bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+		header.Set("Referrer-Policy", "no-referrer")
+		if hsts {
+			header.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		}
+		c.Next()
+	}
+}
+
+// deadlineMiddleware sets the deadline of the request context, handlers pass gin.Context to app,
+// so calls of the database and outer APIs are interrupted after timeout. Server errors caused by
+// the deadline are answered with 504
+func deadlineMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = &deadlineWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Next()
+	}
+}
+
+// streamDeadlineMiddleware applies stream to requests of NDJSON streams and regular to the rest, so
+// dumps of the whole catalog are not cut by the deadline of pages
+func streamDeadlineMiddleware(regular, stream gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wantsStream(c) {
+			stream(c)
+			return
+		}
+		regular(c)
+	}
+}
+
+// deadlineWriter replaces the response with 5xx status by 504 if the deadline of ctx is exceeded,
+// handlers report interrupted calls as failures of the database or outer APIs
+type deadlineWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+	// exceeded is set when the response is replaced, the body of the handler is discarded then
+	exceeded bool
+}
+
+func (w *deadlineWriter) WriteHeader(code int) {
+	if code >= http.StatusInternalServerError && !w.Written() && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.exceeded = true
+		code = http.StatusGatewayTimeout
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *deadlineWriter) Write(data []byte) (int, error) {
+	if !w.exceeded {
+		return w.ResponseWriter.Write(data)
+	}
+	if !w.Written() {
+		body, err := json.Marshal(errorResponse(model.ErrDeadline))
+		if err != nil {
+			return 0, err
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if _, err = w.ResponseWriter.Write(body); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *deadlineWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// bodyLimitMiddleware rejects requests with Content-Length above limit and makes reading of longer
+// bodies fail, handlers report such failures with abortBodyError
+func bodyLimitMiddleware(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errorResponse(model.ErrBodyTooLarge))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// rateLimitMiddleware allows every client limit requests of the budget in windows of period, clients
+// are identified by keys. Requests are allowed if store fails, so its outage does not stop the service
+func rateLimitMiddleware(store repo.RateLimitRepo, budget string, limit int, period time.Duration, keys clientKeys, logs logger.Logger) gin.HandlerFunc {
+	if limit == 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	policy := fmt.Sprintf("%d;w=%d", limit, int(period/time.Second))
+	return func(c *gin.Context) {
+		now := time.Now()
+		start := now.Truncate(period)
+		count, err := store.Hit(c, budget+":"+keys.clientKey(c), start, period)
+		if err != nil {
+			logs.Error(logger.Fields{
+				"path":   c.Request.URL.Path,
+				"method": c.Request.Method,
+			}, err.Error())
+			c.Next()
+			return
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(start.Add(period).Sub(now).Seconds())))
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(max(limit-count, 0)))
+		c.Header("RateLimit-Reset", reset)
+		if count > limit {
+			c.Header("Retry-After", reset)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(model.ErrRateLimited))
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKeys identifies clients by configured API keys sent in header, clients with other keys
+// are identified by IP, so random keys do not give new budgets
+type clientKeys struct {
+	header string
+	known  map[string]bool
+}
+
+func newClientKeys(header string, keys []string) clientKeys {
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+	return clientKeys{header: header, known: known}
+}
+
+// clientKey returns the hash of the known API key of the client, so keys are not stored, or its IP
+func (k clientKeys) clientKey(c *gin.Context) string {
+	if key := k.apiKey(c); key != "" {
+		return key
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// apiKey returns the hash of the known API key of the client or empty string
+func (k clientKeys) apiKey(c *gin.Context) string {
+	if key := c.GetHeader(k.header); k.known[key] {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+	return ""
+}
+
+// idempotencyMiddleware replays the saved response to requests repeating the Idempotency-Key of the
+// client during ttl. Repeats with another method, URL or body are rejected with 422 and repeats of
+// the request in progress with 409. Responses with 5xx are not saved, so such requests may be retried.
+// Keys are scoped by clients like rate limits, so clients without known API keys can't get
+// responses to requests of others by guessing their keys. timeout is the deadline of the route,
+// the body must be limited by bodyLimitMiddleware before
+func idempotencyMiddleware(store repo.IdempotencyRepo, ttl time.Duration, timeout time.Duration, keys clientKeys, logs logger.Logger) gin.HandlerFunc {
+	lockTimeout := timeout + idempotencyLockMargin
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			resp := errorResponse(model.ErrInvalidInput)
+			resp.Details = []fieldErrorData{{
+				Field:  idempotencyKeyHeader,
+				Reason: fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLength),
+			}}
+			c.AbortWithStatusJSON(http.StatusBadRequest, resp)
+			return
+		}
+
+		// the body is limited by bodyLimitMiddleware and restored for the handler
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortBodyError(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		_, _ = fmt.Fprintf(hash, "%s\n%s\n", c.Request.Method, c.Request.URL.RequestURI())
+		_, _ = hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		// keys of different clients do not collide
+		key = keys.clientKey(c) + ":" + key
+		now := time.Now()
+		saved, started, err := store.StartRequest(c, key, fingerprint, now.Add(ttl), now.Add(-lockTimeout))
+		if err != nil {
+			logs.Error(logger.Fields{
+				"path":   c.Request.URL.Path,
+				"method": c.Request.Method,
+			}, err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(model.ErrDatabaseError))
+			return
+		}
+		if !started {
+			switch {
+			case saved.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, errorResponse(model.ErrIdempotencyMismatch))
+			case !saved.Completed:
+				c.AbortWithStatusJSON(http.StatusConflict, errorResponse(model.ErrIdempotencyInProgress))
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(saved.StatusCode, saved.ContentType, saved.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// the response is saved even if the client has gone, it is going to retry
+		ctx := context.WithoutCancel(c.Request.Context())
+		if status := writer.Status(); status >= http.StatusInternalServerError {
+			err = store.DeleteRequest(ctx, key)
+		} else {
+			saved.StatusCode = status
+			saved.ContentType = writer.Header().Get("Content-Type")
+			saved.Body = writer.body.Bytes()
+			err = store.CompleteRequest(ctx, saved)
+		}
+		if err != nil {
+			logs.Error(logger.Fields{
+				"path":   c.Request.URL.Path,
+				"method": c.Request.Method,
+			}, err.Error())
+		}
+	}
+}
+
+// recordingWriter keeps a copy of the response body written by handlers
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
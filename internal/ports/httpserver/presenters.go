@@ -1,5 +1,120 @@
 package httpserver
 
+import (
+	"cars-service/internal/model"
+	"encoding/csv"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strconv"
+	"strings"
+)
+
 type addCarsRequest struct {
 	RegNums []string `json:"regNums"`
 }
+
+type webhookRequest struct {
+	Url    string            `json:"url"`
+	Events []string          `json:"events"`
+	Filter webhookFilterData `json:"filter"`
+	// Secret is used only on creation, it is generated if empty
+	Secret string `json:"secret"`
+	// Active is used only on update, webhook is enabled if it is not set
+	Active *bool `json:"active"`
+}
+
+func (req webhookRequest) toWebhook() model.Webhook {
+	webhook := model.Webhook{
+		Url:    req.Url,
+		Events: make([]model.EventType, len(req.Events)),
+		Mark:   req.Filter.Mark,
+		Model:  req.Filter.Model,
+		Secret: req.Secret,
+		Active: req.Active == nil || *req.Active,
+	}
+	for i, event := range req.Events {
+		webhook.Events[i] = model.EventType(event)
+	}
+	return webhook
+}
+
+// maxPageLimit is the largest page of cars, greater limits are reduced to it
+const maxPageLimit = 1000
+
+// parseFilter reads filter and pagination from query parameters, limit and offset are
+// required only if requirePagination is true, the limit of such pages is at most maxPageLimit
+func parseFilter(c *gin.Context, requirePagination bool) (model.Filter, error) {
+	var filter model.Filter
+	var err error
+	if _, ok := c.GetQuery("limit"); ok || requirePagination {
+		limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 0 {
+			return model.Filter{}, model.ErrInvalidInput
+		}
+		filter.Limit = uint(limit)
+		if requirePagination {
+			filter.Limit = min(filter.Limit, maxPageLimit)
+		}
+	}
+	if _, ok := c.GetQuery("offset"); ok || requirePagination {
+		offset, err := strconv.Atoi(c.Query("offset"))
+		if err != nil || offset < 0 {
+			return model.Filter{}, model.ErrInvalidInput
+		}
+		filter.Offset = uint(offset)
+	}
+	filter.RegNum, filter.ByRegNum = c.GetQuery("regNum")
+	filter.Mark, filter.ByMark = c.GetQuery("mark")
+	filter.Model, filter.ByModel = c.GetQuery("model")
+	filter.OwnerName, filter.ByOwnerName = c.GetQuery("ownerName")
+	filter.OwnerSurname, filter.ByOwnerSurname = c.GetQuery("ownerSurname")
+	filter.OwnerPatronymic, filter.ByOwnerPatronymic = c.GetQuery("ownerPatronymic")
+	if _, filter.ByYear = c.GetQuery("year"); filter.ByYear {
+		filter.Year, err = strconv.Atoi(c.Query("year"))
+		if err != nil {
+			return model.Filter{}, model.ErrInvalidInput
+		}
+	}
+	return filter, nil
+}
+
+// uniqueRegNums returns regNums without repeats keeping the first occurrence of each and the list
+// of repeated ones, regNums are compared without surrounding spaces as AddCars ignores them
+func uniqueRegNums(regNums []string) (unique []string, duplicates []string) {
+	unique = make([]string, 0, len(regNums))
+	seen := make(map[string]int, len(regNums))
+	for _, regNum := range regNums {
+		key := strings.TrimSpace(regNum)
+		seen[key]++
+		switch seen[key] {
+		case 1:
+			unique = append(unique, regNum)
+		case 2:
+			duplicates = append(duplicates, key)
+		}
+	}
+	return unique, duplicates
+}
+
+// parseRegNumsCSV reads regNums from the first column of CSV, the header row with
+// "regNum" column name is skipped
+func parseRegNumsCSV(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var regNums []string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return regNums, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(regNums) == 0 && strings.EqualFold(record[0], "regNum") {
+			continue
+		}
+		regNums = append(regNums, record[0])
+	}
+}
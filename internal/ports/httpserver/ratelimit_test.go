@@ -0,0 +1,120 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/config"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const rateLimitedJSON = `{"data":null,"error":"too many requests"}`
+
+// newRateLimitedServer allows 2 regular requests and 1 bulk insert per hour to clients with keys
+// first and second and to every IP, the window is long, so it does not end during the test
+func newRateLimitedServer(t *testing.T) *testServer {
+	t.Helper()
+	cfg := config.Default().Server
+	cfg.RateLimit.Keys = []string{"first", "second"}
+	cfg.RateLimit.Period = time.Hour
+	cfg.RateLimit.ReadLimit = 2
+	cfg.RateLimit.BulkLimit = 1
+	return newTestServerWithConfig(t, nil, cfg)
+}
+
+func assertRateLimitHeaders(t *testing.T, resp response, remaining string) {
+	t.Helper()
+	if got := resp.header.Get("RateLimit-Remaining"); got != remaining {
+		t.Errorf("RateLimit-Remaining = %q, want %q", got, remaining)
+	}
+	if got := resp.header.Get("RateLimit-Reset"); got == "" {
+		t.Error("RateLimit-Reset is not set")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ts := newRateLimitedServer(t)
+
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars/1", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+vestaJSON+`,"error":null}`)
+	assertRateLimitHeaders(t, resp, "1")
+	if got := resp.header.Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("RateLimit-Limit = %q, want 2", got)
+	}
+	if got := resp.header.Get("RateLimit-Policy"); got != "2;w=3600" {
+		t.Errorf("RateLimit-Policy = %q, want 2;w=3600", got)
+	}
+
+	resp = ts.do(t, http.MethodDelete, "/api/v1/cars/2", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, okJSON)
+	assertRateLimitHeaders(t, resp, "0")
+
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/1", "", nil)
+	assertJSONResponse(t, resp, http.StatusTooManyRequests, rateLimitedJSON)
+	assertRateLimitHeaders(t, resp, "0")
+	if resp.header.Get("Retry-After") == "" {
+		t.Error("Retry-After is not set")
+	}
+}
+
+func TestRateLimitBulkBudget(t *testing.T) {
+	ts := newRateLimitedServer(t)
+
+	// bulk inserts do not spend the budget of regular requests and vice versa
+	resp := ts.do(t, http.MethodPost, "/api/v1/cars", `{"regNums":["E555EE199"]}`, nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":[`+audiJSON+`],"error":null}`)
+	assertRateLimitHeaders(t, resp, "0")
+
+	resp = ts.do(t, http.MethodPost, "/api/v1/imports", `{"regNums":["E555EE199"]}`, nil)
+	assertJSONResponse(t, resp, http.StatusTooManyRequests, rateLimitedJSON)
+
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/1", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+vestaJSON+`,"error":null}`)
+	assertRateLimitHeaders(t, resp, "1")
+
+	// unknown keys spend the budget of the IP, so random keys do not bypass the limit
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/1", "", map[string]string{"X-Api-Key": "random"})
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+vestaJSON+`,"error":null}`)
+	assertRateLimitHeaders(t, resp, "0")
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/1", "", map[string]string{"X-Api-Key": "another"})
+	assertJSONResponse(t, resp, http.StatusTooManyRequests, rateLimitedJSON)
+}
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	ts := newRateLimitedServer(t)
+	first := map[string]string{"X-Api-Key": "first"}
+	second := map[string]string{"X-Api-Key": "second"}
+
+	for i := 0; i < 2; i++ {
+		ts.do(t, http.MethodGet, "/api/v1/cars/1", "", first)
+	}
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars/1", "", first)
+	assertJSONResponse(t, resp, http.StatusTooManyRequests, rateLimitedJSON)
+
+	// clients with other keys and without keys have their own budgets
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/1", "", second)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+vestaJSON+`,"error":null}`)
+	assertRateLimitHeaders(t, resp, "1")
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/1", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+vestaJSON+`,"error":null}`)
+	assertRateLimitHeaders(t, resp, "1")
+}
+
+func TestRateLimitGraphQLMutations(t *testing.T) {
+	ts := newRateLimitedServer(t)
+
+	// mutations add cars like POST /cars, so they spend the bulk budget
+	mutation := `{"query":"# adds cars\nmutation Add { addCars(regNums: [\"E555EE199\"]) { id } }"}`
+	resp := ts.do(t, http.MethodPost, "/api/v1/graphql", mutation, nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":{"addCars":[{"id":"3"}]}}`)
+	assertRateLimitHeaders(t, resp, "0")
+	resp = ts.do(t, http.MethodPost, "/api/v1/graphql", mutation, nil)
+	assertJSONResponse(t, resp, http.StatusTooManyRequests, rateLimitedJSON)
+	resp = ts.do(t, http.MethodPost, "/api/v1/cars", `{"regNums":["E555EE199"]}`, nil)
+	assertJSONResponse(t, resp, http.StatusTooManyRequests, rateLimitedJSON)
+
+	// the word mutation in names and strings of queries does not make them mutations
+	query := `{"query":"query mutation { car(id: 1) { mutation: regNum } cars(filter: {mark: \"mutation\"}, page: {limit: 1}) { id } }"}`
+	resp = ts.do(t, http.MethodPost, "/api/v1/graphql", query, nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":{"car":{"mutation":"A111AA150"},"cars":[]}}`)
+	assertRateLimitHeaders(t, resp, "1")
+}
@@ -0,0 +1,263 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"net/http"
+	"testing"
+)
+
+// changeOwner makes the stored owner of the car with id 2 differ from the outer API
+func changeOwner(t *testing.T, ts *testServer) {
+	t.Helper()
+	car := seedCars[1]
+	car.Owner = sidrov
+	if _, err := ts.repo.UpdateCar(context.Background(), 2, car); err != nil {
+		t.Fatalf("UpdateCar: %v", err)
+	}
+}
+
+// queueRefresh saves pending refresh with id 1 of the car with id 2
+func queueRefresh(t *testing.T, ts *testServer) {
+	t.Helper()
+	changeOwner(t, ts)
+	resp := ts.do(t, http.MethodPost, "/api/v1/cars/2/refresh", "", nil)
+	if resp.status != http.StatusOK {
+		t.Fatalf("refresh status = %d, body: %s", resp.status, resp.body)
+	}
+}
+
+const (
+	refreshNotFoundJSON = `{"data":null,"error":"refresh not found"}`
+	refreshResolvedJSON = `{"data":null,"error":"refresh is already resolved"}`
+	// ownerChangeJSON is the change of the car changed by changeOwner
+	ownerChangeJSON = `{"field":"owner","old":"Сидоров Сидор","new":"Петров Пётр Петрович"}`
+	// pendingRefreshJSON is the refresh made by queueRefresh
+	pendingRefreshJSON = `{"id":1,"carId":2,"status":"pending","changes":[` + ownerChangeJSON + `],` +
+		`"car":` + rioJSON + `,"createdAt":"2024-05-01T12:00:00Z"}`
+)
+
+func TestRefreshCar(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "unchanged",
+			method: http.MethodPost,
+			path:   "/api/v1/cars/1/refresh",
+			status: http.StatusOK,
+			want:   `{"data":{"carId":1,"status":"unchanged","changes":[],"car":` + vestaJSON + `},"error":null}`,
+		},
+		{
+			name:   "queued",
+			setup:  changeOwner,
+			method: http.MethodPost,
+			path:   "/api/v1/cars/2/refresh",
+			status: http.StatusOK,
+			want:   `{"data":` + pendingRefreshJSON + `,"error":null}`,
+		},
+		{
+			name:   "applied",
+			setup:  changeOwner,
+			method: http.MethodPost,
+			path:   "/api/v1/cars/2/refresh?apply=true",
+			status: http.StatusOK,
+			want: `{"data":{"id":1,"carId":2,"status":"applied","changes":[` + ownerChangeJSON + `],` +
+				`"car":` + rioJSON + `,"createdAt":"2024-05-01T12:00:00Z","resolvedAt":"2024-05-01T12:00:00Z"},` +
+				`"error":null}`,
+		},
+		{
+			name: "outer api error",
+			setup: func(t *testing.T, ts *testServer) {
+				car := seedCars[0]
+				car.RegNum = brokenRegNum
+				if _, err := ts.repo.AddCar(context.Background(), car); err != nil {
+					t.Fatalf("AddCar: %v", err)
+				}
+			},
+			method: http.MethodPost,
+			path:   "/api/v1/cars/3/refresh",
+			status: http.StatusBadGateway,
+			want:   `{"data":null,"error":"outer api error"}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodPost,
+			path:   "/api/v1/cars/9/refresh",
+			status: http.StatusNotFound,
+			want:   carNotFoundJSON,
+		},
+		{
+			name:   "invalid apply",
+			method: http.MethodPost,
+			path:   "/api/v1/cars/1/refresh?apply=maybe",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodPost,
+			path:   "/api/v1/cars/abc/refresh",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestRefreshAppliedToCar(t *testing.T) {
+	ts := newTestServer(t, nil)
+	changeOwner(t, ts)
+
+	resp := ts.do(t, http.MethodPost, "/api/v1/cars/2/refresh?apply=true", "", nil)
+	if resp.status != http.StatusOK {
+		t.Fatalf("refresh status = %d, body: %s", resp.status, resp.body)
+	}
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars/2", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":`+rioJSON+`,"error":null}`)
+}
+
+func TestGetRefreshes(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "pending by default",
+			setup:  queueRefresh,
+			method: http.MethodGet,
+			path:   "/api/v1/refreshes",
+			status: http.StatusOK,
+			want:   `{"data":[` + pendingRefreshJSON + `],"error":null}`,
+		},
+		{
+			name: "superseded",
+			setup: func(t *testing.T, ts *testServer) {
+				queueRefresh(t, ts)
+				queueRefresh(t, ts)
+			},
+			method: http.MethodGet,
+			path:   "/api/v1/refreshes?status=superseded",
+			status: http.StatusOK,
+			want: `{"data":[{"id":1,"carId":2,"status":"superseded","changes":[` + ownerChangeJSON + `],` +
+				`"car":` + rioJSON + `,"createdAt":"2024-05-01T12:00:00Z","resolvedAt":"2024-05-01T12:00:00Z"}],` +
+				`"error":null}`,
+		},
+		{
+			name:   "empty",
+			method: http.MethodGet,
+			path:   "/api/v1/refreshes?status=applied",
+			status: http.StatusOK,
+			want:   `{"data":[],"error":null}`,
+		},
+		{
+			name:   "invalid status",
+			method: http.MethodGet,
+			path:   "/api/v1/refreshes?status=unchanged",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestGetRefresh(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "found",
+			setup:  queueRefresh,
+			method: http.MethodGet,
+			path:   "/api/v1/refreshes/1",
+			status: http.StatusOK,
+			want:   `{"data":` + pendingRefreshJSON + `,"error":null}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			path:   "/api/v1/refreshes/1",
+			status: http.StatusNotFound,
+			want:   refreshNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodGet,
+			path:   "/api/v1/refreshes/abc",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestApplyRefresh(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "applied",
+			setup:  queueRefresh,
+			method: http.MethodPost,
+			path:   "/api/v1/refreshes/1/apply",
+			status: http.StatusOK,
+			want: `{"data":{"id":1,"carId":2,"status":"applied","changes":[` + ownerChangeJSON + `],` +
+				`"car":` + rioJSON + `,"createdAt":"2024-05-01T12:00:00Z","resolvedAt":"2024-05-01T12:00:00Z"},` +
+				`"error":null}`,
+		},
+		{
+			name: "already resolved",
+			setup: func(t *testing.T, ts *testServer) {
+				queueRefresh(t, ts)
+				if err := ts.refreshes.ResolveRefresh(context.Background(), 1, model.RefreshRejected); err != nil {
+					t.Fatalf("ResolveRefresh: %v", err)
+				}
+			},
+			method: http.MethodPost,
+			path:   "/api/v1/refreshes/1/apply",
+			status: http.StatusConflict,
+			want:   refreshResolvedJSON,
+		},
+		{
+			name: "car deleted",
+			setup: func(t *testing.T, ts *testServer) {
+				queueRefresh(t, ts)
+				if err := ts.repo.DeleteCar(context.Background(), 2); err != nil {
+					t.Fatalf("DeleteCar: %v", err)
+				}
+			},
+			method: http.MethodPost,
+			path:   "/api/v1/refreshes/1/apply",
+			status: http.StatusNotFound,
+			want:   carNotFoundJSON,
+		},
+		{
+			name:   "not found",
+			method: http.MethodPost,
+			path:   "/api/v1/refreshes/1/apply",
+			status: http.StatusNotFound,
+			want:   refreshNotFoundJSON,
+		},
+	})
+}
+
+func TestRejectRefresh(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "rejected",
+			setup:  queueRefresh,
+			method: http.MethodPost,
+			path:   "/api/v1/refreshes/1/reject",
+			status: http.StatusOK,
+			want: `{"data":{"id":1,"carId":2,"status":"rejected","changes":[` + ownerChangeJSON + `],` +
+				`"car":` + rioJSON + `,"createdAt":"2024-05-01T12:00:00Z","resolvedAt":"2024-05-01T12:00:00Z"},` +
+				`"error":null}`,
+		},
+		{
+			name: "already resolved",
+			setup: func(t *testing.T, ts *testServer) {
+				queueRefresh(t, ts)
+				ts.do(t, http.MethodPost, "/api/v1/refreshes/1/reject", "", nil)
+			},
+			method: http.MethodPost,
+			path:   "/api/v1/refreshes/1/reject",
+			status: http.StatusConflict,
+			want:   refreshResolvedJSON,
+		},
+		{
+			name:   "not found",
+			method: http.MethodPost,
+			path:   "/api/v1/refreshes/1/reject",
+			status: http.StatusNotFound,
+			want:   refreshNotFoundJSON,
+		},
+	})
+}
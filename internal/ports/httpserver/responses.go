@@ -1,6 +1,13 @@
 package httpserver
 
-import "cars-service/internal/model"
+import (
+	"cars-service/internal/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
 
 func errorResponse(err error) carResponse {
 	if err == nil {
@@ -13,6 +20,56 @@ func errorResponse(err error) carResponse {
 	}
 }
 
+// validationErrorResponse returns response with the list of all fields which failed validation
+func validationErrorResponse(err error) carResponse {
+	resp := errorResponse(model.ErrValidation)
+	var vErr *model.ValidationError
+	if errors.As(err, &vErr) {
+		resp.Details = make([]fieldErrorData, len(vErr.Fields))
+		for i, f := range vErr.Fields {
+			resp.Details[i] = fieldErrorData{
+				Field:  f.Field,
+				Reason: f.Reason,
+			}
+		}
+	}
+	return resp
+}
+
+// batchTooLargeResponse returns response to the request adding more than maxBatchSize regNums
+func batchTooLargeResponse(maxBatchSize int) carResponse {
+	resp := errorResponse(model.ErrBatchTooLarge)
+	resp.Details = []fieldErrorData{{
+		Field:  "regNums",
+		Reason: fmt.Sprintf("must contain at most %d items", maxBatchSize),
+	}}
+	return resp
+}
+
+// carETag returns the strong ETag of the car which is changed with its version
+func carETag(car model.Car) string {
+	return fmt.Sprintf(`"%d-%d"`, car.Id, car.Version)
+}
+
+// carsETag returns the strong ETag of the page of cars which is changed if any car of the page is
+// changed, added to it or removed from it
+func carsETag(cars []model.Car) string {
+	hash := sha256.New()
+	for _, car := range cars {
+		_, _ = fmt.Fprintf(hash, "%d-%d,", car.Id, car.Version)
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// carsCacheControl returns Cache-Control of responses with cars, they are private as they may
+// depend on the API key of the client
+func carsCacheControl(maxAge time.Duration) string {
+	if maxAge <= 0 {
+		return "private, no-cache"
+	}
+	return fmt.Sprintf("private, max-age=%d", int(maxAge/time.Second))
+}
+
 func carToCarData(car model.Car) carData {
 	return carData{
 		Id:     car.Id,
@@ -25,6 +82,7 @@ func carToCarData(car model.Car) carData {
 			Surname:    car.Owner.Surname,
 			Patronymic: car.Owner.Patronymic,
 		},
+		Sources: car.Sources,
 	}
 }
 
@@ -36,14 +94,130 @@ func carsToCarsData(cars []model.Car) []carData {
 	return data
 }
 
+func importJobToImportJobData(job model.ImportJob) importJobData {
+	data := importJobData{
+		Id:        job.Id,
+		Status:    string(job.Status),
+		Total:     job.Total,
+		Processed: job.Processed,
+		Succeeded: job.Succeeded,
+		Failed:    job.Failed,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+		Items:     make([]importItemData, len(job.Items)),
+	}
+	for i, item := range job.Items {
+		data.Items[i] = importItemData{
+			RegNum: item.RegNum,
+			Status: string(item.Status),
+			CarId:  item.CarId,
+			Error:  item.Error,
+		}
+	}
+	return data
+}
+
+func webhookToWebhookData(webhook model.Webhook, withSecret bool) webhookData {
+	data := webhookData{
+		Id:     webhook.Id,
+		Url:    webhook.Url,
+		Events: make([]string, len(webhook.Events)),
+		Filter: webhookFilterData{
+			Mark:  webhook.Mark,
+			Model: webhook.Model,
+		},
+		Active:    webhook.Active,
+		Failures:  webhook.Failures,
+		CreatedAt: webhook.CreatedAt,
+	}
+	for i, event := range webhook.Events {
+		data.Events[i] = string(event)
+	}
+	// the secret is shown only once after creation
+	if withSecret {
+		data.Secret = webhook.Secret
+	}
+	return data
+}
+
+func webhooksToWebhooksData(webhooks []model.Webhook) []webhookData {
+	data := make([]webhookData, len(webhooks))
+	for i, webhook := range webhooks {
+		data[i] = webhookToWebhookData(webhook, false)
+	}
+	return data
+}
+
+func deliveriesToDeliveriesData(deliveries []model.WebhookDelivery) []deliveryData {
+	data := make([]deliveryData, len(deliveries))
+	for i, d := range deliveries {
+		data[i] = deliveryData{
+			Id:            d.Id,
+			Event:         string(d.Event.Type),
+			CarId:         d.Event.CarId,
+			Status:        string(d.Status),
+			Attempts:      d.Attempts,
+			NextAttemptAt: d.NextAttemptAt,
+			StatusCode:    d.StatusCode,
+			Error:         d.Error,
+			CreatedAt:     d.CreatedAt,
+			UpdatedAt:     d.UpdatedAt,
+		}
+	}
+	return data
+}
+
+func refreshToRefreshData(refresh model.Refresh) refreshData {
+	data := refreshData{
+		Id:      refresh.Id,
+		CarId:   refresh.CarId,
+		Status:  string(refresh.Status),
+		Changes: make([]fieldChangeData, len(refresh.Changes)),
+		Car:     carToCarData(refresh.Car),
+	}
+	for i, change := range refresh.Changes {
+		data.Changes[i] = fieldChangeData{
+			Field:  change.Field,
+			Old:    change.Old,
+			New:    change.New,
+			Source: change.Source,
+		}
+	}
+	if !refresh.CreatedAt.IsZero() {
+		data.CreatedAt = &refresh.CreatedAt
+	}
+	if !refresh.ResolvedAt.IsZero() {
+		data.ResolvedAt = &refresh.ResolvedAt
+	}
+	return data
+}
+
+func refreshesToRefreshesData(refreshes []model.Refresh) []refreshData {
+	data := make([]refreshData, len(refreshes))
+	for i, refresh := range refreshes {
+		data[i] = refreshToRefreshData(refresh)
+	}
+	return data
+}
+
 type carResponse struct {
-	Data *carData `json:"data"`
-	Err  *string  `json:"error"`
+	Data    *carData         `json:"data"`
+	Err     *string          `json:"error"`
+	Details []fieldErrorData `json:"details,omitempty"`
 }
 
 type carsResponse struct {
-	Data []carData `json:"data"`
-	Err  *string   `json:"error"`
+	Data    []carData        `json:"data"`
+	Err     *string          `json:"error"`
+	Details []fieldErrorData `json:"details,omitempty"`
+}
+
+// addCarsResponse is carsResponse with regNums repeated in the request, each of them is listed once
+type addCarsResponse struct {
+	Data       []carData        `json:"data"`
+	Duplicates []string         `json:"duplicates,omitempty"`
+	Err        *string          `json:"error"`
+	Details    []fieldErrorData `json:"details,omitempty"`
 }
 
 type carData struct {
@@ -53,6 +227,8 @@ type carData struct {
 	Model  string    `json:"model"`
 	Year   int       `json:"year"`
 	Owner  ownerData `json:"owner"`
+	// Sources maps fields to providers which supplied them, it is ignored in requests
+	Sources map[string]string `json:"sources,omitempty"`
 }
 
 type ownerData struct {
@@ -60,3 +236,119 @@ type ownerData struct {
 	Surname    string `json:"surname"`
 	Patronymic string `json:"patronymic"`
 }
+
+type fieldErrorData struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+type importJobResponse struct {
+	Data *importJobData `json:"data"`
+	Err  *string        `json:"error"`
+}
+
+type importJobData struct {
+	Id        uint64           `json:"id"`
+	Status    string           `json:"status"`
+	Total     int              `json:"total"`
+	Processed int              `json:"processed"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	CreatedAt time.Time        `json:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	Items     []importItemData `json:"items"`
+}
+
+type importItemData struct {
+	RegNum string `json:"regNum"`
+	Status string `json:"status"`
+	CarId  uint64 `json:"carId,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type importRowsResponse struct {
+	Data []importRowData `json:"data"`
+	Err  *string         `json:"error"`
+}
+
+type importRowData struct {
+	Row    int    `json:"row"`
+	RegNum string `json:"regNum"`
+	Status string `json:"status"`
+	CarId  uint64 `json:"carId,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type webhookResponse struct {
+	Data    *webhookData     `json:"data"`
+	Err     *string          `json:"error"`
+	Details []fieldErrorData `json:"details,omitempty"`
+}
+
+type webhooksResponse struct {
+	Data []webhookData `json:"data"`
+	Err  *string       `json:"error"`
+}
+
+type webhookData struct {
+	Id        uint64            `json:"id"`
+	Url       string            `json:"url"`
+	Events    []string          `json:"events"`
+	Filter    webhookFilterData `json:"filter"`
+	Secret    string            `json:"secret,omitempty"`
+	Active    bool              `json:"active"`
+	Failures  int               `json:"failures"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+type webhookFilterData struct {
+	Mark  string `json:"mark,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+type deliveriesResponse struct {
+	Data []deliveryData `json:"data"`
+	Err  *string        `json:"error"`
+}
+
+type deliveryData struct {
+	Id            uint64    `json:"id"`
+	Event         string    `json:"event"`
+	CarId         uint64    `json:"carId"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	StatusCode    int       `json:"statusCode,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type refreshResponse struct {
+	Data *refreshData `json:"data"`
+	Err  *string      `json:"error"`
+}
+
+type refreshesResponse struct {
+	Data []refreshData `json:"data"`
+	Err  *string       `json:"error"`
+}
+
+// refreshData is a result of querying providers for the stored car, id and createdAt are absent
+// if nothing changed, car is the state of the car with all changes
+type refreshData struct {
+	Id         uint64            `json:"id,omitempty"`
+	CarId      uint64            `json:"carId"`
+	Status     string            `json:"status"`
+	Changes    []fieldChangeData `json:"changes"`
+	Car        carData           `json:"car"`
+	CreatedAt  *time.Time        `json:"createdAt,omitempty"`
+	ResolvedAt *time.Time        `json:"resolvedAt,omitempty"`
+}
+
+type fieldChangeData struct {
+	Field  string `json:"field"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+	Source string `json:"source,omitempty"`
+}
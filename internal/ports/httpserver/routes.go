@@ -3,17 +3,69 @@ package httpserver
 import (
 	_ "cars-service/docs"
 	"cars-service/internal/app"
+	"cars-service/internal/config"
+	"cars-service/internal/repo"
 	"cars-service/pkg/logger"
 	"github.com/gin-gonic/gin"
 )
 
-func setRoutes(r *gin.RouterGroup, a app.App, logs logger.Logger) {
+func setRoutes(r *gin.RouterGroup, cfg config.Server, a app.App, rateLimits repo.RateLimitRepo, idempotency repo.IdempotencyRepo, logs logger.Logger) {
 	r.Use(panicMiddleware(logs))
 	r.Use(loggingMiddleware(logs))
+	r.Use(sessionMiddleware())
+	r.Use(securityHeadersMiddleware(cfg.TLS.CertFile != ""))
 
-	r.GET("/cars/:id", handleGetCarById(a))
-	r.GET("/cars", handleGetCars(a))
-	r.POST("/cars", handleAddCars(a))
-	r.PUT("/cars/:id", handleUpdateCar(a))
-	r.DELETE("/cars/:id", handleDeleteCar(a))
+	// bulk inserts have their own budget, so imports do not exhaust the read budget of regular routes
+	limits := cfg.RateLimit
+	keys := newClientKeys(limits.KeyHeader, limits.Keys)
+	read := rateLimitMiddleware(rateLimits, "read", limits.ReadLimit, limits.Period, keys, logs)
+	bulkInserts := rateLimitMiddleware(rateLimits, "bulk", limits.BulkLimit, limits.Period, keys, logs)
+	regular := r.Group("", read)
+	bulk := r.Group("", bulkInserts)
+
+	// adding of cars queries outer APIs for every regNum, imports, exports and streams handle whole
+	// catalogs
+	timeout := deadlineMiddleware(cfg.RequestTimeout)
+	long := deadlineMiddleware(cfg.LongRequestTimeout)
+	// idempotent reads bodies of all its routes, so they are limited even if handlers ignore them
+	body := bodyLimitMiddleware(cfg.MaxBodySize)
+	upload := bodyLimitMiddleware(cfg.MaxUploadSize)
+	// retries of changing routes with Idempotency-Key get the response to the first request, the key
+	// stays locked until the deadline of the route
+	idempotent := idempotencyMiddleware(idempotency, cfg.IdempotencyTTL, cfg.RequestTimeout, keys, logs)
+	longIdempotent := idempotencyMiddleware(idempotency, cfg.IdempotencyTTL, cfg.LongRequestTimeout, keys, logs)
+
+	cacheControl := carsCacheControl(cfg.CacheMaxAge)
+	regular.GET("/cars/:id", timeout, handleGetCarById(a, cacheControl))
+	regular.GET("/cars", streamDeadlineMiddleware(timeout, long), handleGetCars(a, cacheControl))
+	bulk.POST("/cars", long, body, longIdempotent, handleAddCars(a, cfg.MaxBatchSize))
+	regular.GET("/cars/export", long, handleExportCars(a))
+	bulk.POST("/cars/import", long, upload, longIdempotent, handleImportCars(a))
+	regular.PUT("/cars/:id", timeout, body, idempotent, handleUpdateCar(a))
+	regular.DELETE("/cars/:id", timeout, body, idempotent, handleDeleteCar(a))
+	regular.POST("/cars/:id/refresh", timeout, body, idempotent, handleRefreshCar(a))
+
+	bulk.POST("/imports", timeout, upload, idempotent, handleCreateImportJob(a))
+	regular.GET("/imports/:id", timeout, handleGetImportJob(a))
+	regular.POST("/imports/:id/cancel", timeout, body, idempotent, handleCancelImportJob(a))
+
+	regular.POST("/webhooks", timeout, body, idempotent, handleCreateWebhook(a))
+	regular.GET("/webhooks", timeout, handleGetWebhooks(a))
+	regular.GET("/webhooks/:id", timeout, handleGetWebhook(a))
+	regular.PUT("/webhooks/:id", timeout, body, idempotent, handleUpdateWebhook(a))
+	regular.DELETE("/webhooks/:id", timeout, body, idempotent, handleDeleteWebhook(a))
+	regular.GET("/webhooks/:id/deliveries", timeout, handleGetWebhookDeliveries(a))
+
+	regular.GET("/refreshes", timeout, handleGetRefreshes(a))
+	regular.GET("/refreshes/:id", timeout, handleGetRefresh(a))
+	regular.POST("/refreshes/:id/apply", timeout, body, idempotent, handleApplyRefresh(a))
+	regular.POST("/refreshes/:id/reject", timeout, body, idempotent, handleRejectRefresh(a))
+
+	// mutations add cars like POST /cars, so they have its budget and deadline, the body is read
+	// before the budget is chosen
+	r.POST("/graphql", body, graphqlOperationMiddleware(),
+		graphqlMutationMiddleware(read, bulkInserts),
+		graphqlMutationMiddleware(timeout, long),
+		graphqlMutationMiddleware(idempotent, longIdempotent),
+		handleGraphQL(newGraphQLSchema(a, logs, cfg.MaxBatchSize), a))
 }
@@ -2,6 +2,8 @@ package httpserver
 
 import (
 	"cars-service/internal/app"
+	"cars-service/internal/config"
+	"cars-service/internal/repo"
 	"cars-service/pkg/logger"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -10,15 +12,24 @@ import (
 )
 
 // New creates HTTP server with all needed routes
-func New(addr string, a app.App, logs logger.Logger) *http.Server {
+func New(cfg config.Server, a app.App, rateLimits repo.RateLimitRepo, idempotency repo.IdempotencyRepo, logs logger.Logger) *http.Server {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// addresses of proxies are validated by config, no proxy is trusted if the list is empty
+	_ = router.SetTrustedProxies(cfg.TrustedProxies)
+	// values and cancellation of the request context are visible through gin.Context
+	router.ContextWithFallback = true
+	// preflight requests do not match any route, so they are answered by the engine
+	router.Use(corsMiddleware(cfg.CORS))
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	api := router.Group("/api/v1")
-	setRoutes(api, a, logs)
+	setRoutes(api, cfg, a, rateLimits, idempotency, logs)
 	return &http.Server{
-		Addr:    addr,
-		Handler: router,
+		Addr:         cfg.Addr,
+		Handler:      router,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
 	}
 }
@@ -0,0 +1,332 @@
+package httpserver_test
+
+import (
+	"bytes"
+	"cars-service/internal/adapters/api"
+	"cars-service/internal/app"
+	"cars-service/internal/config"
+	"cars-service/internal/model"
+	"cars-service/internal/ports/httpserver"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	ivanov = model.Owner{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"}
+	petrov = model.Owner{Name: "Пётр", Surname: "Петров", Patronymic: "Петрович"}
+	sidrov = model.Owner{Name: "Сидор", Surname: "Сидоров"}
+)
+
+// seedCars are added to the repo of every test server with ids 1 and 2
+var seedCars = []model.Car{
+	{RegNum: "A111AA150", Mark: "Lada", Model: "Vesta", Year: 2020, Owner: ivanov},
+	{RegNum: "B222BB50", Mark: "Kia", Model: "Rio", Year: 2018, Owner: petrov},
+}
+
+// outerCars are the cars known by the stub of the outer API, new cars are added with id 3
+var outerCars = []model.Car{
+	seedCars[0],
+	seedCars[1],
+	{RegNum: "E555EE199", Mark: "Audi", Model: "A4", Year: 2015, Owner: sidrov},
+}
+
+// brokenRegNum makes the stub of the outer API fail
+const brokenRegNum = "M999MM99"
+
+// hangingRegNum makes the stub of the outer API respond only after the request is canceled
+const hangingRegNum = "H888HH88"
+
+// JSON of seedCars and the new car from the outer API in responses
+const (
+	vestaJSON = `{"id":1,"regNum":"A111AA150","mark":"Lada","model":"Vesta","year":2020,` +
+		`"owner":{"name":"Иван","surname":"Иванов","patronymic":"Иванович"}}`
+	rioJSON = `{"id":2,"regNum":"B222BB50","mark":"Kia","model":"Rio","year":2018,` +
+		`"owner":{"name":"Пётр","surname":"Петров","patronymic":"Петрович"}}`
+	audiJSON = `{"id":3,"regNum":"E555EE199","mark":"Audi","model":"A4","year":2015,` +
+		`"owner":{"name":"Сидор","surname":"Сидоров","patronymic":""}}`
+)
+
+// error responses of all routes except graphql
+const (
+	invalidInputJSON    = `{"data":null,"error":"invalid input"}`
+	carNotFoundJSON     = `{"data":null,"error":"car not found"}`
+	duplicateRegNumJSON = `{"data":null,"error":"duplicate registration number"}`
+	databaseErrorJSON   = `{"data":null,"error":"database error"}`
+	serviceErrorJSON    = `{"data":null,"error":"unknown service error"}`
+	okJSON              = `{"data":null,"error":null}`
+	bodyTooLargeJSON    = `{"data":null,"error":"request body is too large"}`
+)
+
+// testServer is the HTTP server with the in-process app, its storages are available to tests
+// for setting the state up
+type testServer struct {
+	*httptest.Server
+	repo        repo.Repo
+	jobs        *jobRepo
+	webhooks    *webhookRepo
+	refreshes   *refreshRepo
+	idempotency *idempotencyRepo
+}
+
+// newTestServer starts the server with r and the stub of the outer API, r is the memory repo
+// with seedCars if it is nil
+func newTestServer(t *testing.T, r repo.Repo) *testServer {
+	t.Helper()
+	return newTestServerWithConfig(t, r, config.Default().Server)
+}
+
+// newTestServerWithConfig is newTestServer with limits of cfg
+func newTestServerWithConfig(t *testing.T, r repo.Repo, cfg config.Server) *testServer {
+	t.Helper()
+	if r == nil {
+		r = repo.NewMemoryRepo()
+		for _, car := range seedCars {
+			if _, err := r.AddCar(context.Background(), car); err != nil {
+				t.Fatalf("AddCar(%s): %v", car.RegNum, err)
+			}
+		}
+	}
+	outer := httptest.NewServer(http.HandlerFunc(handleOuterApi))
+	t.Cleanup(outer.Close)
+
+	// only fatal messages are written, so logs of handled errors do not clutter the output
+	logs, err := logger.NewWithLevel("fatal")
+	if err != nil {
+		t.Fatalf("NewWithLevel: %v", err)
+	}
+	ts := &testServer{
+		repo:        r,
+		jobs:        newJobRepo(),
+		webhooks:    newWebhookRepo(),
+		refreshes:   newRefreshRepo(),
+		idempotency: newIdempotencyRepo(),
+	}
+	a := app.New(r, ts.jobs, ts.webhooks, ts.refreshes, api.New(outer.URL, time.Second), logs)
+	ts.Server = httptest.NewServer(httpserver.New(cfg, a, repo.NewMemoryRateLimitRepo(), ts.idempotency, logs).Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// handleOuterApi is the stub of the outer API which knows outerCars
+func handleOuterApi(w http.ResponseWriter, r *http.Request) {
+	regNum := r.URL.Query().Get("regNum")
+	if regNum == brokenRegNum {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if regNum == hangingRegNum {
+		<-r.Context().Done()
+		return
+	}
+	for _, car := range outerCars {
+		if car.RegNum == regNum {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"regNum": car.RegNum,
+				"mark":   car.Mark,
+				"model":  car.Model,
+				"year":   car.Year,
+				"owner": map[string]string{
+					"name":       car.Owner.Name,
+					"surname":    car.Owner.Surname,
+					"patronymic": car.Owner.Patronymic,
+				},
+			})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends the request to path of the API, body is sent as JSON unless Content-Type is in header
+func (ts *testServer) do(t *testing.T, method string, path string, body string, header map[string]string) response {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: reading body: %v", method, path, err)
+	}
+	return response{
+		status: resp.StatusCode,
+		header: resp.Header,
+		body:   respBody,
+	}
+}
+
+// routeTest is a request to the test server with its expected JSON response
+type routeTest struct {
+	name string
+	// setup changes the state of the server before the request
+	setup  func(t *testing.T, ts *testServer)
+	method string
+	path   string
+	body   string
+	header map[string]string
+	status int
+	want   string
+}
+
+// runRouteTests runs every test against new test server with seedCars
+func runRouteTests(t *testing.T, tests []routeTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, nil)
+			if tt.setup != nil {
+				tt.setup(t, ts)
+			}
+			resp := ts.do(t, tt.method, tt.path, tt.body, tt.header)
+			assertJSONResponse(t, resp, tt.status, tt.want)
+		})
+	}
+}
+
+func assertJSONResponse(t *testing.T, resp response, status int, want string) {
+	t.Helper()
+	if resp.status != status {
+		t.Errorf("status = %d, want %d, body: %s", resp.status, status, resp.body)
+	}
+	if ct := resp.header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	assertJSON(t, resp.body, want)
+}
+
+// assertJSON compares JSON values, so the order of object keys and formatting do not matter
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("body = %s\nwant   %s", got, want)
+	}
+}
+
+// multipartFile returns the body of the form with the file and its Content-Type
+func multipartFile(t *testing.T, field string, name string, content string) (string, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile(field, name)
+	if err == nil {
+		_, err = part.Write([]byte(content))
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatalf("writing multipart form: %v", err)
+	}
+	return buf.String(), w.FormDataContentType()
+}
+
+func TestPanicRecovery(t *testing.T) {
+	r := repo.NewMemoryRepo()
+	ts := newTestServer(t, panicRepo{Repo: r})
+
+	resp := ts.do(t, http.MethodGet, "/api/v1/cars/1", "", nil)
+	assertJSONResponse(t, resp, http.StatusInternalServerError, serviceErrorJSON)
+
+	// the server keeps serving requests after the panic
+	resp = ts.do(t, http.MethodGet, "/api/v1/cars?limit=10&offset=0", "", nil)
+	assertJSONResponse(t, resp, http.StatusOK, `{"data":[],"error":null}`)
+}
+
+// TestInternalErrors checks that errors of storages are not exposed to clients, errors of the
+// database and unexpected errors are reported differently
+func TestInternalErrors(t *testing.T) {
+	validCar := `{"regNum":"B222BB50","mark":"Kia","model":"Rio","year":2018,"owner":{"name":"Пётр","surname":"Петров"}}`
+	validWebhook := `{"url":"https://partner.example/hook","events":["car.created"]}`
+	routes := []struct {
+		method string
+		path   string
+		body   string
+		header map[string]string
+	}{
+		{http.MethodGet, "/api/v1/cars/1", "", nil},
+		{http.MethodGet, "/api/v1/cars?limit=10&offset=0", "", nil},
+		{http.MethodGet, "/api/v1/cars", "", map[string]string{"Accept": "application/x-ndjson"}},
+		{http.MethodPost, "/api/v1/cars", `{"regNums":["E555EE199"]}`, nil},
+		{http.MethodGet, "/api/v1/cars/export", "", nil},
+		{http.MethodGet, "/api/v1/cars/export?format=xlsx", "", nil},
+		{http.MethodPut, "/api/v1/cars/1", validCar, nil},
+		{http.MethodDelete, "/api/v1/cars/1", "", nil},
+		{http.MethodPost, "/api/v1/imports", `{"regNums":["E555EE199"]}`, nil},
+		{http.MethodGet, "/api/v1/imports/1", "", nil},
+		{http.MethodPost, "/api/v1/imports/1/cancel", "", nil},
+		{http.MethodPost, "/api/v1/webhooks", validWebhook, nil},
+		{http.MethodGet, "/api/v1/webhooks", "", nil},
+		{http.MethodGet, "/api/v1/webhooks/1", "", nil},
+		{http.MethodPut, "/api/v1/webhooks/1", validWebhook, nil},
+		{http.MethodDelete, "/api/v1/webhooks/1", "", nil},
+		{http.MethodGet, "/api/v1/webhooks/1/deliveries", "", nil},
+		{http.MethodPost, "/api/v1/cars/1/refresh", "", nil},
+		{http.MethodGet, "/api/v1/refreshes", "", nil},
+		{http.MethodGet, "/api/v1/refreshes/1", "", nil},
+		{http.MethodPost, "/api/v1/refreshes/1/apply", "", nil},
+		{http.MethodPost, "/api/v1/refreshes/1/reject", "", nil},
+	}
+	errs := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"database", errors.Join(model.ErrDatabaseError, errors.New("connection refused")), databaseErrorJSON},
+		{"unexpected", errors.New("unexpected error"), serviceErrorJSON},
+	}
+
+	for _, e := range errs {
+		ts := newTestServer(t, faultyRepo{Repo: repo.NewMemoryRepo(), err: e.err})
+		ts.jobs.err = e.err
+		ts.webhooks.err = e.err
+		ts.refreshes.err = e.err
+		for _, route := range routes {
+			t.Run(e.name+" "+route.method+" "+route.path, func(t *testing.T) {
+				resp := ts.do(t, route.method, route.path, route.body, route.header)
+				assertJSONResponse(t, resp, http.StatusInternalServerError, e.want)
+				if cd := resp.header.Get("Content-Disposition"); cd != "" {
+					t.Errorf("Content-Disposition = %q, want none", cd)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package httpserver_test
+
+import (
+	"bytes"
+	"cars-service/internal/app"
+	"cars-service/internal/config"
+	"cars-service/internal/ports/httpserver"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites golden files with the current output: go test ./internal/ports/httpserver -update
+var update = flag.Bool("update", false, "update golden files")
+
+const swaggerGolden = "testdata/swagger.golden.json"
+
+// TestSwaggerGolden fails on any change of the served spec, so changes of the API are noticed
+// in review, the golden file is updated with -update after regenerating docs
+func TestSwaggerGolden(t *testing.T) {
+	ts := newTestServer(t, nil)
+	resp := ts.do(t, http.MethodGet, "/swagger/doc.json", "", nil)
+	if resp.status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.status, http.StatusOK)
+	}
+	var spec bytes.Buffer
+	if err := json.Indent(&spec, resp.body, "", "  "); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+	spec.WriteByte('\n')
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(swaggerGolden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(swaggerGolden, spec.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := os.ReadFile(swaggerGolden)
+	if err != nil {
+		t.Fatalf("reading golden file: %v, run the test with -update to create it", err)
+	}
+	if !bytes.Equal(spec.Bytes(), golden) {
+		t.Errorf("swagger spec differs from %s, run the test with -update if the change of the API is intended\n%s",
+			swaggerGolden, firstDiff(string(golden), spec.String()))
+	}
+}
+
+// TestSwaggerCoversRoutes checks that every route is documented and every documented operation exists
+func TestSwaggerCoversRoutes(t *testing.T) {
+	logs, err := logger.NewWithLevel("fatal")
+	if err != nil {
+		t.Fatalf("NewWithLevel: %v", err)
+	}
+	a := app.New(repo.NewMemoryRepo(), newJobRepo(), newWebhookRepo(), newRefreshRepo(), nil, logs)
+	router, ok := httpserver.New(config.Default().Server, a, repo.NewMemoryRateLimitRepo(), newIdempotencyRepo(), logs).Handler.(*gin.Engine)
+	if !ok {
+		t.Fatal("handler of the server is not gin.Engine")
+	}
+
+	golden, err := os.ReadFile(swaggerGolden)
+	if err != nil {
+		t.Fatalf("reading golden file: %v", err)
+	}
+	var spec struct {
+		BasePath string                    `json:"basePath"`
+		Paths    map[string]map[string]any `json:"paths"`
+	}
+	if err = json.Unmarshal(golden, &spec); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+
+	documented := make(map[string]bool)
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+spec.BasePath+path] = true
+		}
+	}
+	for _, route := range router.Routes() {
+		if !strings.HasPrefix(route.Path, spec.BasePath+"/") {
+			continue
+		}
+		// gin parameters :id are {id} in the spec
+		segments := strings.Split(route.Path, "/")
+		for i, s := range segments {
+			if strings.HasPrefix(s, ":") {
+				segments[i] = "{" + s[1:] + "}"
+			}
+		}
+		operation := route.Method + " " + strings.Join(segments, "/")
+		if !documented[operation] {
+			t.Errorf("%s is not documented", operation)
+		}
+		delete(documented, operation)
+	}
+	for operation := range documented {
+		t.Errorf("%s is documented but not routed", operation)
+	}
+}
+
+// firstDiff returns the first line which differs in got with a few lines around it, it is enough
+// to find the change in the indented spec
+func firstDiff(want string, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	i := 0
+	for i < len(wantLines) && i < len(gotLines) && wantLines[i] == gotLines[i] {
+		i++
+	}
+	from, to := max(i-3, 0), min(i+4, len(gotLines))
+	return fmt.Sprintf("line %d:\n%s", i+1, strings.Join(gotLines[from:to], "\n"))
+}
@@ -0,0 +1,128 @@
+package httpserver
+
+import (
+	"cars-service/internal/config"
+	"cars-service/pkg/logger"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is the minimal interval between checks of changes of certificate files
+const certCheckInterval = 10 * time.Second
+
+// NewTLSConfig returns the config of HTTPS server which loads the certificate again when its files
+// are changed, so renewed certificates are used without restart. It returns nil if TLS is not
+// configured
+func NewTLSConfig(cfg config.TLS, logs logger.Logger) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	certs := &certReloader{
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		logs:     logs,
+		now:      time.Now,
+	}
+	if err := certs.load(); err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+
+	switch cfg.ClientAuth {
+	case "optional":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.ClientCAFile)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// certReloader keeps the certificate of the server and loads it again on handshakes after its
+// files are changed, the previous certificate is used until the changed files are loaded
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logs     logger.Logger
+	now      func() time.Time
+
+	mu   sync.Mutex
+	cert *tls.Certificate
+	// modTime is the latest modification time of the loaded files
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := r.now(); now.Sub(r.checkedAt) >= certCheckInterval {
+		r.checkedAt = now
+		r.reloadIfChanged()
+	}
+	return r.cert, nil
+}
+
+// reloadIfChanged loads the files if they are changed since the last load, failures are retried
+// by the next check as the key may be written after the certificate, the lock must be held
+func (r *certReloader) reloadIfChanged() {
+	modTime, err := r.filesModTime()
+	if err == nil && !modTime.After(r.modTime) {
+		return
+	}
+	if err == nil {
+		err = r.load()
+	}
+	if err != nil {
+		r.logs.Error(logger.Fields{"certFile": r.certFile}, "unable to reload certificate: "+err.Error())
+		return
+	}
+	r.logs.Info(logger.Fields{"certFile": r.certFile}, "certificate is reloaded")
+}
+
+// load reads the certificate and its key, the lock must be held
+func (r *certReloader) load() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// filesModTime returns the latest modification time of the certificate and key files
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
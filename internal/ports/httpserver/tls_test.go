@@ -0,0 +1,196 @@
+package httpserver
+
+import (
+	"cars-service/internal/config"
+	"cars-service/pkg/logger"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCert writes the certificate for localhost and its key to dir, the certificate is signed by
+// parent or self-signed if parent is nil, it can sign other certificates
+func writeCert(t *testing.T, dir string, name string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("Int: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	issuer, signer := template, any(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err = os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair: %v", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert
+}
+
+func newTestLogger(t *testing.T) logger.Logger {
+	t.Helper()
+	logs, err := logger.NewWithLevel("fatal")
+	if err != nil {
+		t.Fatalf("NewWithLevel: %v", err)
+	}
+	return logs
+}
+
+func TestCertReload(t *testing.T) {
+	dir := t.TempDir()
+	first := writeCert(t, dir, "server", nil)
+	now := time.Now()
+	certs := &certReloader{
+		certFile: filepath.Join(dir, "server.crt"),
+		keyFile:  filepath.Join(dir, "server.key"),
+		logs:     newTestLogger(t),
+		now:      func() time.Time { return now },
+	}
+	if err := certs.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	serial := func() *big.Int {
+		t.Helper()
+		cert, err := certs.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("GetCertificate: %v", err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatalf("ParseCertificate: %v", err)
+		}
+		return leaf.SerialNumber
+	}
+	if got := serial(); got.Cmp(first.Leaf.SerialNumber) != 0 {
+		t.Fatalf("serial = %v, want %v", got, first.Leaf.SerialNumber)
+	}
+
+	// the renewed certificate is used after the next check
+	second := writeCert(t, dir, "server", nil)
+	future := now.Add(time.Minute)
+	for _, name := range []string{certs.certFile, certs.keyFile} {
+		if err := os.Chtimes(name, future, future); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+	if got := serial(); got.Cmp(first.Leaf.SerialNumber) != 0 {
+		t.Errorf("serial before check = %v, want %v", got, first.Leaf.SerialNumber)
+	}
+	now = now.Add(certCheckInterval)
+	if got := serial(); got.Cmp(second.Leaf.SerialNumber) != 0 {
+		t.Errorf("serial after check = %v, want %v", got, second.Leaf.SerialNumber)
+	}
+
+	// the broken file does not replace the loaded certificate
+	if err := os.WriteFile(certs.keyFile, []byte("broken"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chtimes(certs.keyFile, future.Add(time.Minute), future.Add(time.Minute)); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	now = now.Add(certCheckInterval)
+	if got := serial(); got.Cmp(second.Leaf.SerialNumber) != 0 {
+		t.Errorf("serial after broken key = %v, want %v", got, second.Leaf.SerialNumber)
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	server := writeCert(t, dir, "server", nil)
+	ca := writeCert(t, dir, "ca", nil)
+	client := writeCert(t, dir, "client", &ca)
+	other := writeCert(t, dir, "other", nil)
+
+	tlsConfig, err := NewTLSConfig(config.TLS{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientAuth:   "require",
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}, newTestLogger(t))
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = tlsConfig
+	// rejected handshakes are expected
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Leaf)
+	get := func(certs []tls.Certificate) error {
+		t.Helper()
+		httpClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		// the name is sent in SNI, httptest uses its own certificate without it
+		resp, err := httpClient.Get(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		return nil
+	}
+	if err = get([]tls.Certificate{client}); err != nil {
+		t.Errorf("request with client certificate: %v", err)
+	}
+	if err = get(nil); err == nil {
+		t.Error("request without client certificate is accepted")
+	}
+	if err = get([]tls.Certificate{other}); err == nil {
+		t.Error("request with certificate of unknown CA is accepted")
+	}
+}
@@ -0,0 +1,249 @@
+package httpserver_test
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"net/http"
+	"testing"
+)
+
+// createWebhook adds the webhook with id 1 subscribed to new cars
+func createWebhook(t *testing.T, ts *testServer) {
+	t.Helper()
+	_, err := ts.webhooks.CreateWebhook(context.Background(), model.Webhook{
+		Url:    "https://partner.example/hook",
+		Events: []model.EventType{model.EventCarCreated},
+		Secret: "s3cret",
+		Active: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+}
+
+const (
+	webhookNotFoundJSON = `{"data":null,"error":"webhook not found"}`
+	// webhookJSON is the webhook made by createWebhook, the secret is shown only on creation
+	webhookJSON = `{"id":1,"url":"https://partner.example/hook","events":["car.created"],"filter":{},` +
+		`"active":true,"failures":0,"createdAt":"2024-05-01T12:00:00Z"}`
+	invalidWebhookJSON = `{"data":null,"error":"validation error","details":[` +
+		`{"field":"url","reason":"must be absolute http or https url"},` +
+		`{"field":"events","reason":"must not be empty"}]}`
+	privateWebhookJSON = `{"data":null,"error":"validation error","details":[` +
+		`{"field":"url","reason":"must not point to a local or private address"}]}`
+)
+
+func TestCreateWebhook(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "created",
+			method: http.MethodPost,
+			path:   "/api/v1/webhooks",
+			body: `{"url":" https://partner.example/hook ","events":["car.created","car.deleted"],` +
+				`"filter":{"mark":"Lada"},"secret":"s3cret"}`,
+			status: http.StatusCreated,
+			want: `{"data":{"id":1,"url":"https://partner.example/hook","events":["car.created","car.deleted"],` +
+				`"filter":{"mark":"Lada"},"secret":"s3cret","active":true,"failures":0,` +
+				`"createdAt":"2024-05-01T12:00:00Z"},"error":null}`,
+		},
+		{
+			name:   "invalid fields",
+			method: http.MethodPost,
+			path:   "/api/v1/webhooks",
+			body:   `{"url":"ftp://partner.example","events":[]}`,
+			status: http.StatusBadRequest,
+			want:   invalidWebhookJSON,
+		},
+		{
+			name:   "private address",
+			method: http.MethodPost,
+			path:   "/api/v1/webhooks",
+			body:   `{"url":"http://169.254.169.254/latest/meta-data","events":["car.created"]}`,
+			status: http.StatusBadRequest,
+			want:   privateWebhookJSON,
+		},
+		{
+			name:   "local name",
+			method: http.MethodPost,
+			path:   "/api/v1/webhooks",
+			body:   `{"url":"http://LOCALHOST:8080/hook","events":["car.created"]}`,
+			status: http.StatusBadRequest,
+			want:   privateWebhookJSON,
+		},
+		{
+			name:   "invalid body",
+			method: http.MethodPost,
+			path:   "/api/v1/webhooks",
+			body:   `{"url":42}`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestGetWebhooks(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "found",
+			setup:  createWebhook,
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks",
+			status: http.StatusOK,
+			want:   `{"data":[` + webhookJSON + `],"error":null}`,
+		},
+		{
+			name:   "empty",
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks",
+			status: http.StatusOK,
+			want:   `{"data":[],"error":null}`,
+		},
+	})
+}
+
+func TestGetWebhook(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "found",
+			setup:  createWebhook,
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks/1",
+			status: http.StatusOK,
+			want:   `{"data":` + webhookJSON + `,"error":null}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks/1",
+			status: http.StatusNotFound,
+			want:   webhookNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks/first",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "updated",
+			setup:  createWebhook,
+			method: http.MethodPut,
+			path:   "/api/v1/webhooks/1",
+			body:   `{"url":"https://partner.example/v2","events":["car.updated"],"filter":{"model":"Rio"},"active":false}`,
+			status: http.StatusOK,
+			want: `{"data":{"id":1,"url":"https://partner.example/v2","events":["car.updated"],` +
+				`"filter":{"model":"Rio"},"active":false,"failures":0,"createdAt":"2024-05-01T12:00:00Z"},"error":null}`,
+		},
+		{
+			name:   "invalid fields",
+			setup:  createWebhook,
+			method: http.MethodPut,
+			path:   "/api/v1/webhooks/1",
+			body:   `{"url":"partner.example","events":[]}`,
+			status: http.StatusBadRequest,
+			want:   invalidWebhookJSON,
+		},
+		{
+			name:   "not found",
+			method: http.MethodPut,
+			path:   "/api/v1/webhooks/1",
+			body:   `{"url":"https://partner.example/v2","events":["car.updated"]}`,
+			status: http.StatusNotFound,
+			want:   webhookNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodPut,
+			path:   "/api/v1/webhooks/first",
+			body:   `{"url":"https://partner.example/v2","events":["car.updated"]}`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+		{
+			name:   "invalid body",
+			setup:  createWebhook,
+			method: http.MethodPut,
+			path:   "/api/v1/webhooks/1",
+			body:   `{"events":"car.updated"}`,
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name:   "deleted",
+			setup:  createWebhook,
+			method: http.MethodDelete,
+			path:   "/api/v1/webhooks/1",
+			status: http.StatusOK,
+			want:   okJSON,
+		},
+		{
+			name:   "not found",
+			method: http.MethodDelete,
+			path:   "/api/v1/webhooks/1",
+			status: http.StatusNotFound,
+			want:   webhookNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodDelete,
+			path:   "/api/v1/webhooks/first",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
+
+func TestGetWebhookDeliveries(t *testing.T) {
+	runRouteTests(t, []routeTest{
+		{
+			name: "delivery of the new car",
+			setup: func(t *testing.T, ts *testServer) {
+				createWebhook(t, ts)
+				ts.webhooks.addDelivery(1, model.Event{
+					Type:  model.EventCarCreated,
+					CarId: 3,
+					Car:   model.Car{Id: 3, RegNum: "E555EE199"},
+				})
+			},
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks/1/deliveries",
+			status: http.StatusOK,
+			want: `{"data":[{"id":1,"event":"car.created","carId":3,"status":"pending","attempts":0,` +
+				`"nextAttemptAt":"2024-05-01T12:00:00Z","createdAt":"2024-05-01T12:00:00Z",` +
+				`"updatedAt":"2024-05-01T12:00:00Z"}],"error":null}`,
+		},
+		{
+			name:   "no deliveries",
+			setup:  createWebhook,
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks/1/deliveries",
+			status: http.StatusOK,
+			want:   `{"data":[],"error":null}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks/1/deliveries",
+			status: http.StatusNotFound,
+			want:   webhookNotFoundJSON,
+		},
+		{
+			name:   "invalid id",
+			method: http.MethodGet,
+			path:   "/api/v1/webhooks/first/deliveries",
+			status: http.StatusBadRequest,
+			want:   invalidInputJSON,
+		},
+	})
+}
@@ -0,0 +1,187 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// cachedRepoImpl keeps the least recently read cars of GetCarById in memory, cars are removed on
+// their changes made through it, changes of other instances are seen after ttl
+type cachedRepoImpl struct {
+	Repo
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu sync.Mutex
+	// order holds *cacheEntry from the most recently used one
+	order   *list.List
+	entries map[uint64]*list.Element
+	// generation is incremented on every invalidation, so cars read before it are not cached
+	generation uint64
+}
+
+// cacheEntry is the cached car or, if cached is not set, the version of the last change of the car
+// made through the cache. Cars read from lagging replicas after the change have lower versions
+// and are not cached until the entry expires
+type cacheEntry struct {
+	id         uint64
+	car        model.Car
+	cached     bool
+	minVersion uint64
+	expiresAt  time.Time
+}
+
+func (r *cachedRepoImpl) GetCarById(ctx context.Context, id uint64) (model.Car, error) {
+	// reads which must not be stale skip the cache, but their result is still cached
+	if !primaryRequired(ctx) {
+		if car, ok := r.get(id); ok {
+			return car, nil
+		}
+	}
+
+	r.mu.Lock()
+	generation := r.generation
+	r.mu.Unlock()
+	car, err := r.Repo.GetCarById(ctx, id)
+	if err != nil {
+		return model.Car{}, err
+	}
+	r.put(car, generation)
+	return car, nil
+}
+
+func (r *cachedRepoImpl) UpdateCar(ctx context.Context, id uint64, car model.Car) (model.Car, error) {
+	car, err := r.Repo.UpdateCar(ctx, id, car)
+	if err != nil {
+		// the car is removed on errors too as the update may be committed before the error
+		r.invalidate(id)
+		return model.Car{}, err
+	}
+	r.forget(id, car.Version)
+	return car, nil
+}
+
+func (r *cachedRepoImpl) DeleteCar(ctx context.Context, id uint64) error {
+	err := r.Repo.DeleteCar(ctx, id)
+	if err != nil {
+		r.invalidate(id)
+		return err
+	}
+	// ids are not reused, so the deleted car is never cached again
+	r.forget(id, math.MaxUint64)
+	return nil
+}
+
+func (r *cachedRepoImpl) MergeOwners(ctx context.Context, into uint64, from []uint64) ([]model.Car, error) {
+	cars, err := r.Repo.MergeOwners(ctx, into, from)
+	if err != nil {
+		// moved cars are unknown, so nothing cached is trusted
+		r.invalidate()
+		return nil, err
+	}
+	for _, car := range cars {
+		r.forget(car.Id, car.Version)
+	}
+	return cars, nil
+}
+
+// get returns the unexpired cached car and marks it recently used
+func (r *cachedRepoImpl) get(id uint64) (model.Car, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elem, ok := r.entries[id]
+	if !ok {
+		return model.Car{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !r.now().Before(entry.expiresAt) {
+		r.order.Remove(elem)
+		delete(r.entries, id)
+		return model.Car{}, false
+	}
+	if !entry.cached {
+		return model.Car{}, false
+	}
+	r.order.MoveToFront(elem)
+	car := entry.car
+	car.Sources = cloneSources(car.Sources)
+	return car, true
+}
+
+// put caches the car read in generation unless it is invalidated since then or it is older than
+// the last change made through the cache
+func (r *cachedRepoImpl) put(car model.Car, generation uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation != r.generation {
+		return
+	}
+	if elem, ok := r.entries[car.Id]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if car.Version < entry.minVersion && r.now().Before(entry.expiresAt) {
+			return
+		}
+	}
+	car.Sources = cloneSources(car.Sources)
+	r.set(&cacheEntry{
+		id:         car.Id,
+		car:        car,
+		cached:     true,
+		minVersion: car.Version,
+		expiresAt:  r.now().Add(r.ttl),
+	})
+}
+
+// forget removes the car changed through the cache and remembers the version of the change
+func (r *cachedRepoImpl) forget(id uint64, version uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	r.set(&cacheEntry{
+		id:         id,
+		minVersion: version,
+		expiresAt:  r.now().Add(r.ttl),
+	})
+}
+
+// set replaces the entry of the car, the least recently used entry is evicted if the cache is full
+func (r *cachedRepoImpl) set(entry *cacheEntry) {
+	if elem, ok := r.entries[entry.id]; ok {
+		elem.Value = entry
+		r.order.MoveToFront(elem)
+		return
+	}
+	r.entries[entry.id] = r.order.PushFront(entry)
+	if r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cacheEntry).id)
+	}
+}
+
+// invalidate removes cars with ids from the cache or all cars if no id is passed
+func (r *cachedRepoImpl) invalidate(ids ...uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	if len(ids) == 0 {
+		r.order.Init()
+		clear(r.entries)
+		return
+	}
+	for _, id := range ids {
+		if elem, ok := r.entries[id]; ok {
+			r.order.Remove(elem)
+			delete(r.entries, id)
+		}
+	}
+}
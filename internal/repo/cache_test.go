@@ -0,0 +1,129 @@
+package repo_test
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"cars-service/internal/repo/repotest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCachedRepo(t *testing.T) {
+	// the small cache evicts cars during the suite
+	repotest.Run(t, func(t *testing.T) repo.Repo {
+		return repo.NewCachedRepo(repo.NewMemoryRepo(), 2, time.Hour)
+	})
+}
+
+func TestCachedRepoInvalidation(t *testing.T) {
+	ctx := context.Background()
+	inner := repo.NewMemoryRepo()
+	cached := repo.NewCachedRepo(inner, 1, time.Hour)
+
+	first, err := cached.AddCar(ctx, model.Car{RegNum: "A111AA150", Mark: "Lada", Model: "Vesta", Year: 2020})
+	if err != nil {
+		t.Fatalf("AddCar(): %v", err)
+	}
+	second, err := cached.AddCar(ctx, model.Car{RegNum: "B222BB50", Mark: "Kia", Model: "Rio", Year: 2018})
+	if err != nil {
+		t.Fatalf("AddCar(): %v", err)
+	}
+	getYear := func(id uint64) int {
+		t.Helper()
+		car, err := cached.GetCarById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetCarById(%d): %v", id, err)
+		}
+		return car.Year
+	}
+	// updates do not check the version, like updates of PUT /cars
+	update := func(r repo.Repo, car model.Car, year int) {
+		t.Helper()
+		car.Year = year
+		car.Version = 0
+		if _, err := r.UpdateCar(ctx, car.Id, car); err != nil {
+			t.Fatalf("UpdateCar(): %v", err)
+		}
+	}
+
+	// changes behind the cache are not seen while the car is cached
+	getYear(first.Id)
+	update(inner, first, 2021)
+	if got := getYear(first.Id); got != 2020 {
+		t.Errorf("cached year = %d, want 2020", got)
+	}
+	// changes through the cache remove the car
+	update(cached, first, 2022)
+	if got := getYear(first.Id); got != 2022 {
+		t.Errorf("year after update = %d, want 2022", got)
+	}
+	// reading of the second car evicts the first one
+	getYear(second.Id)
+	update(inner, first, 2023)
+	if got := getYear(first.Id); got != 2023 {
+		t.Errorf("year after eviction = %d, want 2023", got)
+	}
+	if err = cached.DeleteCar(ctx, first.Id); err != nil {
+		t.Fatalf("DeleteCar(): %v", err)
+	}
+	if _, err = cached.GetCarById(ctx, first.Id); !errors.Is(err, model.ErrCarNotFound) {
+		t.Errorf("GetCarById() of deleted car error = %v, want %v", err, model.ErrCarNotFound)
+	}
+}
+
+// laggingRepo returns stale from GetCarById if it is set, like a replica which has not yet received
+// the latest change
+type laggingRepo struct {
+	repo.Repo
+	stale *model.Car
+}
+
+func (r *laggingRepo) GetCarById(ctx context.Context, id uint64) (model.Car, error) {
+	if r.stale != nil {
+		return *r.stale, nil
+	}
+	return r.Repo.GetCarById(ctx, id)
+}
+
+func TestCachedRepoLaggingReplica(t *testing.T) {
+	ctx := context.Background()
+	lagging := &laggingRepo{Repo: repo.NewMemoryRepo()}
+	cached := repo.NewCachedRepo(lagging, 10, time.Hour)
+
+	car, err := cached.AddCar(ctx, model.Car{RegNum: "A111AA150", Mark: "Lada", Model: "Vesta", Year: 2020})
+	if err != nil {
+		t.Fatalf("AddCar(): %v", err)
+	}
+	stale := car
+	lagging.stale = &stale
+	car.Year = 2021
+	if _, err = cached.UpdateCar(ctx, car.Id, car); err != nil {
+		t.Fatalf("UpdateCar(): %v", err)
+	}
+
+	// the stale car read after the update is not cached
+	if got, err := cached.GetCarById(ctx, car.Id); err != nil {
+		t.Fatalf("GetCarById(): %v", err)
+	} else if got.Year != 2020 {
+		t.Errorf("year of the lagging replica = %d, want 2020", got.Year)
+	}
+	lagging.stale = nil
+	if got, err := cached.GetCarById(ctx, car.Id); err != nil {
+		t.Fatalf("GetCarById(): %v", err)
+	} else if got.Year != 2021 {
+		t.Errorf("year after the replica caught up = %d, want 2021", got.Year)
+	}
+
+	// deleted cars are not cached again either
+	if err = cached.DeleteCar(ctx, car.Id); err != nil {
+		t.Fatalf("DeleteCar(): %v", err)
+	}
+	lagging.stale = &stale
+	_, _ = cached.GetCarById(ctx, car.Id)
+	lagging.stale = nil
+	if _, err = cached.GetCarById(ctx, car.Id); !errors.Is(err, model.ErrCarNotFound) {
+		t.Errorf("GetCarById() of deleted car error = %v, want %v", err, model.ErrCarNotFound)
+	}
+}
@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"cars-service/internal/config"
+	"cars-service/pkg/logger"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	baseConnectBackoff = 200 * time.Millisecond
+	maxConnectBackoff  = 5 * time.Second
+)
+
+// Connect creates pool of connections to the database with settings of cfg and waits until
+// postgres accepts connections, which takes a while when it starts together with the service
+func Connect(ctx context.Context, cfg config.Postgres, logs logger.Logger) (*pgxpool.Pool, error) {
+	poolCfg, err := poolConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid postgres config: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid postgres config: %w", err)
+	}
+
+	// the pool connects lazily, so ping checks that postgres is actually available
+	startupCtx, cancel := context.WithTimeout(ctx, cfg.StartupTimeout)
+	defer cancel()
+	delay := baseConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err = pool.Ping(startupCtx)
+		if err == nil {
+			return pool, nil
+		}
+		logs.Info(logger.Fields{"attempt": attempt, "retryIn": delay.String(), "error": err.Error()},
+			"postgres is not available")
+
+		select {
+		case <-startupCtx.Done():
+			pool.Close()
+			return nil, fmt.Errorf("unable to connect to postgres after %d attempts: %w", attempt, err)
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxConnectBackoff)
+	}
+}
+
+// poolConfig parses DSN or builds it from the connection fields, the password is left empty to
+// look it up in PassFile, and applies the settings of the pool
+func poolConfig(cfg config.Postgres) (*pgxpool.Config, error) {
+	dsn := cfg.DSN
+	if dsn == "" {
+		params := url.Values{"sslmode": {cfg.SSLMode}}
+		for name, value := range map[string]string{
+			"sslrootcert": cfg.SSLRootCert,
+			"sslcert":     cfg.SSLCert,
+			"sslkey":      cfg.SSLKey,
+			"passfile":    cfg.PassFile,
+		} {
+			if value != "" {
+				params.Set(name, value)
+			}
+		}
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.User(cfg.Username),
+			Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+			Path:     cfg.Name,
+			RawQuery: params.Encode(),
+		}
+		if cfg.Password != "" {
+			u.User = url.UserPassword(cfg.Username, cfg.Password)
+		}
+		dsn = u.String()
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg.MaxConns = cfg.MaxConns
+	poolCfg.MinConns = cfg.MinConns
+	poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	poolCfg.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+
+	if cfg.ApplicationName != "" {
+		poolCfg.ConnConfig.RuntimeParams["application_name"] = cfg.ApplicationName
+	}
+	if cfg.StatementTimeout > 0 {
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	return poolCfg, nil
+}
+
+// ConnectReplicas creates pools of connections to the replicas with settings of cfg, it does not
+// wait for replicas since ReplicatedRepo sends reads to them only after successful health check
+func ConnectReplicas(ctx context.Context, cfg config.Postgres) ([]*pgxpool.Pool, error) {
+	pools := make([]*pgxpool.Pool, 0, len(cfg.Replicas))
+	for i, dsn := range cfg.Replicas {
+		pool, err := connectReplica(ctx, cfg, dsn)
+		if err != nil {
+			for _, pool = range pools {
+				pool.Close()
+			}
+			return nil, fmt.Errorf("invalid config of replica %d: %w", i+1, err)
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func connectReplica(ctx context.Context, cfg config.Postgres, dsn string) (*pgxpool.Pool, error) {
+	cfg.DSN = dsn
+	poolCfg, err := poolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.NewWithConfig(ctx, poolCfg)
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sync/atomic"
+	"time"
+)
+
+// idempotencyCleanupInterval is the minimal interval between deletions of expired requests
+const idempotencyCleanupInterval = time.Minute
+
+type idempotencyRepoImpl struct {
+	*pgxpool.Pool
+	// cleanedAt is the unix time of the last deletion of expired requests
+	cleanedAt atomic.Int64
+}
+
+func (r *idempotencyRepoImpl) StartRequest(ctx context.Context, key string, fingerprint string, expiresAt time.Time, abandonedBefore time.Time) (model.IdempotentRequest, bool, error) {
+	r.deleteExpired(ctx)
+
+	// the saved request may expire between the queries, so they are repeated once
+	for attempt := 0; attempt < 2; attempt++ {
+		var saved string
+		err := r.QueryRow(ctx, startRequestQuery, key, fingerprint, expiresAt, abandonedBefore).Scan(&saved)
+		if err == nil {
+			return model.IdempotentRequest{Key: key, Fingerprint: fingerprint, ExpiresAt: expiresAt}, true, nil
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return model.IdempotentRequest{}, false, errors.Join(model.ErrDatabaseError, err)
+		}
+
+		req, err := scanIdempotentRequest(r.QueryRow(ctx, getRequestQuery, key))
+		if err == nil {
+			return req, false, nil
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return model.IdempotentRequest{}, false, errors.Join(model.ErrDatabaseError, err)
+		}
+	}
+	return model.IdempotentRequest{}, false, errors.Join(model.ErrDatabaseError, errors.New("idempotency key is changed concurrently"))
+}
+
+func (r *idempotencyRepoImpl) CompleteRequest(ctx context.Context, req model.IdempotentRequest) error {
+	if _, err := r.Exec(ctx, completeRequestQuery, req.Key, req.StatusCode, req.ContentType, req.Body); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepoImpl) DeleteRequest(ctx context.Context, key string) error {
+	if _, err := r.Exec(ctx, deleteRequestQuery, key); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+// deleteExpired deletes expired requests at most once per idempotencyCleanupInterval, its failure
+// is retried by the next request
+func (r *idempotencyRepoImpl) deleteExpired(ctx context.Context) {
+	now := time.Now().Unix()
+	last := r.cleanedAt.Load()
+	if now-last >= int64(idempotencyCleanupInterval/time.Second) && r.cleanedAt.CompareAndSwap(last, now) {
+		_, _ = r.Exec(ctx, deleteExpiredRequestsQuery)
+	}
+}
+
+func scanIdempotentRequest(row pgx.Row) (model.IdempotentRequest, error) {
+	var req model.IdempotentRequest
+	var statusCode *int
+	var contentType *string
+	if err := row.Scan(
+		&req.Key,
+		&req.Fingerprint,
+		&req.Completed,
+		&statusCode,
+		&contentType,
+		&req.Body,
+		&req.CreatedAt,
+		&req.ExpiresAt,
+	); err != nil {
+		return model.IdempotentRequest{}, err
+	}
+	if statusCode != nil {
+		req.StatusCode = *statusCode
+	}
+	if contentType != nil {
+		req.ContentType = *contentType
+	}
+	return req, nil
+}
+
+const (
+	// startRequestQuery saves the pending request, the saved request is replaced only if it is
+	// expired or abandoned by the instance which crashed while processing it
+	startRequestQuery = `
+		INSERT INTO "idempotency_keys" ("key", "fingerprint", "expires_at")
+		VALUES ($1, $2, CAST($3 AS TIMESTAMPTZ))
+		ON CONFLICT ("key") DO UPDATE
+		SET "fingerprint" = EXCLUDED."fingerprint",
+			"completed" = FALSE,
+			"status_code" = NULL,
+			"content_type" = NULL,
+			"body" = NULL,
+			"created_at" = NOW(),
+			"expires_at" = EXCLUDED."expires_at"
+		WHERE "idempotency_keys"."expires_at" < NOW()
+			OR (NOT "idempotency_keys"."completed" AND "idempotency_keys"."created_at" < CAST($4 AS TIMESTAMPTZ))
+		RETURNING "key";`
+
+	getRequestQuery = `
+		SELECT "key", "fingerprint", "completed", "status_code", "content_type", "body", "created_at", "expires_at"
+		FROM "idempotency_keys"
+		WHERE "key" = $1;`
+
+	completeRequestQuery = `
+		UPDATE "idempotency_keys"
+		SET "completed" = TRUE,
+			"status_code" = $2,
+			"content_type" = $3,
+			"body" = $4
+		WHERE "key" = $1;`
+
+	deleteRequestQuery = `
+		DELETE FROM "idempotency_keys"
+		WHERE "key" = $1;`
+
+	deleteExpiredRequestsQuery = `
+		DELETE FROM "idempotency_keys"
+		WHERE "expires_at" < NOW();`
+)
@@ -0,0 +1,295 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type importItemKey struct{}
+
+// WithImportItem returns ctx in which AddCar marks the import item done with the id of the added
+// car in its transaction, so the item is not imported again if the service stops right after it
+func WithImportItem(ctx context.Context, itemId uint64) context.Context {
+	return context.WithValue(ctx, importItemKey{}, itemId)
+}
+
+// setImportItemDone marks the import item of ctx done if there is one
+func setImportItemDone(ctx context.Context, q querier, carId uint64) error {
+	itemId, ok := ctx.Value(importItemKey{}).(uint64)
+	if !ok {
+		return nil
+	}
+	if _, err := q.Exec(ctx, setItemResultQuery, itemId, model.ImportDone, carId, ""); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+type jobRepoImpl struct {
+	*pgxpool.Pool
+}
+
+func (r *jobRepoImpl) CreateJob(ctx context.Context, regNums []string) (model.ImportJob, error) {
+	tx, err := r.Begin(ctx)
+	if err != nil {
+		return model.ImportJob{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	job := model.ImportJob{
+		Status: model.ImportPending,
+		Total:  len(regNums),
+	}
+	if err = tx.QueryRow(ctx, insertJobQuery, job.Status).Scan(
+		&job.Id,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	); err != nil {
+		return model.ImportJob{}, errors.Join(model.ErrDatabaseError, err)
+	}
+
+	rows := make([][]any, len(regNums))
+	for i, regNum := range regNums {
+		rows[i] = []any{job.Id, regNum, string(model.ImportPending)}
+	}
+	if _, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"import_items"},
+		[]string{"job_id", "reg_num", "status"},
+		pgx.CopyFromRows(rows),
+	); err != nil {
+		return model.ImportJob{}, errors.Join(model.ErrDatabaseError, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return model.ImportJob{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	return job, nil
+}
+
+func (r *jobRepoImpl) GetJob(ctx context.Context, id uint64) (model.ImportJob, error) {
+	job := model.ImportJob{Id: id}
+	if err := r.QueryRow(ctx, getJobQuery, id).Scan(
+		&job.Status,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.Total,
+		&job.Succeeded,
+		&job.Failed,
+	); errors.Is(err, pgx.ErrNoRows) {
+		return model.ImportJob{}, model.ErrJobNotFound
+	} else if err != nil {
+		return model.ImportJob{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	job.Processed = job.Succeeded + job.Failed
+
+	rows, err := r.Query(ctx, getJobItemsQuery, id)
+	if err != nil {
+		return model.ImportJob{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	job.Items = make([]model.ImportItem, 0, job.Total)
+	for rows.Next() {
+		item, err := scanImportItem(rows)
+		if err != nil {
+			return model.ImportJob{}, errors.Join(model.ErrDatabaseError, err)
+		}
+		job.Items = append(job.Items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return model.ImportJob{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	return job, nil
+}
+
+func (r *jobRepoImpl) GetJobStatus(ctx context.Context, id uint64) (model.ImportStatus, error) {
+	var status model.ImportStatus
+	if err := r.QueryRow(ctx, getJobStatusQuery, id).Scan(&status); errors.Is(err, pgx.ErrNoRows) {
+		return "", model.ErrJobNotFound
+	} else if err != nil {
+		return "", errors.Join(model.ErrDatabaseError, err)
+	}
+	return status, nil
+}
+
+func (r *jobRepoImpl) SetJobStatus(ctx context.Context, id uint64, status model.ImportStatus) error {
+	e, err := r.Exec(ctx, setJobStatusQuery, id, status)
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	} else if e.RowsAffected() != 0 {
+		return nil
+	}
+
+	// nothing was updated, so the job either does not exist or is already finished
+	if _, err = r.GetJobStatus(ctx, id); err != nil {
+		return err
+	}
+	return model.ErrJobFinished
+}
+
+func (r *jobRepoImpl) CancelJob(ctx context.Context, id uint64) error {
+	return r.SetJobStatus(ctx, id, model.ImportCancelled)
+}
+
+func (r *jobRepoImpl) GetActiveJobs(ctx context.Context) ([]model.ImportJob, error) {
+	rows, err := r.Query(ctx, getActiveJobsQuery)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	var jobs []model.ImportJob
+	for rows.Next() {
+		var job model.ImportJob
+		if err = rows.Scan(&job.Id, &job.Status, &job.CreatedAt, &job.UpdatedAt); err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	return jobs, nil
+}
+
+func (r *jobRepoImpl) GetPendingItems(ctx context.Context, jobId uint64, limit uint) ([]model.ImportItem, error) {
+	rows, err := r.Query(ctx, getPendingItemsQuery, jobId, limit)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	items := make([]model.ImportItem, 0, limit)
+	for rows.Next() {
+		item, err := scanImportItem(rows)
+		if err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	return items, nil
+}
+
+func (r *jobRepoImpl) HasPendingItems(ctx context.Context, jobId uint64) (bool, error) {
+	var pending bool
+	if err := r.QueryRow(ctx, hasPendingItemsQuery, jobId).Scan(&pending); err != nil {
+		return false, errors.Join(model.ErrDatabaseError, err)
+	}
+	return pending, nil
+}
+
+func (r *jobRepoImpl) RetryItem(ctx context.Context, id uint64, retryAt time.Time) error {
+	if _, err := r.Exec(ctx, retryItemQuery, id, retryAt); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+func (r *jobRepoImpl) SetItemResult(ctx context.Context, item model.ImportItem) error {
+	if _, err := r.Exec(ctx, setItemResultQuery,
+		item.Id,
+		item.Status,
+		item.CarId,
+		item.Error,
+	); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+// scanImportItem scans a row of getJobItemsQuery or getPendingItemsQuery
+func scanImportItem(rows pgx.Rows) (model.ImportItem, error) {
+	var item model.ImportItem
+	var carId *uint64
+	var errText *string
+	if err := rows.Scan(
+		&item.Id,
+		&item.RegNum,
+		&item.Status,
+		&carId,
+		&errText,
+		&item.Attempts,
+	); err != nil {
+		return model.ImportItem{}, err
+	}
+	if carId != nil {
+		item.CarId = *carId
+	}
+	if errText != nil {
+		item.Error = *errText
+	}
+	return item, nil
+}
+
+const (
+	insertJobQuery = `
+		INSERT INTO "import_jobs" ("status")
+		VALUES ($1)
+		RETURNING "id", "created_at", "updated_at";`
+
+	getJobQuery = `
+		SELECT "status", "created_at", "updated_at",
+			(SELECT COUNT(*) FROM "import_items" WHERE "job_id" = $1),
+			(SELECT COUNT(*) FROM "import_items" WHERE "job_id" = $1 AND "status" = 'done'),
+			(SELECT COUNT(*) FROM "import_items" WHERE "job_id" = $1 AND "status" = 'failed')
+		FROM "import_jobs"
+		WHERE "id" = $1;`
+
+	getJobStatusQuery = `
+		SELECT "status"
+		FROM "import_jobs"
+		WHERE "id" = $1;`
+
+	getJobItemsQuery = `
+		SELECT "id", "reg_num", "status", "car_id", "error", "attempts"
+		FROM "import_items"
+		WHERE "job_id" = $1
+		ORDER BY "id";`
+
+	setJobStatusQuery = `
+		UPDATE "import_jobs"
+		SET "status" = $2,
+			"updated_at" = NOW()
+		WHERE "id" = $1 AND "status" IN ('pending', 'running');`
+
+	getActiveJobsQuery = `
+		SELECT "id", "status", "created_at", "updated_at"
+		FROM "import_jobs"
+		WHERE "status" IN ('pending', 'running')
+		ORDER BY "id";`
+
+	getPendingItemsQuery = `
+		SELECT "id", "reg_num", "status", "car_id", "error", "attempts"
+		FROM "import_items"
+		WHERE "job_id" = $1 AND "status" = 'pending' AND "retry_at" <= NOW()
+		ORDER BY "id"
+		LIMIT $2;`
+
+	hasPendingItemsQuery = `
+		SELECT EXISTS (
+			SELECT 1 FROM "import_items"
+			WHERE "job_id" = $1 AND "status" = 'pending'
+		);`
+
+	retryItemQuery = `
+		UPDATE "import_items"
+		SET "attempts" = "attempts" + 1,
+			"retry_at" = CAST($2 AS TIMESTAMPTZ)
+		WHERE "id" = $1 AND "status" = 'pending';`
+
+	setItemResultQuery = `
+		UPDATE "import_items"
+		SET "status" = $2,
+			"car_id" = NULLIF($3, 0),
+			"error" = NULLIF($4, '')
+		WHERE "id" = $1;`
+)
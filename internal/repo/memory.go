@@ -0,0 +1,330 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// memoryRepoImpl keeps cars in memory with the same semantics as repoImpl, change events are
+// not written since there is no outbox
+type memoryRepoImpl struct {
+	mu sync.RWMutex
+
+	cars       map[uint64]model.Car
+	owners     map[uint64]model.Owner
+	ownerships []memoryOwnership
+
+	lastCarId   uint64
+	lastOwnerId uint64
+}
+
+// memoryOwnership is a row of ownerships, the owner is joined on reading like in repoImpl
+type memoryOwnership struct {
+	carId   uint64
+	ownerId uint64
+	from    time.Time
+	till    time.Time
+}
+
+func (r *memoryRepoImpl) GetCarById(_ context.Context, id uint64) (model.Car, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	car, ok := r.cars[id]
+	if !ok {
+		return model.Car{}, model.ErrCarNotFound
+	}
+	return car, nil
+}
+
+func (r *memoryRepoImpl) GetCars(_ context.Context, filter model.Filter) ([]model.Car, error) {
+	cars, err := r.filterCars(filter)
+	if err != nil {
+		return []model.Car{}, err
+	}
+	return paginate(cars, filter.Offset, filter.Limit), nil
+}
+
+func (r *memoryRepoImpl) IterateCars(ctx context.Context, filter model.Filter, fn func(model.Car) error) error {
+	cars, err := r.filterCars(filter)
+	if err != nil {
+		return err
+	}
+	// zero limit means no limit unlike GetCars
+	limit := filter.Limit
+	if limit == 0 {
+		limit = uint(len(cars))
+	}
+	cars = paginate(cars, filter.Offset, limit)
+
+	// fn is called without the lock, so it can use the repo
+	for _, car := range cars {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = fn(car); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepoImpl) AddCar(_ context.Context, car model.Car) (model.Car, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.regNumExists(car.RegNum, 0) {
+		return model.Car{}, model.ErrDuplicateRegNum
+	}
+	r.lastCarId++
+	car.Id = r.lastCarId
+	car.Version = 1
+	car.Owner.Id = r.ownerId(car.Owner)
+	car.Sources = cloneSources(car.Sources)
+	r.cars[car.Id] = car
+	r.ownerships = append(r.ownerships, memoryOwnership{
+		carId:   car.Id,
+		ownerId: car.Owner.Id,
+		from:    time.Now(),
+	})
+	return car, nil
+}
+
+func (r *memoryRepoImpl) UpdateCar(_ context.Context, id uint64, car model.Car) (model.Car, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.cars[id]
+	if !ok {
+		return model.Car{}, model.ErrCarNotFound
+	}
+	if car.Version != 0 && car.Version != prev.Version {
+		return model.Car{}, model.ErrCarChanged
+	}
+	if r.regNumExists(car.RegNum, id) {
+		return model.Car{}, model.ErrDuplicateRegNum
+	}
+	if car.Sources == nil {
+		car.Sources = updatedSources(prev, car)
+	}
+	car.Id = id
+	car.Version = prev.Version + 1
+	car.Owner.Id = r.ownerId(car.Owner)
+	car.Sources = cloneSources(car.Sources)
+	r.cars[id] = car
+
+	if prev.Owner.Id != car.Owner.Id {
+		now := time.Now()
+		for i := range r.ownerships {
+			if r.ownerships[i].carId == id && r.ownerships[i].till.IsZero() {
+				r.ownerships[i].till = now
+			}
+		}
+		r.ownerships = append(r.ownerships, memoryOwnership{
+			carId:   id,
+			ownerId: car.Owner.Id,
+			from:    now,
+		})
+	}
+	return car, nil
+}
+
+func (r *memoryRepoImpl) DeleteCar(_ context.Context, id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.cars[id]; !ok {
+		return model.ErrCarNotFound
+	}
+	delete(r.cars, id)
+	r.ownerships = slices.DeleteFunc(r.ownerships, func(o memoryOwnership) bool {
+		return o.carId == id
+	})
+	return nil
+}
+
+func (r *memoryRepoImpl) GetOwnerById(_ context.Context, id uint64) (model.Owner, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	owner, ok := r.owners[id]
+	if !ok {
+		return model.Owner{}, model.ErrOwnerNotFound
+	}
+	return owner, nil
+}
+
+func (r *memoryRepoImpl) GetCarsByOwnerIds(_ context.Context, ownerIds []uint64) (map[uint64][]model.Car, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cars := make(map[uint64][]model.Car, len(ownerIds))
+	for _, car := range r.sortedCars() {
+		if slices.Contains(ownerIds, car.Owner.Id) {
+			cars[car.Owner.Id] = append(cars[car.Owner.Id], car)
+		}
+	}
+	return cars, nil
+}
+
+func (r *memoryRepoImpl) GetOwnershipHistory(_ context.Context, carIds []uint64) (map[uint64][]model.Ownership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// ownerships are appended in the order of their ids
+	history := make(map[uint64][]model.Ownership, len(carIds))
+	for _, o := range r.ownerships {
+		if slices.Contains(carIds, o.carId) {
+			history[o.carId] = append(history[o.carId], model.Ownership{
+				CarId: o.carId,
+				Owner: r.owners[o.ownerId],
+				From:  o.from,
+				Till:  o.till,
+			})
+		}
+	}
+	return history, nil
+}
+
+func (r *memoryRepoImpl) MergeOwners(_ context.Context, into uint64, from []uint64) ([]model.Car, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.owners[into]; !ok {
+		return nil, model.ErrOwnerNotFound
+	}
+	// like DELETE in repoImpl, repeated ids are counted once and the merge is not applied partially
+	deleted := 0
+	for id := range r.owners {
+		if slices.Contains(from, id) {
+			deleted++
+		}
+	}
+	if deleted != len(from) {
+		return nil, model.ErrOwnerNotFound
+	}
+
+	for _, id := range from {
+		delete(r.owners, id)
+	}
+	for i := range r.ownerships {
+		if slices.Contains(from, r.ownerships[i].ownerId) {
+			r.ownerships[i].ownerId = into
+		}
+	}
+	cars := []model.Car{}
+	for _, car := range r.sortedCars() {
+		if slices.Contains(from, car.Owner.Id) {
+			car.Owner = r.owners[into]
+			car.Version++
+			r.cars[car.Id] = car
+			cars = append(cars, car)
+		}
+	}
+	return cars, nil
+}
+
+// filterCars returns all cars matching the filter in the order of the filter
+func (r *memoryRepoImpl) filterCars(filter model.Filter) ([]model.Car, error) {
+	compare, ok := carComparators[filter.SortBy]
+	if !ok {
+		return nil, model.ErrInvalidInput
+	}
+
+	r.mu.RLock()
+	cars := make([]model.Car, 0, len(r.cars))
+	for _, car := range r.cars {
+		if matches(car, filter) {
+			cars = append(cars, car)
+		}
+	}
+	r.mu.RUnlock()
+
+	// cars with equal values are ordered by id so pages do not overlap
+	slices.SortFunc(cars, func(a, b model.Car) int {
+		c := compare(a, b)
+		if c == 0 {
+			c = cmp.Compare(a.Id, b.Id)
+		}
+		if filter.SortDesc {
+			return -c
+		}
+		return c
+	})
+	return cars, nil
+}
+
+// sortedCars returns all cars ordered by id, the lock must be held
+func (r *memoryRepoImpl) sortedCars() []model.Car {
+	cars := make([]model.Car, 0, len(r.cars))
+	for _, car := range r.cars {
+		cars = append(cars, car)
+	}
+	slices.SortFunc(cars, func(a, b model.Car) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return cars
+}
+
+// regNumExists reports whether a car other than the car with id has the regNum, the lock must be held
+func (r *memoryRepoImpl) regNumExists(regNum string, id uint64) bool {
+	for _, car := range r.cars {
+		if car.RegNum == regNum && car.Id != id {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerId returns id of existing owner with the same name or adds new owner, the lock must be held
+func (r *memoryRepoImpl) ownerId(owner model.Owner) uint64 {
+	for id, o := range r.owners {
+		if o.Name == owner.Name && o.Surname == owner.Surname && o.Patronymic == owner.Patronymic {
+			return id
+		}
+	}
+	r.lastOwnerId++
+	owner.Id = r.lastOwnerId
+	r.owners[owner.Id] = owner
+	return owner.Id
+}
+
+// cloneSources copies sources, so the caller can't change the stored car, empty sources are nil
+// like in repoImpl
+func cloneSources(sources map[string]string) map[string]string {
+	if len(sources) == 0 {
+		return nil
+	}
+	return maps.Clone(sources)
+}
+
+// carComparators are comparators of cars for every sort field of the filter
+var carComparators = map[model.CarSortField]func(a, b model.Car) int{
+	"":                 func(a, b model.Car) int { return cmp.Compare(a.Id, b.Id) },
+	model.SortById:     func(a, b model.Car) int { return cmp.Compare(a.Id, b.Id) },
+	model.SortByRegNum: func(a, b model.Car) int { return cmp.Compare(a.RegNum, b.RegNum) },
+	model.SortByMark:   func(a, b model.Car) int { return cmp.Compare(a.Mark, b.Mark) },
+	model.SortByModel:  func(a, b model.Car) int { return cmp.Compare(a.Model, b.Model) },
+	model.SortByYear:   func(a, b model.Car) int { return cmp.Compare(a.Year, b.Year) },
+}
+
+func matches(car model.Car, filter model.Filter) bool {
+	return (!filter.ByRegNum || car.RegNum == filter.RegNum) &&
+		(!filter.ByMark || car.Mark == filter.Mark) &&
+		(!filter.ByModel || car.Model == filter.Model) &&
+		(!filter.ByYear || car.Year == filter.Year) &&
+		(!filter.ByOwnerName || car.Owner.Name == filter.OwnerName) &&
+		(!filter.ByOwnerSurname || car.Owner.Surname == filter.OwnerSurname) &&
+		(!filter.ByOwnerPatronymic || car.Owner.Patronymic == filter.OwnerPatronymic)
+}
+
+// paginate returns the page of cars like LIMIT and OFFSET, zero limit returns no cars
+func paginate(cars []model.Car, offset uint, limit uint) []model.Car {
+	start := min(offset, uint(len(cars)))
+	return cars[start : start+min(limit, uint(len(cars))-start)]
+}
@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryRateLimitMaxCounters is the max number of counters kept in memory, so clients coming from
+// many addresses can't exhaust memory of the instance
+const memoryRateLimitMaxCounters = 100_000
+
+// memoryRateLimitRepoImpl keeps counters of rate limits of a single instance in memory
+type memoryRateLimitRepoImpl struct {
+	mu        sync.Mutex
+	counters  map[string]memoryCounter
+	cleanedAt time.Time
+}
+
+// memoryCounter is a row of rate_limits
+type memoryCounter struct {
+	start   time.Time
+	expires time.Time
+	count   int
+}
+
+func (r *memoryRateLimitRepoImpl) Hit(_ context.Context, key string, start time.Time, period time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	counter, ok := r.counters[key]
+	if !ok && len(r.counters) >= memoryRateLimitMaxCounters {
+		r.cleanup(now)
+		// a counter of the full store is dropped, its client gets a new window at worst
+		for k := range r.counters {
+			if len(r.counters) < memoryRateLimitMaxCounters {
+				break
+			}
+			delete(r.counters, k)
+		}
+	}
+	if start.After(counter.start) {
+		counter = memoryCounter{start: start, expires: start.Add(period)}
+	}
+	counter.count++
+	r.counters[key] = counter
+
+	if now.Sub(r.cleanedAt) >= rateLimitCleanupInterval {
+		r.cleanup(now)
+	}
+	return counter.count, nil
+}
+
+// cleanup deletes counters of ended windows
+func (r *memoryRateLimitRepoImpl) cleanup(now time.Time) {
+	for k, c := range r.counters {
+		if c.expires.Before(now) {
+			delete(r.counters, k)
+		}
+	}
+	r.cleanedAt = now
+}
@@ -0,0 +1,13 @@
+package repo_test
+
+import (
+	"cars-service/internal/repo"
+	"cars-service/internal/repo/repotest"
+	"testing"
+)
+
+func TestMemoryRepo(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repo.Repo {
+		return repo.NewMemoryRepo()
+	})
+}
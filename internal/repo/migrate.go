@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a SQL file which version is the numeric prefix of its name like 001_db_init.sql
+type Migration struct {
+	Version int
+	Name    string
+}
+
+// Migrate applies SQL files of fsys which are not applied yet, each file in its own transaction.
+// Migrations with versions up to baseline are only marked as applied, it is needed for databases
+// initialized from the migrations directory by docker-entrypoint-initdb.d. Instances starting at
+// once apply migrations one by one
+func Migrate(ctx context.Context, pool *pgxpool.Pool, fsys fs.FS, baseline int) ([]Migration, error) {
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer conn.Release()
+	if _, err = conn.Exec(ctx, lockMigrationsQuery); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), unlockMigrationsQuery)
+	}()
+
+	if _, err = conn.Exec(ctx, createMigrationsTableQuery); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	isApplied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if isApplied[m.Version] {
+			continue
+		}
+		var script []byte
+		if m.Version > baseline {
+			if script, err = fs.ReadFile(fsys, m.Name); err != nil {
+				return done, err
+			}
+		}
+		if err = applyMigration(ctx, conn, m, string(script)); err != nil {
+			return done, fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// PendingMigrations returns SQL files of fsys which are not applied yet, all of them are pending if
+// the database has never been migrated
+func PendingMigrations(ctx context.Context, pool *pgxpool.Pool, fsys fs.FS) ([]Migration, error) {
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err = pool.QueryRow(ctx, migrationsTableExistsQuery).Scan(&exists); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	if !exists {
+		return migrations, nil
+	}
+	isApplied, err := appliedMigrations(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if !isApplied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// appliedMigrations returns versions saved in the table of migrations
+func appliedMigrations(ctx context.Context, q querier) (map[int]bool, error) {
+	rows, err := q.Query(ctx, getAppliedMigrationsQuery)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	applied, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	isApplied := make(map[int]bool, len(applied))
+	for _, v := range applied {
+		isApplied[v] = true
+	}
+	return isApplied, nil
+}
+
+// applyMigration runs the script and saves the version in the same transaction
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, m Migration, script string) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if script != "" {
+		// statements without arguments are sent by simple protocol, so the whole file is executed
+		if _, err = tx.Exec(ctx, script); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+	}
+	if _, err = tx.Exec(ctx, insertMigrationQuery, m.Version, m.Name); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+// readMigrations returns SQL files of fsys ordered by version
+func readMigrations(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(names))
+	versions := make(map[int]string, len(names))
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no numeric prefix", name)
+		}
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, name)
+		}
+		versions[version] = name
+		migrations = append(migrations, Migration{Version: version, Name: name})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+const (
+	// lockMigrationsQuery takes the session lock, so concurrent Migrate calls wait for each other
+	lockMigrationsQuery = `
+		SELECT pg_advisory_lock(hashtext('schema_migrations'));`
+
+	unlockMigrationsQuery = `
+		SELECT pg_advisory_unlock(hashtext('schema_migrations'));`
+
+	migrationsTableExistsQuery = `
+		SELECT to_regclass('schema_migrations') IS NOT NULL;`
+
+	createMigrationsTableQuery = `
+		CREATE TABLE IF NOT EXISTS "schema_migrations" (
+			"version" INTEGER PRIMARY KEY,
+			"name" VARCHAR(255) NOT NULL,
+			"applied_at" TIMESTAMP NOT NULL DEFAULT NOW()
+		);`
+
+	getAppliedMigrationsQuery = `
+		SELECT "version"
+		FROM "schema_migrations"
+		ORDER BY "version";`
+
+	insertMigrationQuery = `
+		INSERT INTO "schema_migrations" ("version", "name")
+		VALUES ($1, $2);`
+)
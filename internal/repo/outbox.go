@@ -0,0 +1,213 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"slices"
+	"time"
+)
+
+type outboxRepoImpl struct {
+	*pgxpool.Pool
+}
+
+func (r *outboxRepoImpl) ClaimEvents(ctx context.Context, limit uint, claimedUntil time.Time) ([]model.Event, error) {
+	rows, err := r.Query(ctx, claimEventsQuery, limit, claimedUntil)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	events := make([]model.Event, 0, limit)
+	for rows.Next() {
+		var event model.Event
+		var payload []byte
+		if err = rows.Scan(
+			&event.Id,
+			&event.CarId,
+			&event.Type,
+			&payload,
+			&event.CreatedAt,
+			&event.Attempts,
+		); err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		if err = decodeEventPayload(payload, &event); err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	// rows returned by UPDATE are not ordered
+	slices.SortFunc(events, func(a, b model.Event) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return events, nil
+}
+
+func (r *outboxRepoImpl) MarkDelivered(ctx context.Context, id uint64) error {
+	if _, err := r.Exec(ctx, markEventDeliveredQuery, id); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+func (r *outboxRepoImpl) MarkFailed(ctx context.Context, id uint64, retryAt time.Time) error {
+	if _, err := r.Exec(ctx, markEventFailedQuery, id, retryAt); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+func (r *outboxRepoImpl) MarkDead(ctx context.Context, id uint64) error {
+	if _, err := r.Exec(ctx, markEventDeadQuery, id); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+// insertEvent writes the event to the outbox and its deliveries for subscribed webhooks
+// in the same transaction as the change of the car
+func insertEvent(ctx context.Context, tx pgx.Tx, event model.Event) error {
+	payload, err := encodeEventPayload(event)
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	if _, err = tx.Exec(ctx, insertEventQuery,
+		event.CarId,
+		event.Type,
+		payload,
+	); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	if _, err = tx.Exec(ctx, insertEventDeliveriesQuery,
+		event.Type,
+		payload,
+		event.Car.Mark,
+		event.Car.Model,
+	); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+// eventPayload is a struct for storing data of the event in the outbox
+type eventPayload struct {
+	Car           carPayload    `json:"car"`
+	PreviousOwner *ownerPayload `json:"previousOwner,omitempty"`
+}
+
+type carPayload struct {
+	Id     uint64       `json:"id"`
+	RegNum string       `json:"regNum"`
+	Mark   string       `json:"mark"`
+	Model  string       `json:"model"`
+	Year   int          `json:"year"`
+	Owner  ownerPayload `json:"owner"`
+}
+
+type ownerPayload struct {
+	Id         uint64 `json:"id"`
+	Name       string `json:"name"`
+	Surname    string `json:"surname"`
+	Patronymic string `json:"patronymic"`
+}
+
+func encodeEventPayload(event model.Event) ([]byte, error) {
+	payload := eventPayload{
+		Car: carPayload{
+			Id:     event.Car.Id,
+			RegNum: event.Car.RegNum,
+			Mark:   event.Car.Mark,
+			Model:  event.Car.Model,
+			Year:   event.Car.Year,
+			Owner:  ownerPayload(event.Car.Owner),
+		},
+	}
+	if event.PreviousOwner != nil {
+		prev := ownerPayload(*event.PreviousOwner)
+		payload.PreviousOwner = &prev
+	}
+	return json.Marshal(payload)
+}
+
+func decodeEventPayload(data []byte, event *model.Event) error {
+	var payload eventPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	event.Car = model.Car{
+		Id:     payload.Car.Id,
+		RegNum: payload.Car.RegNum,
+		Mark:   payload.Car.Mark,
+		Model:  payload.Car.Model,
+		Year:   payload.Car.Year,
+		Owner:  model.Owner(payload.Car.Owner),
+	}
+	if payload.PreviousOwner != nil {
+		prev := model.Owner(*payload.PreviousOwner)
+		event.PreviousOwner = &prev
+	}
+	return nil
+}
+
+const (
+	insertEventQuery = `
+		INSERT INTO "outbox" ("car_id", "event_type", "payload")
+		VALUES ($1, $2, $3);`
+
+	// insertEventDeliveriesQuery saves pending deliveries for active webhooks subscribed
+	// to the event type, which filters match the car
+	insertEventDeliveriesQuery = `
+		INSERT INTO "webhook_deliveries" ("webhook_id", "event_type", "payload", "status")
+		SELECT "id", CAST($1 AS VARCHAR), $2, 'pending'
+		FROM "webhooks"
+		WHERE "active" AND CAST($1 AS VARCHAR) = ANY("events")
+			AND ("mark" IS NULL OR "mark" = $3)
+			AND ("model" IS NULL OR "model" = $4);`
+
+	// claimEventsQuery skips events locked by relays of other instances, the oldest pending event
+	// of the car claimed by another relay keeps later events of the car from being claimed
+	claimEventsQuery = `
+		UPDATE "outbox"
+		SET "retry_at" = CAST($2 AS TIMESTAMPTZ)
+		WHERE "id" IN (
+			SELECT "id"
+			FROM "outbox" AS "o"
+			WHERE "delivered_at" IS NULL AND "dead_at" IS NULL AND "retry_at" <= NOW()
+				AND NOT EXISTS (
+					SELECT 1 FROM "outbox" AS "prev"
+					WHERE "prev"."car_id" = "o"."car_id" AND "prev"."id" < "o"."id"
+						AND "prev"."delivered_at" IS NULL AND "prev"."dead_at" IS NULL
+				)
+			ORDER BY "id"
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING "id", "car_id", "event_type", "payload", "created_at", "attempts";`
+
+	markEventDeliveredQuery = `
+		UPDATE "outbox"
+		SET "delivered_at" = NOW(),
+			"attempts" = "attempts" + 1
+		WHERE "id" = $1;`
+
+	markEventFailedQuery = `
+		UPDATE "outbox"
+		SET "attempts" = "attempts" + 1,
+			"retry_at" = CAST($2 AS TIMESTAMPTZ)
+		WHERE "id" = $1;`
+
+	markEventDeadQuery = `
+		UPDATE "outbox"
+		SET "attempts" = "attempts" + 1,
+			"dead_at" = NOW()
+		WHERE "id" = $1;`
+)
@@ -0,0 +1,226 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+func (r *repoImpl) GetOwnerById(ctx context.Context, id uint64) (model.Owner, error) {
+	owner := model.Owner{Id: id}
+	if err := r.QueryRow(ctx, getOwnerByIdQuery, id).Scan(
+		&owner.Name,
+		&owner.Surname,
+		&owner.Patronymic,
+	); errors.Is(err, pgx.ErrNoRows) {
+		return model.Owner{}, model.ErrOwnerNotFound
+	} else if err != nil {
+		return model.Owner{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	return owner, nil
+}
+
+func (r *repoImpl) GetCarsByOwnerIds(ctx context.Context, ownerIds []uint64) (map[uint64][]model.Car, error) {
+	rows, err := r.Query(ctx, getCarsByOwnerIdsQuery, ownerIds)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	cars := make(map[uint64][]model.Car, len(ownerIds))
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		cars[car.Owner.Id] = append(cars[car.Owner.Id], car)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	return cars, nil
+}
+
+func (r *repoImpl) GetOwnershipHistory(ctx context.Context, carIds []uint64) (map[uint64][]model.Ownership, error) {
+	rows, err := r.Query(ctx, getOwnershipHistoryQuery, carIds)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	history := make(map[uint64][]model.Ownership, len(carIds))
+	for rows.Next() {
+		var o model.Ownership
+		var till *time.Time
+		if err = rows.Scan(
+			&o.CarId,
+			&o.Owner.Id,
+			&o.Owner.Name,
+			&o.Owner.Surname,
+			&o.Owner.Patronymic,
+			&o.From,
+			&till,
+		); err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		if till != nil {
+			o.Till = *till
+		}
+		history[o.CarId] = append(history[o.CarId], o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	return history, nil
+}
+
+func (r *repoImpl) MergeOwners(ctx context.Context, into uint64, from []uint64) ([]model.Car, error) {
+	var cars []model.Car
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, ownerExistsQuery, into).Scan(&exists); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		} else if !exists {
+			return model.ErrOwnerNotFound
+		}
+
+		// cars are locked before the merge, so their previous owners are the owners replaced by it
+		rows, err := tx.Query(ctx, lockOwnerCarsQuery, from)
+		if err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		locked, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Car, error) {
+			return scanCar(row)
+		})
+		if err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		prevOwners := make(map[uint64]model.Owner, len(locked))
+		for _, car := range locked {
+			prevOwners[car.Id] = car.Owner
+		}
+
+		if e, err := tx.Exec(ctx, deleteOwnersQuery, from); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		} else if e.RowsAffected() != int64(len(from)) {
+			return model.ErrOwnerNotFound
+		}
+		if _, err := tx.Exec(ctx, mergeOwnershipsQuery, into, from); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+
+		if rows, err = tx.Query(ctx, mergeOwnerCarsQuery, into, from); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[uint64])
+		if err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+
+		if rows, err = tx.Query(ctx, getCarsByIdsQuery, ids); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		if cars, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Car, error) {
+			return scanCar(row)
+		}); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+
+		for _, car := range cars {
+			if err = insertEvent(ctx, tx, model.Event{
+				Type:  model.EventCarUpdated,
+				CarId: car.Id,
+				Car:   car,
+			}); err != nil {
+				return err
+			}
+			prevOwner := prevOwners[car.Id]
+			if err = insertEvent(ctx, tx, model.Event{
+				Type:          model.EventOwnerChanged,
+				CarId:         car.Id,
+				Car:           car,
+				PreviousOwner: &prevOwner,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cars, nil
+}
+
+// changeOwnership finishes the current ownership of the car and starts the ownership of the new owner
+func changeOwnership(ctx context.Context, tx pgx.Tx, carId uint64, ownerId uint64) error {
+	if _, err := tx.Exec(ctx, finishOwnershipQuery, carId); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	if _, err := tx.Exec(ctx, insertOwnershipQuery, carId, ownerId); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+const (
+	getOwnerByIdQuery = `
+		SELECT "name", "surname", "patronymic"
+		FROM "owners"
+		WHERE "id" = $1;`
+
+	getCarsByOwnerIdsQuery = carColumnsQuery + `
+		WHERE "owners"."id" = ANY(CAST($1 AS BIGINT[]))
+		ORDER BY "cars"."id";`
+
+	getOwnershipHistoryQuery = `
+		SELECT "ownerships"."car_id", "owners"."id", "owners"."name", "owners"."surname", "owners"."patronymic",
+			"ownerships"."started_at", "ownerships"."ended_at"
+		FROM "ownerships"
+			INNER JOIN "owners" ON "ownerships"."owner_id" = "owners"."id"
+		WHERE "ownerships"."car_id" = ANY(CAST($1 AS BIGINT[]))
+		ORDER BY "ownerships"."car_id", "ownerships"."id";`
+
+	insertOwnershipQuery = `
+		INSERT INTO "ownerships" ("car_id", "owner_id")
+		VALUES ($1, $2);`
+
+	finishOwnershipQuery = `
+		UPDATE "ownerships"
+		SET "ended_at" = NOW()
+		WHERE "car_id" = $1 AND "ended_at" IS NULL;`
+
+	ownerExistsQuery = `
+		SELECT EXISTS (SELECT 1 FROM "owners" WHERE "id" = $1);`
+
+	deleteOwnersQuery = `
+		DELETE FROM "owners"
+		WHERE "id" = ANY(CAST($1 AS BIGINT[]));`
+
+	mergeOwnershipsQuery = `
+		UPDATE "ownerships"
+		SET "owner_id" = $1
+		WHERE "owner_id" = ANY(CAST($2 AS BIGINT[]));`
+
+	lockOwnerCarsQuery = carColumnsQuery + `
+		WHERE "owners"."id" = ANY(CAST($1 AS BIGINT[]))
+		ORDER BY "cars"."id"
+		FOR UPDATE OF "cars";`
+
+	mergeOwnerCarsQuery = `
+		UPDATE "cars"
+		SET "owner_id" = $1,
+			"version" = "version" + 1
+		WHERE "owner_id" = ANY(CAST($2 AS BIGINT[]))
+		RETURNING "id";`
+
+	getCarsByIdsQuery = carColumnsQuery + `
+		WHERE "cars"."id" = ANY(CAST($1 AS BIGINT[]))
+		ORDER BY "cars"."id";`
+
+	deleteOwnershipsQuery = `
+		DELETE FROM "ownerships"
+		WHERE "car_id" = $1;`
+)
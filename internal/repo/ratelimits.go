@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimitCleanupInterval is the minimal interval between deletions of counters of ended windows
+const rateLimitCleanupInterval = time.Minute
+
+type rateLimitRepoImpl struct {
+	*pgxpool.Pool
+	// cleanedAt is the unix time of the last deletion of expired counters
+	cleanedAt atomic.Int64
+}
+
+func (r *rateLimitRepoImpl) Hit(ctx context.Context, key string, start time.Time, period time.Duration) (int, error) {
+	var count int
+	if err := r.QueryRow(ctx, hitRateLimitQuery, key, start, start.Add(period)).Scan(&count); err != nil {
+		return 0, errors.Join(model.ErrDatabaseError, err)
+	}
+
+	// only one of concurrent requests deletes expired counters, its failure is retried by the next one
+	now := time.Now().Unix()
+	last := r.cleanedAt.Load()
+	if now-last >= int64(rateLimitCleanupInterval/time.Second) && r.cleanedAt.CompareAndSwap(last, now) {
+		_, _ = r.Exec(ctx, deleteExpiredRateLimitsQuery)
+	}
+	return count, nil
+}
+
+const (
+	// hitRateLimitQuery starts the counter again in the new window, requests of instances which
+	// are late with the new window are counted in it
+	hitRateLimitQuery = `
+		INSERT INTO "rate_limits" ("key", "window_start", "count", "expires_at")
+		VALUES ($1, CAST($2 AS TIMESTAMPTZ), 1, CAST($3 AS TIMESTAMPTZ))
+		ON CONFLICT ("key") DO UPDATE
+		SET "count" = CASE
+				WHEN EXCLUDED."window_start" > "rate_limits"."window_start" THEN 1
+				ELSE "rate_limits"."count" + 1
+			END,
+			"window_start" = GREATEST("rate_limits"."window_start", EXCLUDED."window_start"),
+			"expires_at" = GREATEST("rate_limits"."expires_at", EXCLUDED."expires_at")
+		RETURNING "count";`
+
+	deleteExpiredRateLimitsQuery = `
+		DELETE FROM "rate_limits"
+		WHERE "expires_at" < NOW();`
+)
@@ -0,0 +1,286 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type refreshRepoImpl struct {
+	*pgxpool.Pool
+}
+
+func (r *refreshRepoImpl) GetStaleCars(ctx context.Context, syncedBefore time.Time, limit uint) ([]model.Car, error) {
+	rows, err := r.Query(ctx, getStaleCarsQuery, syncedBefore, limit)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	var cars []model.Car
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		cars = append(cars, car)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	return cars, nil
+}
+
+func (r *refreshRepoImpl) SetCarSynced(ctx context.Context, carId uint64, at time.Time) error {
+	if _, err := r.Exec(ctx, setCarSyncedQuery, carId, at); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+func (r *refreshRepoImpl) AddRefresh(ctx context.Context, refresh model.Refresh, save func(ctx context.Context) (model.Car, error)) (model.Refresh, error) {
+	tx, err := r.Begin(ctx)
+	if err != nil {
+		return model.Refresh{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if save != nil {
+		if refresh.Car, err = save(withTx(ctx, tx)); err != nil {
+			return model.Refresh{}, err
+		}
+	}
+	payload, err := encodeRefreshPayload(refresh)
+	if err != nil {
+		return model.Refresh{}, errors.Join(model.ErrDatabaseError, err)
+	}
+
+	if refresh.Status == model.RefreshPending {
+		if _, err = tx.Exec(ctx, supersedeRefreshesQuery, refresh.CarId); err != nil {
+			return model.Refresh{}, errors.Join(model.ErrDatabaseError, err)
+		}
+	}
+	var resolvedAt *time.Time
+	if err = tx.QueryRow(ctx, insertRefreshQuery,
+		refresh.CarId,
+		refresh.Status,
+		payload,
+	).Scan(&refresh.Id, &refresh.CreatedAt, &resolvedAt); err != nil {
+		return model.Refresh{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	if resolvedAt != nil {
+		refresh.ResolvedAt = *resolvedAt
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return model.Refresh{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	return refresh, nil
+}
+
+func (r *refreshRepoImpl) GetRefresh(ctx context.Context, id uint64) (model.Refresh, error) {
+	refresh, err := scanRefresh(r.QueryRow(ctx, getRefreshQuery, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Refresh{}, model.ErrRefreshNotFound
+	} else if err != nil {
+		return model.Refresh{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	return refresh, nil
+}
+
+func (r *refreshRepoImpl) GetRefreshes(ctx context.Context, status model.RefreshStatus, limit uint) ([]model.Refresh, error) {
+	rows, err := r.Query(ctx, getRefreshesQuery, status, limit)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	var refreshes []model.Refresh
+	for rows.Next() {
+		refresh, err := scanRefresh(rows)
+		if err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		refreshes = append(refreshes, refresh)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	return refreshes, nil
+}
+
+func (r *refreshRepoImpl) ResolveRefresh(ctx context.Context, id uint64, status model.RefreshStatus) error {
+	e, err := r.Exec(ctx, resolveRefreshQuery, id, status)
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	} else if e.RowsAffected() != 0 {
+		return nil
+	}
+
+	// nothing was updated, so the refresh either does not exist or is already resolved
+	if _, err = r.GetRefresh(ctx, id); err != nil {
+		return err
+	}
+	return model.ErrRefreshResolved
+}
+
+func (r *refreshRepoImpl) ApplyRefresh(ctx context.Context, id uint64, save func(ctx context.Context) error) error {
+	tx, err := r.Begin(ctx)
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// the row of the refresh stays locked, so concurrent requests do not apply it twice
+	e, err := tx.Exec(ctx, resolveRefreshQuery, id, model.RefreshApplied)
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	} else if e.RowsAffected() == 0 {
+		if _, err = r.GetRefresh(ctx, id); err != nil {
+			return err
+		}
+		return model.ErrRefreshResolved
+	}
+	if err = save(withTx(ctx, tx)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+// scanRefresh scans a row of any query selecting refreshColumns
+func scanRefresh(row pgx.Row) (model.Refresh, error) {
+	var refresh model.Refresh
+	var payload []byte
+	var resolvedAt *time.Time
+	if err := row.Scan(
+		&refresh.Id,
+		&refresh.CarId,
+		&refresh.Status,
+		&payload,
+		&refresh.CreatedAt,
+		&resolvedAt,
+	); err != nil {
+		return model.Refresh{}, err
+	}
+	if err := decodeRefreshPayload(payload, &refresh); err != nil {
+		return model.Refresh{}, err
+	}
+	if resolvedAt != nil {
+		refresh.ResolvedAt = *resolvedAt
+	}
+	return refresh, nil
+}
+
+// refreshPayload is a struct for storing the proposed car and its changes
+type refreshPayload struct {
+	Car     carPayload        `json:"car"`
+	Sources map[string]string `json:"sources,omitempty"`
+	Changes []changePayload   `json:"changes"`
+}
+
+type changePayload struct {
+	Field  string `json:"field"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+	Source string `json:"source"`
+}
+
+func encodeRefreshPayload(refresh model.Refresh) ([]byte, error) {
+	payload := refreshPayload{
+		Car: carPayload{
+			Id:     refresh.Car.Id,
+			RegNum: refresh.Car.RegNum,
+			Mark:   refresh.Car.Mark,
+			Model:  refresh.Car.Model,
+			Year:   refresh.Car.Year,
+			Owner:  ownerPayload(refresh.Car.Owner),
+		},
+		Sources: refresh.Car.Sources,
+		Changes: make([]changePayload, len(refresh.Changes)),
+	}
+	for i, change := range refresh.Changes {
+		payload.Changes[i] = changePayload(change)
+	}
+	return json.Marshal(payload)
+}
+
+func decodeRefreshPayload(data []byte, refresh *model.Refresh) error {
+	var payload refreshPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	refresh.Car = model.Car{
+		Id:      payload.Car.Id,
+		RegNum:  payload.Car.RegNum,
+		Mark:    payload.Car.Mark,
+		Model:   payload.Car.Model,
+		Year:    payload.Car.Year,
+		Owner:   model.Owner(payload.Car.Owner),
+		Sources: payload.Sources,
+	}
+	refresh.Changes = make([]model.FieldChange, len(payload.Changes))
+	for i, change := range payload.Changes {
+		refresh.Changes[i] = model.FieldChange(change)
+	}
+	return nil
+}
+
+const (
+	refreshColumns = `"id", "car_id", "status", "payload", "created_at", "resolved_at"`
+
+	getStaleCarsQuery = carColumnsQuery + `
+		WHERE "cars"."synced_at" < CAST($1 AS TIMESTAMPTZ)
+		ORDER BY "cars"."synced_at", "cars"."id"
+		LIMIT $2;`
+
+	setCarSyncedQuery = `
+		UPDATE "cars"
+		SET "synced_at" = CAST($2 AS TIMESTAMPTZ)
+		WHERE "id" = $1;`
+
+	supersedeRefreshesQuery = `
+		UPDATE "car_refreshes"
+		SET "status" = 'superseded',
+			"resolved_at" = NOW()
+		WHERE "car_id" = $1 AND "status" = 'pending';`
+
+	// insertRefreshQuery saves refreshes which are not pending as already resolved
+	insertRefreshQuery = `
+		INSERT INTO "car_refreshes" ("car_id", "status", "payload", "resolved_at")
+		VALUES ($1, CAST($2 AS VARCHAR), $3, CASE WHEN CAST($2 AS VARCHAR) = 'pending' THEN NULL ELSE NOW() END)
+		RETURNING "id", "created_at", "resolved_at";`
+
+	getRefreshQuery = `
+		SELECT ` + refreshColumns + `
+		FROM "car_refreshes"
+		WHERE "id" = $1;`
+
+	getRefreshesQuery = `
+		SELECT ` + refreshColumns + `
+		FROM "car_refreshes"
+		WHERE "status" = $1
+		ORDER BY "id" DESC
+		LIMIT $2;`
+
+	deleteCarRefreshesQuery = `
+		DELETE FROM "car_refreshes"
+		WHERE "car_id" = $1;`
+
+	resolveRefreshQuery = `
+		UPDATE "car_refreshes"
+		SET "status" = $2,
+			"resolved_at" = NOW()
+		WHERE "id" = $1 AND "status" = 'pending';`
+)
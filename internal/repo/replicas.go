@@ -0,0 +1,215 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"cars-service/pkg/logger"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type sessionKey struct{}
+
+// session remembers writes made with ctx of a request
+type session struct {
+	wrote atomic.Bool
+}
+
+// WithSession returns ctx in which reads go to the primary after the first write, so a request
+// sees its own changes even if replicas lag behind
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// WithPrimary returns ctx in which all reads go to the primary, it is used when the read data
+// must not be stale
+func WithPrimary(ctx context.Context) context.Context {
+	s := &session{}
+	s.wrote.Store(true)
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+type replica struct {
+	Repo
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// name identifies the replica in logs without the password of its DSN
+func (r *replica) name() string {
+	cfg := r.pool.Config().ConnConfig
+	return cfg.Host + "/" + cfg.Database
+}
+
+type replicatedRepoImpl struct {
+	// Repo of the primary, methods which are not overridden are sent to it
+	Repo
+	replicas []*replica
+	next     atomic.Uint64
+	logs     logger.Logger
+	interval time.Duration
+}
+
+func (r *replicatedRepoImpl) GetCarById(ctx context.Context, id uint64) (model.Car, error) {
+	return read(ctx, r, func(rp Repo) (model.Car, error) {
+		return rp.GetCarById(ctx, id)
+	})
+}
+
+func (r *replicatedRepoImpl) GetCars(ctx context.Context, filter model.Filter) ([]model.Car, error) {
+	return read(ctx, r, func(rp Repo) ([]model.Car, error) {
+		return rp.GetCars(ctx, filter)
+	})
+}
+
+func (r *replicatedRepoImpl) IterateCars(ctx context.Context, filter model.Filter, fn func(model.Car) error) error {
+	if rp := r.reader(ctx); rp != nil {
+		// the read can be repeated on the primary only until the first car is passed to fn
+		called := false
+		err := rp.IterateCars(ctx, filter, func(car model.Car) error {
+			called = true
+			return fn(car)
+		})
+		if called || !r.failed(ctx, rp, err) {
+			return err
+		}
+	}
+	return r.Repo.IterateCars(ctx, filter, fn)
+}
+
+func (r *replicatedRepoImpl) GetOwnerById(ctx context.Context, id uint64) (model.Owner, error) {
+	return read(ctx, r, func(rp Repo) (model.Owner, error) {
+		return rp.GetOwnerById(ctx, id)
+	})
+}
+
+func (r *replicatedRepoImpl) GetCarsByOwnerIds(ctx context.Context, ownerIds []uint64) (map[uint64][]model.Car, error) {
+	return read(ctx, r, func(rp Repo) (map[uint64][]model.Car, error) {
+		return rp.GetCarsByOwnerIds(ctx, ownerIds)
+	})
+}
+
+func (r *replicatedRepoImpl) GetOwnershipHistory(ctx context.Context, carIds []uint64) (map[uint64][]model.Ownership, error) {
+	return read(ctx, r, func(rp Repo) (map[uint64][]model.Ownership, error) {
+		return rp.GetOwnershipHistory(ctx, carIds)
+	})
+}
+
+func (r *replicatedRepoImpl) AddCar(ctx context.Context, car model.Car) (model.Car, error) {
+	markWrite(ctx)
+	return r.Repo.AddCar(ctx, car)
+}
+
+func (r *replicatedRepoImpl) UpdateCar(ctx context.Context, id uint64, car model.Car) (model.Car, error) {
+	markWrite(ctx)
+	return r.Repo.UpdateCar(ctx, id, car)
+}
+
+func (r *replicatedRepoImpl) DeleteCar(ctx context.Context, id uint64) error {
+	markWrite(ctx)
+	return r.Repo.DeleteCar(ctx, id)
+}
+
+func (r *replicatedRepoImpl) MergeOwners(ctx context.Context, into uint64, from []uint64) ([]model.Car, error) {
+	markWrite(ctx)
+	return r.Repo.MergeOwners(ctx, into, from)
+}
+
+func (r *replicatedRepoImpl) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.checkReplicas(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkReplicas pings all replicas concurrently, so one hanging replica does not delay the others
+func (r *replicatedRepoImpl) checkReplicas(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, r.interval)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, rp := range r.replicas {
+		wg.Add(1)
+		go func(rp *replica) {
+			defer wg.Done()
+			err := rp.pool.Ping(ctx)
+			// the service is stopping, it is not a failure of the replica
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+			r.setHealth(rp, err)
+		}(rp)
+	}
+	wg.Wait()
+}
+
+// setHealth logs only changes of the health, so an unavailable replica does not flood logs
+func (r *replicatedRepoImpl) setHealth(rp *replica, err error) {
+	healthy := err == nil
+	if rp.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		r.logs.Info(logger.Fields{"replica": rp.name()}, "replica is available")
+	} else {
+		r.logs.Error(logger.Fields{"replica": rp.name(), "error": err.Error()}, "replica is unavailable")
+	}
+}
+
+// reader returns healthy replica for the next read in turn or nil if the read must go to the primary
+func (r *replicatedRepoImpl) reader(ctx context.Context) *replica {
+	if primaryRequired(ctx) {
+		return nil
+	}
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if rp := r.replicas[(start+i)%n]; rp.healthy.Load() {
+			return rp
+		}
+	}
+	return nil
+}
+
+// read calls fn with the replica and repeats it with the primary if the replica fails
+func read[T any](ctx context.Context, r *replicatedRepoImpl, fn func(Repo) (T, error)) (T, error) {
+	if rp := r.reader(ctx); rp != nil {
+		res, err := fn(rp)
+		if !r.failed(ctx, rp, err) {
+			return res, err
+		}
+	}
+	return fn(r.Repo)
+}
+
+// failed marks the replica unhealthy until the next check if err is caused by the database,
+// errors like model.ErrCarNotFound are the same on the primary
+func (r *replicatedRepoImpl) failed(ctx context.Context, rp *replica, err error) bool {
+	if !errors.Is(err, model.ErrDatabaseError) || ctx.Err() != nil {
+		return false
+	}
+	r.setHealth(rp, err)
+	return true
+}
+
+// primaryRequired reports whether reads with ctx must see all writes, it is true for ctx made by
+// WithPrimary or by WithSession after a write
+func primaryRequired(ctx context.Context) bool {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	return ok && s.wrote.Load()
+}
+
+func markWrite(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.wrote.Store(true)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"maps"
 )
 
 type repoImpl struct {
@@ -14,28 +15,21 @@ type repoImpl struct {
 }
 
 func (r *repoImpl) GetCarById(ctx context.Context, id uint64) (model.Car, error) {
-	row := r.QueryRow(ctx, getCarByIdQuery, id)
-	var car model.Car
-	if err := row.Scan(
-		&car.Id,
-		&car.RegNum,
-		&car.Mark,
-		&car.Model,
-		&car.Year,
-		&car.Owner.Name,
-		&car.Owner.Surname,
-		&car.Owner.Patronymic,
-	); errors.Is(err, pgx.ErrNoRows) {
+	car, err := scanCar(r.QueryRow(ctx, getCarByIdQuery, id))
+	if errors.Is(err, pgx.ErrNoRows) {
 		return model.Car{}, model.ErrCarNotFound
 	} else if err != nil {
 		return model.Car{}, errors.Join(model.ErrDatabaseError, err)
-	} else {
-		return car, nil
 	}
+	return car, nil
 }
 
 func (r *repoImpl) GetCars(ctx context.Context, filter model.Filter) ([]model.Car, error) {
-	rows, err := r.Query(ctx, getCarsQuery,
+	orderBy, err := carsOrderBy(filter)
+	if err != nil {
+		return []model.Car{}, err
+	}
+	rows, err := r.Query(ctx, filteredCarsQuery+orderBy+getCarsPagination,
 		filter.RegNum,
 		filter.ByRegNum,
 		filter.Mark,
@@ -58,108 +52,349 @@ func (r *repoImpl) GetCars(ctx context.Context, filter model.Filter) ([]model.Ca
 	}
 	defer rows.Close()
 
-	cars := make([]model.Car, 0, filter.Limit)
+	// huge limit must not allocate huge memory before any row is read
+	cars := make([]model.Car, 0, min(filter.Limit, maxPreallocatedCars))
 	for rows.Next() {
-		var car model.Car
-		_ = rows.Scan(
-			&car.Id,
-			&car.RegNum,
-			&car.Mark,
-			&car.Model,
-			&car.Year,
-			&car.Owner.Name,
-			&car.Owner.Surname,
-			&car.Owner.Patronymic,
-		)
+		car, err := scanCar(rows)
+		if err != nil {
+			return []model.Car{}, errors.Join(model.ErrDatabaseError, err)
+		}
 		cars = append(cars, car)
 	}
+	if err = rows.Err(); err != nil {
+		return []model.Car{}, errors.Join(model.ErrDatabaseError, err)
+	}
 	return cars, nil
 }
 
-func (r *repoImpl) AddCar(ctx context.Context, car model.Car) (model.Car, error) {
-	ownerId, err := r.getOwnerId(ctx, car.Owner)
+func (r *repoImpl) IterateCars(ctx context.Context, filter model.Filter, fn func(model.Car) error) error {
+	orderBy, err := carsOrderBy(filter)
 	if err != nil {
-		return model.Car{}, err
+		return err
 	}
-	markId, err := r.getMarkId(ctx, car.Mark)
+	rows, err := r.Query(ctx, filteredCarsQuery+orderBy+iterateCarsPagination,
+		filter.RegNum,
+		filter.ByRegNum,
+		filter.Mark,
+		filter.ByMark,
+		filter.Model,
+		filter.ByModel,
+		filter.Year,
+		filter.ByYear,
+		filter.OwnerName,
+		filter.ByOwnerName,
+		filter.OwnerSurname,
+		filter.ByOwnerSurname,
+		filter.OwnerPatronymic,
+		filter.ByOwnerPatronymic,
+		filter.Limit,
+		filter.Offset,
+	)
 	if err != nil {
-		return model.Car{}, err
+		return errors.Join(model.ErrDatabaseError, err)
 	}
-	modelId, err := r.getModelId(ctx, car.Model, markId)
-	if err != nil {
-		return model.Car{}, err
+	defer rows.Close()
+
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		if err = fn(car); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
 	}
+	return nil
+}
 
-	var pgErr *pgconn.PgError
-	if err = r.QueryRow(ctx, insertCarQuery,
-		car.RegNum,
-		modelId,
-		car.Year,
-		ownerId,
-	).Scan(&car.Id); errors.As(err, &pgErr) {
-		switch pgErr.Code {
-		case "23505":
-			return model.Car{}, model.ErrDuplicateRegNum
-		default:
-			return model.Car{}, errors.Join(model.ErrDatabaseError, err)
+func (r *repoImpl) AddCar(ctx context.Context, car model.Car) (model.Car, error) {
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		ownerId, modelId, err := getRelatedIds(ctx, tx, car)
+		if err != nil {
+			return err
 		}
-	} else if err != nil {
-		return model.Car{}, errors.Join(model.ErrDatabaseError, err)
+		car.Owner.Id = ownerId
+
+		var pgErr *pgconn.PgError
+		if err = tx.QueryRow(ctx, insertCarQuery,
+			car.RegNum,
+			modelId,
+			car.Year,
+			ownerId,
+			sourcesValue(car.Sources),
+		).Scan(&car.Id, &car.Version); errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return model.ErrDuplicateRegNum
+			default:
+				return errors.Join(model.ErrDatabaseError, err)
+			}
+		} else if err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+
+		if _, err = tx.Exec(ctx, insertOwnershipQuery, car.Id, ownerId); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		if err = setImportItemDone(ctx, tx, car.Id); err != nil {
+			return err
+		}
+
+		return insertEvent(ctx, tx, model.Event{
+			Type:  model.EventCarCreated,
+			CarId: car.Id,
+			Car:   car,
+		})
+	})
+	if err != nil {
+		return model.Car{}, err
 	}
 	return car, nil
 }
 
 func (r *repoImpl) UpdateCar(ctx context.Context, id uint64, car model.Car) (model.Car, error) {
-	ownerId, err := r.getOwnerId(ctx, car.Owner)
+	car.Id = id
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		// the row is locked until the end of transaction, so events are written in the order of updates
+		prev, err := lockCar(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if car.Version != 0 && car.Version != prev.Version {
+			return model.ErrCarChanged
+		}
+		if car.Sources == nil {
+			car.Sources = updatedSources(prev, car)
+		}
+
+		ownerId, modelId, err := getRelatedIds(ctx, tx, car)
+		if err != nil {
+			return err
+		}
+		car.Owner.Id = ownerId
+
+		var pgErr *pgconn.PgError
+		if err = tx.QueryRow(ctx, updateCarQuery,
+			id,
+			car.RegNum,
+			modelId,
+			car.Year,
+			ownerId,
+			sourcesValue(car.Sources),
+		).Scan(&car.Version); errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return model.ErrDuplicateRegNum
+			default:
+				return errors.Join(model.ErrDatabaseError, err)
+			}
+		} else if err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+
+		if err = insertEvent(ctx, tx, model.Event{
+			Type:  model.EventCarUpdated,
+			CarId: id,
+			Car:   car,
+		}); err != nil {
+			return err
+		}
+		if prev.Owner.Id != ownerId {
+			if err = changeOwnership(ctx, tx, id, ownerId); err != nil {
+				return err
+			}
+			prevOwner := prev.Owner
+			return insertEvent(ctx, tx, model.Event{
+				Type:          model.EventOwnerChanged,
+				CarId:         id,
+				Car:           car,
+				PreviousOwner: &prevOwner,
+			})
+		}
+		return nil
+	})
 	if err != nil {
 		return model.Car{}, err
 	}
-	markId, err := r.getMarkId(ctx, car.Mark)
-	if err != nil {
-		return model.Car{}, err
+	return car, nil
+}
+
+func (r *repoImpl) DeleteCar(ctx context.Context, id uint64) error {
+	return r.inTx(ctx, func(tx pgx.Tx) error {
+		car, err := lockCar(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, deleteCarQuery, id); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		if _, err = tx.Exec(ctx, deleteOwnershipsQuery, id); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		if _, err = tx.Exec(ctx, deleteCarRefreshesQuery, id); err != nil {
+			return errors.Join(model.ErrDatabaseError, err)
+		}
+		return insertEvent(ctx, tx, model.Event{
+			Type:  model.EventCarDeleted,
+			CarId: id,
+			Car:   car,
+		})
+	})
+}
+
+type txKey struct{}
+
+// withTx returns ctx in which inTx runs functions in tx, so changes of several repos are committed
+// by the owner of tx together
+func withTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// inTx runs fn in transaction which is committed if fn returns nil, fn joins the transaction of ctx
+// if there is one
+func (r *repoImpl) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(tx)
 	}
-	modelId, err := r.getModelId(ctx, car.Model, markId)
+
+	tx, err := r.Begin(ctx)
 	if err != nil {
-		return model.Car{}, err
+		return errors.Join(model.ErrDatabaseError, err)
 	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
 
-	var pgErr *pgconn.PgError
-	if e, err := r.Exec(ctx, updateCarQuery,
-		id,
-		car.RegNum,
-		modelId,
-		car.Year,
-		ownerId,
-	); errors.As(err, &pgErr) {
-		switch pgErr.Code {
-		case "23505":
-			return model.Car{}, model.ErrDuplicateRegNum
-		default:
-			return model.Car{}, errors.Join(model.ErrDatabaseError, err)
-		}
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+// querier is implemented by both pgxpool.Pool and pgx.Tx
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// lockCar returns current data of the car and locks its row until the end of transaction
+func lockCar(ctx context.Context, tx pgx.Tx, id uint64) (model.Car, error) {
+	car, err := scanCar(tx.QueryRow(ctx, lockCarQuery, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Car{}, model.ErrCarNotFound
 	} else if err != nil {
 		return model.Car{}, errors.Join(model.ErrDatabaseError, err)
-	} else if e.RowsAffected() == 0 {
-		return model.Car{}, model.ErrCarNotFound
-	} else {
-		car.Id = id
-		return car, nil
 	}
+	return car, nil
 }
 
-func (r *repoImpl) DeleteCar(ctx context.Context, id uint64) error {
-	if e, err := r.Exec(ctx, deleteCarQuery, id); err != nil {
-		return errors.Join(model.ErrDatabaseError, err)
-	} else if e.RowsAffected() == 0 {
-		return model.ErrCarNotFound
+// scanCar scans a row of any query selecting carColumns
+func scanCar(row pgx.Row) (model.Car, error) {
+	var car model.Car
+	if err := row.Scan(
+		&car.Id,
+		&car.RegNum,
+		&car.Mark,
+		&car.Model,
+		&car.Year,
+		&car.Owner.Id,
+		&car.Owner.Name,
+		&car.Owner.Surname,
+		&car.Owner.Patronymic,
+		&car.Sources,
+		&car.Version,
+	); err != nil {
+		return model.Car{}, err
 	}
-	return nil
+	// cars without sources are the same as in the memory repo
+	if len(car.Sources) == 0 {
+		car.Sources = nil
+	}
+	return car, nil
+}
+
+// sourcesValue returns sources for the NOT NULL column, nil map would be encoded as JSON null
+func sourcesValue(sources map[string]string) map[string]string {
+	if sources == nil {
+		return map[string]string{}
+	}
+	return sources
+}
+
+// updatedSources returns sources of prev with fields changed in car attributed to manual input
+func updatedSources(prev model.Car, car model.Car) map[string]string {
+	sources := maps.Clone(prev.Sources)
+	if sources == nil {
+		sources = make(map[string]string)
+	}
+	if prev.Mark != car.Mark {
+		sources[model.FieldMark] = model.SourceManual
+	}
+	if prev.Model != car.Model {
+		sources[model.FieldModel] = model.SourceManual
+	}
+	if prev.Year != car.Year {
+		sources[model.FieldYear] = model.SourceManual
+	}
+	if prev.Owner.Name != car.Owner.Name ||
+		prev.Owner.Surname != car.Owner.Surname ||
+		prev.Owner.Patronymic != car.Owner.Patronymic {
+		sources[model.FieldOwner] = model.SourceManual
+	}
+	return sources
+}
+
+// sortColumns are columns of filteredCarsQuery for every sort field of the filter
+var sortColumns = map[model.CarSortField]string{
+	"":                 `"cars"."id"`,
+	model.SortById:     `"cars"."id"`,
+	model.SortByRegNum: `"reg_num"`,
+	model.SortByMark:   `"marks"."name"`,
+	model.SortByModel:  `"models"."name"`,
+	model.SortByYear:   `"year"`,
+}
+
+// carsOrderBy returns ORDER BY clause for the sort of the filter, cars with equal values
+// are ordered by id so pages do not overlap
+func carsOrderBy(filter model.Filter) (string, error) {
+	column, ok := sortColumns[filter.SortBy]
+	if !ok {
+		return "", model.ErrInvalidInput
+	}
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
+	}
+	return `
+		ORDER BY ` + column + ` ` + direction + `, "cars"."id" ` + direction, nil
+}
+
+// getRelatedIds returns ids of owner and model of the car inserting them and the mark if needed
+func getRelatedIds(ctx context.Context, q querier, car model.Car) (ownerId uint64, modelId uint64, err error) {
+	if ownerId, err = getOwnerId(ctx, q, car.Owner); err != nil {
+		return 0, 0, err
+	}
+	markId, err := getMarkId(ctx, q, car.Mark)
+	if err != nil {
+		return 0, 0, err
+	}
+	if modelId, err = getModelId(ctx, q, car.Model, markId); err != nil {
+		return 0, 0, err
+	}
+	return ownerId, modelId, nil
 }
 
 // getOwnerId returns id of existing owner in database or inserts new owner and returns its id
-func (r *repoImpl) getOwnerId(ctx context.Context, owner model.Owner) (uint64, error) {
+func getOwnerId(ctx context.Context, q querier, owner model.Owner) (uint64, error) {
 	var id uint64
-	if err := r.QueryRow(ctx, getOwnerIdQuery,
+	if err := q.QueryRow(ctx, getOwnerIdQuery,
 		owner.Name,
 		owner.Surname,
 		owner.Patronymic,
@@ -170,9 +405,9 @@ func (r *repoImpl) getOwnerId(ctx context.Context, owner model.Owner) (uint64, e
 }
 
 // getMarkId returns id of existing mark in database or inserts new mark and returns its id
-func (r *repoImpl) getMarkId(ctx context.Context, mark string) (uint64, error) {
+func getMarkId(ctx context.Context, q querier, mark string) (uint64, error) {
 	var id uint64
-	if err := r.QueryRow(ctx, getMarkIdQuery,
+	if err := q.QueryRow(ctx, getMarkIdQuery,
 		mark,
 	).Scan(&id); err != nil {
 		return 0, errors.Join(model.ErrDatabaseError, err)
@@ -181,9 +416,9 @@ func (r *repoImpl) getMarkId(ctx context.Context, mark string) (uint64, error) {
 }
 
 // getModelId returns id of existing model in database or inserts new model and returns its id
-func (r *repoImpl) getModelId(ctx context.Context, mdl string, markId uint64) (uint64, error) {
+func getModelId(ctx context.Context, q querier, mdl string, markId uint64) (uint64, error) {
 	var id uint64
-	if err := r.QueryRow(ctx, getModelIdQuery,
+	if err := q.QueryRow(ctx, getModelIdQuery,
 		mdl,
 		markId,
 	).Scan(&id); err != nil {
@@ -192,42 +427,55 @@ func (r *repoImpl) getModelId(ctx context.Context, mdl string, markId uint64) (u
 	return id, nil
 }
 
+// maxPreallocatedCars is the maximum capacity of the slice allocated by GetCars before reading rows
+const maxPreallocatedCars = 1000
+
 const (
-	getCarByIdQuery = `
-		SELECT "cars"."id", "reg_num", "marks"."name", "models"."name", "year", "owners"."name", "owners"."surname", "owners"."patronymic"
+	carColumnsQuery = `
+		SELECT "cars"."id", "reg_num", "marks"."name", "models"."name", "year", "owners"."id", "owners"."name", "owners"."surname", "owners"."patronymic", "cars"."sources", "cars"."version"
 		FROM "cars"
 			INNER JOIN "models" ON "cars"."model_id" = "models"."id"
 			INNER JOIN "marks" ON "models"."mark_id" = "marks"."id"
-			INNER JOIN "owners" ON "cars"."owner_id" = "owners"."id"
+			INNER JOIN "owners" ON "cars"."owner_id" = "owners"."id"`
+
+	getCarByIdQuery = carColumnsQuery + `
 		WHERE "cars"."id" = $1;`
 
-	getCarsQuery = `
-		SELECT "cars"."id", "reg_num", "marks"."name", "models"."name", "year", "owners"."name", "owners"."surname", "owners"."patronymic"
-		FROM "cars"
-			INNER JOIN "models" ON "cars"."model_id" = "models"."id"
-			INNER JOIN "marks" ON "models"."mark_id" = "marks"."id"
-			INNER JOIN "owners" ON "cars"."owner_id" = "owners"."id"
+	filteredCarsQuery = carColumnsQuery + `
 		WHERE ("reg_num" = $1 OR (NOT $2))
 			AND ("marks"."name" = $3 OR (NOT $4))
 			AND ("models"."name" = $5 OR (NOT $6))
 			AND ("year" = $7 OR (NOT $8))
 			AND ("owners"."name" = $9 OR (NOT $10))
 			AND ("owners"."surname" = $11 OR (NOT $12))
-			AND ("owners"."patronymic" = $13 OR (NOT $14))
+			AND ("owners"."patronymic" = $13 OR (NOT $14))`
+
+	getCarsPagination = `
 		LIMIT $15 OFFSET $16;`
 
+	// iterateCarsPagination returns all filtered cars if limit is 0
+	iterateCarsPagination = `
+		LIMIT NULLIF($15, 0) OFFSET $16;`
+
+	lockCarQuery = carColumnsQuery + `
+		WHERE "cars"."id" = $1
+		FOR UPDATE OF "cars";`
+
 	insertCarQuery = `
-		INSERT INTO "cars" (reg_num, model_id, year, owner_id) 
-		VALUES ($1, $2, $3, $4)
-		RETURNING "id";`
+		INSERT INTO "cars" (reg_num, model_id, year, owner_id, sources) 
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING "id", "version";`
 
 	updateCarQuery = `
 		UPDATE "cars"
 		SET "reg_num" = $2,
 		    "model_id" = $3,
 		    "year" = $4,
-		    "owner_id" = $5
-		WHERE "id" = $1;`
+		    "owner_id" = $5,
+		    "sources" = $6,
+		    "version" = "version" + 1
+		WHERE "id" = $1
+		RETURNING "version";`
 
 	deleteCarQuery = `
 		DELETE FROM "cars"
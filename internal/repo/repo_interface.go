@@ -2,18 +2,38 @@ package repo
 
 import (
 	"cars-service/internal/model"
+	"cars-service/pkg/logger"
+	"container/list"
 	"context"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 // Repo is an interface of the database
 type Repo interface {
 	GetCarById(ctx context.Context, id uint64) (model.Car, error)
 	GetCars(ctx context.Context, filter model.Filter) ([]model.Car, error)
+	// IterateCars calls fn for every filtered car in the order of the filter without loading all
+	// of them into memory, zero limit of the filter means no limit, iteration stops on the first
+	// error of fn
+	IterateCars(ctx context.Context, filter model.Filter, fn func(model.Car) error) error
 
 	AddCar(ctx context.Context, car model.Car) (model.Car, error)
+	// UpdateCar also starts new ownership in the history if the owner is changed. If car.Sources
+	// is nil, sources of the stored car are kept and fields changed by the update are attributed
+	// to manual input. If car.Version is set, model.ErrCarChanged is returned when the stored car
+	// has another version, so changes made since the car was read are not overwritten
 	UpdateCar(ctx context.Context, id uint64, car model.Car) (model.Car, error)
 	DeleteCar(ctx context.Context, id uint64) error
+
+	GetOwnerById(ctx context.Context, id uint64) (model.Owner, error)
+	// GetCarsByOwnerIds returns cars of every owner in one query, owners without cars are absent
+	GetCarsByOwnerIds(ctx context.Context, ownerIds []uint64) (map[uint64][]model.Car, error)
+	// GetOwnershipHistory returns ownerships of every car from the oldest one in one query
+	GetOwnershipHistory(ctx context.Context, carIds []uint64) (map[uint64][]model.Ownership, error)
+	// MergeOwners moves cars and ownerships of owners from to the owner into and deletes owners
+	// from, it returns moved cars and saves car.updated and owner.changed events for each of them
+	MergeOwners(ctx context.Context, into uint64, from []uint64) ([]model.Car, error)
 }
 
 // New creates Repo implementation
@@ -22,3 +42,206 @@ func New(pool *pgxpool.Pool) Repo {
 		Pool: pool,
 	}
 }
+
+// JobRepo is an interface of the storage of bulk import jobs
+type JobRepo interface {
+	// CreateJob saves new pending job with a pending item for every regNum
+	CreateJob(ctx context.Context, regNums []string) (model.ImportJob, error)
+	// GetJob returns the job with its progress and all its items
+	GetJob(ctx context.Context, id uint64) (model.ImportJob, error)
+	GetJobStatus(ctx context.Context, id uint64) (model.ImportStatus, error)
+	// SetJobStatus changes status of pending or running job, returns model.ErrJobFinished
+	// if the job is already finished
+	SetJobStatus(ctx context.Context, id uint64, status model.ImportStatus) error
+	// CancelJob cancels pending or running job, returns model.ErrJobFinished if the job is already finished
+	CancelJob(ctx context.Context, id uint64) error
+
+	// GetActiveJobs returns pending and running jobs without items in order of creation
+	GetActiveJobs(ctx context.Context) ([]model.ImportJob, error)
+	// GetPendingItems returns pending items of the job which are not waiting for a retry
+	GetPendingItems(ctx context.Context, jobId uint64, limit uint) ([]model.ImportItem, error)
+	// HasPendingItems reports whether the job has pending items including ones waiting for a retry
+	HasPendingItems(ctx context.Context, jobId uint64) (bool, error)
+	// RetryItem counts failed attempt of the pending item and postpones it until retryAt
+	RetryItem(ctx context.Context, id uint64, retryAt time.Time) error
+	// SetItemResult saves the result of the item, AddCar with ctx made by WithImportItem saves
+	// the result of the added car itself
+	SetItemResult(ctx context.Context, item model.ImportItem) error
+}
+
+// NewJobRepo creates JobRepo implementation
+func NewJobRepo(pool *pgxpool.Pool) JobRepo {
+	return &jobRepoImpl{
+		Pool: pool,
+	}
+}
+
+// RefreshRepo is an interface of the storage of refreshes of cars from providers
+type RefreshRepo interface {
+	// GetStaleCars returns cars synced with providers before the time, the least recently synced first
+	GetStaleCars(ctx context.Context, syncedBefore time.Time, limit uint) ([]model.Car, error)
+	SetCarSynced(ctx context.Context, carId uint64, at time.Time) error
+
+	// AddRefresh saves the refresh, a pending refresh supersedes other pending refreshes of the car.
+	// If save is not nil, it is called with ctx in which changes of Repo are made in the same
+	// transaction and the car it returns is saved in the refresh, nothing is saved if save fails
+	AddRefresh(ctx context.Context, refresh model.Refresh, save func(ctx context.Context) (model.Car, error)) (model.Refresh, error)
+	GetRefresh(ctx context.Context, id uint64) (model.Refresh, error)
+	// GetRefreshes returns the latest refreshes with the status
+	GetRefreshes(ctx context.Context, status model.RefreshStatus, limit uint) ([]model.Refresh, error)
+	// ResolveRefresh changes status of pending refresh, returns model.ErrRefreshResolved if the refresh
+	// is not pending
+	ResolveRefresh(ctx context.Context, id uint64, status model.RefreshStatus) error
+	// ApplyRefresh marks pending refresh applied and calls save with ctx in which changes of Repo
+	// are made in the same transaction, the refresh stays pending if save fails. It returns
+	// model.ErrRefreshResolved if the refresh is not pending
+	ApplyRefresh(ctx context.Context, id uint64, save func(ctx context.Context) error) error
+}
+
+// NewRefreshRepo creates RefreshRepo implementation
+func NewRefreshRepo(pool *pgxpool.Pool) RefreshRepo {
+	return &refreshRepoImpl{
+		Pool: pool,
+	}
+}
+
+// OutboxRepo is an interface of the outbox with events written by mutations of Repo
+type OutboxRepo interface {
+	// ClaimEvents returns the oldest due events in order of their creation, only the oldest pending
+	// event of every car is returned, so events of the car are delivered in order. Claimed events
+	// are not returned to any relay again until claimedUntil
+	ClaimEvents(ctx context.Context, limit uint, claimedUntil time.Time) ([]model.Event, error)
+	MarkDelivered(ctx context.Context, id uint64) error
+	// MarkFailed counts failed attempt of delivery, the event is claimed again after retryAt
+	MarkFailed(ctx context.Context, id uint64, retryAt time.Time) error
+	// MarkDead counts the last failed attempt, the event is not delivered and later events of its
+	// car are not blocked by it anymore
+	MarkDead(ctx context.Context, id uint64) error
+}
+
+// NewOutboxRepo creates OutboxRepo implementation
+func NewOutboxRepo(pool *pgxpool.Pool) OutboxRepo {
+	return &outboxRepoImpl{
+		Pool: pool,
+	}
+}
+
+// WebhookRepo is an interface of the storage of webhook subscriptions and their deliveries,
+// deliveries of events are saved by mutations of Repo in their transactions
+type WebhookRepo interface {
+	CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error)
+	GetWebhook(ctx context.Context, id uint64) (model.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]model.Webhook, error)
+	// UpdateWebhook changes everything except the secret, enabled webhook gets its failures reset
+	UpdateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error)
+	// DeleteWebhook deletes the webhook with all its deliveries
+	DeleteWebhook(ctx context.Context, id uint64) error
+	// GetDeliveries returns the latest deliveries of the webhook
+	GetDeliveries(ctx context.Context, webhookId uint64, limit uint) ([]model.WebhookDelivery, error)
+
+	// ClaimDeliveries returns pending deliveries of active webhooks which should be attempted now,
+	// claimed deliveries are not returned to any dispatcher again until claimedUntil
+	ClaimDeliveries(ctx context.Context, limit uint, claimedUntil time.Time) ([]model.WebhookDelivery, error)
+	// SetDeliveryResult saves the result of the attempt and counts consecutive failures of the webhook,
+	// the webhook is disabled after disableAfter failures
+	SetDeliveryResult(ctx context.Context, delivery model.WebhookDelivery, disableAfter int) error
+}
+
+// NewWebhookRepo creates WebhookRepo implementation
+func NewWebhookRepo(pool *pgxpool.Pool) WebhookRepo {
+	return &webhookRepoImpl{
+		Pool: pool,
+	}
+}
+
+// RateLimitRepo is an interface of the storage of counters of rate limits
+type RateLimitRepo interface {
+	// Hit counts the request of key in the window which starts at start and lasts period and returns
+	// the number of requests of key in the window, counters of ended windows are deleted
+	Hit(ctx context.Context, key string, start time.Time, period time.Duration) (int, error)
+}
+
+// NewRateLimitRepo creates RateLimitRepo implementation, counters are shared by all instances of
+// the service
+func NewRateLimitRepo(pool *pgxpool.Pool) RateLimitRepo {
+	return &rateLimitRepoImpl{
+		Pool: pool,
+	}
+}
+
+// NewMemoryRateLimitRepo creates RateLimitRepo implementation keeping counters of this instance
+// in memory
+func NewMemoryRateLimitRepo() RateLimitRepo {
+	return &memoryRateLimitRepoImpl{
+		counters: make(map[string]memoryCounter),
+	}
+}
+
+// IdempotencyRepo is an interface of the storage of requests with idempotency keys and their responses
+type IdempotencyRepo interface {
+	// StartRequest saves the pending request with the key and returns true, if the key is used by
+	// the unexpired request, it returns that request and false. Pending requests created before
+	// abandonedBefore are replaced as their processing is considered failed
+	StartRequest(ctx context.Context, key string, fingerprint string, expiresAt time.Time, abandonedBefore time.Time) (model.IdempotentRequest, bool, error)
+	// CompleteRequest saves the response of the started request
+	CompleteRequest(ctx context.Context, req model.IdempotentRequest) error
+	// DeleteRequest deletes the request, so it can be retried with the same key
+	DeleteRequest(ctx context.Context, key string) error
+}
+
+// NewIdempotencyRepo creates IdempotencyRepo implementation
+func NewIdempotencyRepo(pool *pgxpool.Pool) IdempotencyRepo {
+	return &idempotencyRepoImpl{
+		Pool: pool,
+	}
+}
+
+// ReplicatedRepo is Repo which sends writes to the primary and reads to healthy replicas in turn.
+// Reads go to the primary if there is no healthy replica, if the replica fails or if ctx is made
+// by WithPrimary or by WithSession after a write
+type ReplicatedRepo interface {
+	Repo
+	// Run checks health of replicas with the interval until ctx is done, reads go to the primary
+	// until the first check
+	Run(ctx context.Context)
+}
+
+// NewReplicatedRepo creates ReplicatedRepo implementation, primary is used for writes and as the
+// fallback of reads
+func NewReplicatedRepo(primary Repo, replicas []*pgxpool.Pool, logs logger.Logger, interval time.Duration) ReplicatedRepo {
+	r := &replicatedRepoImpl{
+		Repo:     primary,
+		logs:     logs,
+		interval: interval,
+	}
+	for _, pool := range replicas {
+		r.replicas = append(r.replicas, &replica{
+			Repo: New(pool),
+			pool: pool,
+		})
+	}
+	return r
+}
+
+// NewCachedRepo creates Repo caching up to size cars read by GetCarById of r for ttl, cars are
+// removed from the cache when they are changed through the returned Repo and their versions older
+// than the change, e.g. read from lagging replicas, are not cached during ttl
+func NewCachedRepo(r Repo, size int, ttl time.Duration) Repo {
+	return &cachedRepoImpl{
+		Repo:    r,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[uint64]*list.Element),
+	}
+}
+
+// NewMemoryRepo creates Repo implementation keeping cars in memory, it is used in tests and demos
+// and does not write change events
+func NewMemoryRepo() Repo {
+	return &memoryRepoImpl{
+		cars:   make(map[uint64]model.Car),
+		owners: make(map[uint64]model.Owner),
+	}
+}
@@ -0,0 +1,225 @@
+package repo_test
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"cars-service/internal/repo/repotest"
+	"cars-service/migrations"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// TestRepo runs the suite against the database from TEST_POSTGRES_DSN, every test gets its own
+// schema with all migrations applied, which is dropped after the test
+func TestRepo(t *testing.T) {
+	admin := newAdminPool(t)
+	repotest.Run(t, func(t *testing.T) repo.Repo {
+		return repo.New(newSchemaPool(t, admin))
+	})
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	pool := newSchemaPool(t, newAdminPool(t))
+	ctx := context.Background()
+	webhooks := repo.NewWebhookRepo(pool)
+	r := repo.New(pool)
+
+	hook, err := webhooks.CreateWebhook(ctx, model.Webhook{
+		Url:    "https://example.com/hook",
+		Events: []model.EventType{model.EventCarCreated},
+		Mark:   "Lada",
+		Secret: "secret",
+		Active: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	// only the car matching filters of the webhook gets the delivery
+	if _, err = r.AddCar(ctx, model.Car{RegNum: "A111AA150", Mark: "Lada", Model: "Vesta", Year: 2020,
+		Owner: model.Owner{Name: "Иван", Surname: "Иванов"}}); err != nil {
+		t.Fatalf("AddCar: %v", err)
+	}
+	if _, err = r.AddCar(ctx, model.Car{RegNum: "E555EE199", Mark: "Kia", Model: "Rio", Year: 2020,
+		Owner: model.Owner{Name: "Сидор", Surname: "Сидоров"}}); err != nil {
+		t.Fatalf("AddCar: %v", err)
+	}
+
+	deliveries, err := webhooks.GetDeliveries(ctx, hook.Id, 10)
+	if err != nil {
+		t.Fatalf("GetDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1: %+v", len(deliveries), deliveries)
+	}
+	if d := deliveries[0]; d.Event.Type != model.EventCarCreated || d.Event.Car.RegNum != "A111AA150" ||
+		d.Status != model.DeliveryPending {
+		t.Errorf("delivery = %+v, want pending car.created of A111AA150", d)
+	}
+}
+
+func TestAddRefresh(t *testing.T) {
+	pool := newSchemaPool(t, newAdminPool(t))
+	ctx := context.Background()
+	refreshes := repo.NewRefreshRepo(pool)
+	r := repo.New(pool)
+
+	car, err := r.AddCar(ctx, model.Car{RegNum: "A111AA150", Mark: "Lada", Model: "Vesta", Year: 2020,
+		Owner: model.Owner{Name: "Иван", Surname: "Иванов"}})
+	if err != nil {
+		t.Fatalf("AddCar: %v", err)
+	}
+	changes := []model.FieldChange{{Field: model.FieldYear, Old: "2020", New: "2021"}}
+
+	// the refresh is not saved if the car is not saved
+	failed := errors.New("car is not saved")
+	_, err = refreshes.AddRefresh(ctx, model.Refresh{CarId: car.Id, Status: model.RefreshApplied, Changes: changes, Car: car},
+		func(context.Context) (model.Car, error) {
+			return model.Car{}, failed
+		})
+	if !errors.Is(err, failed) {
+		t.Fatalf("AddRefresh error = %v, want %v", err, failed)
+	}
+	if applied, err := refreshes.GetRefreshes(ctx, model.RefreshApplied, 10); err != nil || len(applied) != 0 {
+		t.Fatalf("GetRefreshes = %+v, %v, want none", applied, err)
+	}
+
+	// the car is saved in the transaction of the refresh
+	changed := car
+	changed.Year = 2021
+	refresh, err := refreshes.AddRefresh(ctx, model.Refresh{CarId: car.Id, Status: model.RefreshApplied, Changes: changes, Car: changed},
+		func(ctx context.Context) (model.Car, error) {
+			return r.UpdateCar(ctx, car.Id, changed)
+		})
+	if err != nil {
+		t.Fatalf("AddRefresh: %v", err)
+	}
+	if refresh.ResolvedAt.IsZero() || refresh.Car.Year != 2021 || refresh.Car.Version == car.Version {
+		t.Errorf("refresh = %+v, want resolved refresh with the saved car", refresh)
+	}
+	if saved, err := r.GetCarById(ctx, car.Id); err != nil || saved.Year != 2021 {
+		t.Errorf("GetCarById = %+v, %v, want the year 2021", saved, err)
+	}
+
+	// pending refreshes are saved without the car
+	pending, err := refreshes.AddRefresh(ctx, model.Refresh{CarId: car.Id, Status: model.RefreshPending, Changes: changes, Car: changed}, nil)
+	if err != nil {
+		t.Fatalf("AddRefresh: %v", err)
+	}
+	if got, err := refreshes.GetRefresh(ctx, pending.Id); err != nil || got.Status != model.RefreshPending || !got.ResolvedAt.IsZero() {
+		t.Errorf("GetRefresh = %+v, %v, want unresolved pending refresh", got, err)
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	pool := newSchemaPool(t, newAdminPool(t))
+	ctx := context.Background()
+
+	pending, err := repo.PendingMigrations(ctx, pool, migrations.FS)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("PendingMigrations = %+v, %v, want none", pending, err)
+	}
+
+	// the migration added after the deployment is pending until it is applied
+	fsys := fstest.MapFS{"999_next.sql": {Data: []byte("CREATE TABLE next_table (id INT);")}}
+	entries, err := migrations.FS.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := migrations.FS.ReadFile(entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys[entry.Name()] = &fstest.MapFile{Data: data}
+	}
+	pending, err = repo.PendingMigrations(ctx, pool, fsys)
+	if err != nil || len(pending) != 1 || pending[0].Version != 999 {
+		t.Fatalf("PendingMigrations = %+v, %v, want version 999", pending, err)
+	}
+	applied, err := repo.Migrate(ctx, pool, fsys, 0)
+	if err != nil || len(applied) != 1 || applied[0].Version != 999 {
+		t.Fatalf("Migrate = %+v, %v, want version 999", applied, err)
+	}
+	if pending, err = repo.PendingMigrations(ctx, pool, fsys); err != nil || len(pending) != 0 {
+		t.Fatalf("PendingMigrations = %+v, %v, want none", pending, err)
+	}
+}
+
+func TestPendingMigrationsNotMigrated(t *testing.T) {
+	admin := newAdminPool(t)
+	ctx := context.Background()
+	schema := fmt.Sprintf("empty_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(ctx, "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE")
+	})
+	cfg := admin.Config().Copy()
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	// all migrations are pending without the table of migrations
+	entries, err := migrations.FS.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := repo.PendingMigrations(ctx, pool, migrations.FS)
+	if err != nil || len(pending) != len(entries) || pending[0].Version != 1 {
+		t.Fatalf("PendingMigrations = %+v, %v, want all migrations", pending, err)
+	}
+}
+
+// newAdminPool connects to the database from TEST_POSTGRES_DSN, the test is skipped if it is not set
+func newAdminPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	admin, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	return admin
+}
+
+// newSchemaPool creates the schema with all migrations applied, which is dropped after the test,
+// and returns the pool using it
+func newSchemaPool(t *testing.T, admin *pgxpool.Pool) *pgxpool.Pool {
+	t.Helper()
+	ctx := context.Background()
+	schema := fmt.Sprintf("conformance_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(ctx, "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE")
+	})
+
+	cfg := admin.Config().Copy()
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err = repo.Migrate(ctx, pool, migrations.FS, 0); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
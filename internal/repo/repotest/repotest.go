@@ -0,0 +1,598 @@
+// Package repotest provides the conformance test suite which every implementation of repo.Repo
+// must pass, so implementations are interchangeable in app
+package repotest
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Run runs the suite, newRepo must return an empty repo for every test
+func Run(t *testing.T, newRepo func(t *testing.T) repo.Repo) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, r repo.Repo)
+	}{
+		{"AddCar", testAddCar},
+		{"AddCarDuplicate", testAddCarDuplicate},
+		{"GetCarByIdNotFound", testGetCarByIdNotFound},
+		{"GetCarsFilter", testGetCarsFilter},
+		{"GetCarsPagination", testGetCarsPagination},
+		{"GetCarsSort", testGetCarsSort},
+		{"GetCarsInvalidSort", testGetCarsInvalidSort},
+		{"IterateCars", testIterateCars},
+		{"IterateCarsStop", testIterateCarsStop},
+		{"UpdateCar", testUpdateCar},
+		{"UpdateCarNotFound", testUpdateCarNotFound},
+		{"UpdateCarDuplicate", testUpdateCarDuplicate},
+		{"UpdateCarChanged", testUpdateCarChanged},
+		{"CarSources", testCarSources},
+		{"DeleteCar", testDeleteCar},
+		{"DeleteCarNotFound", testDeleteCarNotFound},
+		{"GetOwnerById", testGetOwnerById},
+		{"GetCarsByOwnerIds", testGetCarsByOwnerIds},
+		{"GetOwnershipHistory", testGetOwnershipHistory},
+		{"MergeOwners", testMergeOwners},
+		{"MergeOwnersNotFound", testMergeOwnersNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+var (
+	ivanov = model.Owner{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"}
+	petrov = model.Owner{Name: "Пётр", Surname: "Петров", Patronymic: "Петрович"}
+	sidrov = model.Owner{Name: "Сидор", Surname: "Сидоров"}
+)
+
+// sampleCars are added in this order by addSamples, values of every field differ in ASCII
+// order, so sorting does not depend on the collation of the database
+var sampleCars = []model.Car{
+	{RegNum: "A111AA150", Mark: "Lada", Model: "Vesta", Year: 2020, Owner: ivanov},
+	{RegNum: "C333CC77", Mark: "Audi", Model: "A4", Year: 2015, Owner: petrov},
+	{RegNum: "B222BB50", Mark: "Lada", Model: "Granta", Year: 2018, Owner: ivanov},
+	{RegNum: "E555EE199", Mark: "Kia", Model: "Rio", Year: 2020, Owner: sidrov},
+}
+
+func addSamples(t *testing.T, r repo.Repo) []model.Car {
+	t.Helper()
+	cars := make([]model.Car, 0, len(sampleCars))
+	for _, car := range sampleCars {
+		cars = append(cars, mustAdd(t, r, car))
+	}
+	return cars
+}
+
+func mustAdd(t *testing.T, r repo.Repo, car model.Car) model.Car {
+	t.Helper()
+	added, err := r.AddCar(context.Background(), car)
+	if err != nil {
+		t.Fatalf("AddCar(%s): %v", car.RegNum, err)
+	}
+	return added
+}
+
+func testAddCar(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	for i, car := range cars {
+		if car.Id == 0 || car.Owner.Id == 0 {
+			t.Fatalf("car %s: ids are not assigned: %+v", car.RegNum, car)
+		}
+		want := sampleCars[i]
+		want.Id = car.Id
+		want.Owner.Id = car.Owner.Id
+		want.Version = 1
+		if !reflect.DeepEqual(car, want) {
+			t.Errorf("AddCar() = %+v, want %+v", car, want)
+		}
+		got, err := r.GetCarById(ctx, car.Id)
+		if err != nil {
+			t.Fatalf("GetCarById(%d): %v", car.Id, err)
+		}
+		if !reflect.DeepEqual(got, car) {
+			t.Errorf("GetCarById(%d) = %+v, want %+v", car.Id, got, car)
+		}
+	}
+	if cars[0].Owner.Id != cars[2].Owner.Id {
+		t.Errorf("cars of the same owner have different owner ids %d and %d", cars[0].Owner.Id, cars[2].Owner.Id)
+	}
+	if cars[0].Owner.Id == cars[1].Owner.Id {
+		t.Errorf("cars of different owners have the same owner id %d", cars[0].Owner.Id)
+	}
+}
+
+func testAddCarDuplicate(t *testing.T, r repo.Repo) {
+	cars := addSamples(t, r)
+
+	car := sampleCars[1]
+	car.Owner = ivanov
+	if _, err := r.AddCar(context.Background(), car); !errors.Is(err, model.ErrDuplicateRegNum) {
+		t.Fatalf("AddCar() error = %v, want %v", err, model.ErrDuplicateRegNum)
+	}
+	got := getCars(t, r, model.Filter{Limit: 100})
+	if !reflect.DeepEqual(got, cars) {
+		t.Errorf("cars after duplicate = %+v, want %+v", got, cars)
+	}
+}
+
+func testGetCarByIdNotFound(t *testing.T, r repo.Repo) {
+	cars := addSamples(t, r)
+
+	if _, err := r.GetCarById(context.Background(), cars[len(cars)-1].Id+1); !errors.Is(err, model.ErrCarNotFound) {
+		t.Errorf("GetCarById() error = %v, want %v", err, model.ErrCarNotFound)
+	}
+}
+
+func testGetCarsFilter(t *testing.T, r repo.Repo) {
+	cars := addSamples(t, r)
+
+	tests := []struct {
+		name   string
+		filter model.Filter
+		want   []model.Car
+	}{
+		{"none", model.Filter{}, cars},
+		{"regNum", model.Filter{RegNum: "C333CC77", ByRegNum: true}, cars[1:2]},
+		{"mark", model.Filter{Mark: "Lada", ByMark: true}, []model.Car{cars[0], cars[2]}},
+		{"model", model.Filter{Model: "Rio", ByModel: true}, cars[3:4]},
+		{"year", model.Filter{Year: 2020, ByYear: true}, []model.Car{cars[0], cars[3]}},
+		{"ownerName", model.Filter{OwnerName: "Пётр", ByOwnerName: true}, cars[1:2]},
+		{"ownerSurname", model.Filter{OwnerSurname: "Иванов", ByOwnerSurname: true}, []model.Car{cars[0], cars[2]}},
+		{"ownerPatronymic", model.Filter{OwnerPatronymic: "", ByOwnerPatronymic: true}, cars[3:4]},
+		{"several", model.Filter{Mark: "Lada", ByMark: true, Year: 2018, ByYear: true}, cars[2:3]},
+		{"value without flag", model.Filter{Mark: "Audi"}, cars},
+		{"nothing", model.Filter{Mark: "BMW", ByMark: true}, []model.Car{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Limit = 100
+			if got := getCars(t, r, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCars() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testGetCarsPagination(t *testing.T, r repo.Repo) {
+	cars := addSamples(t, r)
+
+	tests := []struct {
+		name          string
+		limit, offset uint
+		want          []model.Car
+	}{
+		{"first page", 2, 0, cars[:2]},
+		{"second page", 2, 2, cars[2:]},
+		{"partial page", 3, 2, cars[2:]},
+		{"offset beyond", 2, 10, []model.Car{}},
+		{"zero limit", 0, 0, []model.Car{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getCars(t, r, model.Filter{Limit: tt.limit, Offset: tt.offset})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCars() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testGetCarsSort(t *testing.T, r repo.Repo) {
+	cars := addSamples(t, r)
+
+	tests := []struct {
+		sortBy model.CarSortField
+		desc   bool
+		want   []model.Car
+	}{
+		{"", false, cars},
+		{model.SortById, true, []model.Car{cars[3], cars[2], cars[1], cars[0]}},
+		{model.SortByRegNum, false, []model.Car{cars[0], cars[2], cars[1], cars[3]}},
+		{model.SortByMark, false, []model.Car{cars[1], cars[3], cars[0], cars[2]}},
+		{model.SortByMark, true, []model.Car{cars[2], cars[0], cars[3], cars[1]}},
+		{model.SortByModel, false, []model.Car{cars[1], cars[2], cars[3], cars[0]}},
+		// cars with equal years are ordered by id in the same direction
+		{model.SortByYear, false, []model.Car{cars[1], cars[2], cars[0], cars[3]}},
+		{model.SortByYear, true, []model.Car{cars[3], cars[0], cars[2], cars[1]}},
+	}
+	for _, tt := range tests {
+		name := string(tt.sortBy)
+		if tt.desc {
+			name += " desc"
+		}
+		t.Run(name, func(t *testing.T) {
+			got := getCars(t, r, model.Filter{Limit: 100, SortBy: tt.sortBy, SortDesc: tt.desc})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCars() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testGetCarsInvalidSort(t *testing.T, r repo.Repo) {
+	addSamples(t, r)
+
+	filter := model.Filter{Limit: 100, SortBy: "owner"}
+	if _, err := r.GetCars(context.Background(), filter); !errors.Is(err, model.ErrInvalidInput) {
+		t.Errorf("GetCars() error = %v, want %v", err, model.ErrInvalidInput)
+	}
+	err := r.IterateCars(context.Background(), filter, func(model.Car) error { return nil })
+	if !errors.Is(err, model.ErrInvalidInput) {
+		t.Errorf("IterateCars() error = %v, want %v", err, model.ErrInvalidInput)
+	}
+}
+
+func testIterateCars(t *testing.T, r repo.Repo) {
+	cars := addSamples(t, r)
+
+	tests := []struct {
+		name   string
+		filter model.Filter
+		want   []model.Car
+	}{
+		{"zero limit is no limit", model.Filter{}, cars},
+		{"offset without limit", model.Filter{Offset: 1}, cars[1:]},
+		{"limit", model.Filter{Limit: 2, Offset: 1}, cars[1:3]},
+		{"filter and sort", model.Filter{Mark: "Lada", ByMark: true, SortBy: model.SortByYear}, []model.Car{cars[2], cars[0]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []model.Car{}
+			if err := r.IterateCars(context.Background(), tt.filter, func(car model.Car) error {
+				got = append(got, car)
+				return nil
+			}); err != nil {
+				t.Fatalf("IterateCars(): %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IterateCars() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testIterateCarsStop(t *testing.T, r repo.Repo) {
+	addSamples(t, r)
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := r.IterateCars(context.Background(), model.Filter{}, func(model.Car) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("IterateCars() error = %v, want %v", err, errStop)
+	}
+	if calls != 1 {
+		t.Errorf("fn is called %d times after error, want 1", calls)
+	}
+}
+
+func testUpdateCar(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	update := model.Car{RegNum: "A111AA150", Mark: "Lada", Model: "Niva", Year: 2021, Owner: petrov}
+	got, err := r.UpdateCar(ctx, cars[0].Id, update)
+	if err != nil {
+		t.Fatalf("UpdateCar(): %v", err)
+	}
+	want := update
+	want.Id = cars[0].Id
+	want.Owner.Id = cars[1].Owner.Id
+	want.Version = cars[0].Version + 1
+	// the update has no sources, so changed fields are attributed to manual input
+	want.Sources = map[string]string{
+		model.FieldModel: model.SourceManual,
+		model.FieldYear:  model.SourceManual,
+		model.FieldOwner: model.SourceManual,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UpdateCar() = %+v, want %+v", got, want)
+	}
+	if got, err = r.GetCarById(ctx, cars[0].Id); err != nil {
+		t.Fatalf("GetCarById(): %v", err)
+	} else if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCarById() after update = %+v, want %+v", got, want)
+	}
+
+	// other cars of the previous owner are not changed
+	if got, err = r.GetCarById(ctx, cars[2].Id); err != nil {
+		t.Fatalf("GetCarById(): %v", err)
+	} else if !reflect.DeepEqual(got, cars[2]) {
+		t.Errorf("GetCarById() of other car = %+v, want %+v", got, cars[2])
+	}
+}
+
+func testUpdateCarNotFound(t *testing.T, r repo.Repo) {
+	cars := addSamples(t, r)
+
+	_, err := r.UpdateCar(context.Background(), cars[len(cars)-1].Id+1, sampleCars[0])
+	if !errors.Is(err, model.ErrCarNotFound) {
+		t.Errorf("UpdateCar() error = %v, want %v", err, model.ErrCarNotFound)
+	}
+}
+
+func testUpdateCarDuplicate(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	update := cars[0]
+	update.RegNum = cars[1].RegNum
+	if _, err := r.UpdateCar(ctx, cars[0].Id, update); !errors.Is(err, model.ErrDuplicateRegNum) {
+		t.Errorf("UpdateCar() error = %v, want %v", err, model.ErrDuplicateRegNum)
+	}
+	if got, err := r.GetCarById(ctx, cars[0].Id); err != nil {
+		t.Fatalf("GetCarById(): %v", err)
+	} else if !reflect.DeepEqual(got, cars[0]) {
+		t.Errorf("GetCarById() after duplicate = %+v, want %+v", got, cars[0])
+	}
+}
+
+func testUpdateCarChanged(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	// the car is changed by another request after it is read
+	changed := cars[0]
+	changed.Year++
+	changed, err := r.UpdateCar(ctx, cars[0].Id, changed)
+	if err != nil {
+		t.Fatalf("UpdateCar(): %v", err)
+	}
+	update := cars[0]
+	update.Mark = "Lada"
+	if _, err = r.UpdateCar(ctx, cars[0].Id, update); !errors.Is(err, model.ErrCarChanged) {
+		t.Errorf("UpdateCar() error = %v, want %v", err, model.ErrCarChanged)
+	}
+	if got, err := r.GetCarById(ctx, cars[0].Id); err != nil {
+		t.Fatalf("GetCarById(): %v", err)
+	} else if !reflect.DeepEqual(got, changed) {
+		t.Errorf("GetCarById() after stale update = %+v, want %+v", got, changed)
+	}
+}
+
+func testCarSources(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+
+	car := sampleCars[0]
+	car.Sources = map[string]string{model.FieldMark: "first", model.FieldOwner: "second"}
+	added := mustAdd(t, r, car)
+	if !reflect.DeepEqual(added.Sources, car.Sources) {
+		t.Errorf("AddCar() sources = %v, want %v", added.Sources, car.Sources)
+	}
+	// the stored car does not share the map with the caller
+	car.Sources[model.FieldMark] = "changed"
+	got, err := r.GetCarById(ctx, added.Id)
+	if err != nil {
+		t.Fatalf("GetCarById(): %v", err)
+	}
+	if got.Sources[model.FieldMark] != "first" {
+		t.Errorf("GetCarById() sources = %v, changed by the caller", got.Sources)
+	}
+
+	update := added
+	update.Sources = map[string]string{model.FieldMark: "first", model.FieldOwner: model.SourceManual}
+	if _, err := r.UpdateCar(ctx, added.Id, update); err != nil {
+		t.Fatalf("UpdateCar(): %v", err)
+	}
+	if got, err = r.GetCarById(ctx, added.Id); err != nil {
+		t.Fatalf("GetCarById(): %v", err)
+	}
+	if !reflect.DeepEqual(got.Sources, update.Sources) {
+		t.Errorf("GetCarById() sources = %v, want %v", got.Sources, update.Sources)
+	}
+}
+
+func testDeleteCar(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	if err := r.DeleteCar(ctx, cars[1].Id); err != nil {
+		t.Fatalf("DeleteCar(): %v", err)
+	}
+	if _, err := r.GetCarById(ctx, cars[1].Id); !errors.Is(err, model.ErrCarNotFound) {
+		t.Errorf("GetCarById() of deleted car error = %v, want %v", err, model.ErrCarNotFound)
+	}
+	want := []model.Car{cars[0], cars[2], cars[3]}
+	if got := getCars(t, r, model.Filter{Limit: 100}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCars() after delete = %+v, want %+v", got, want)
+	}
+	history, err := r.GetOwnershipHistory(ctx, []uint64{cars[1].Id})
+	if err != nil {
+		t.Fatalf("GetOwnershipHistory(): %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("history of deleted car = %+v, want none", history)
+	}
+
+	// the regNum of the deleted car can be used again
+	mustAdd(t, r, sampleCars[1])
+}
+
+func testDeleteCarNotFound(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	if err := r.DeleteCar(ctx, cars[len(cars)-1].Id+1); !errors.Is(err, model.ErrCarNotFound) {
+		t.Errorf("DeleteCar() error = %v, want %v", err, model.ErrCarNotFound)
+	}
+	if err := r.DeleteCar(ctx, cars[0].Id); err != nil {
+		t.Fatalf("DeleteCar(): %v", err)
+	}
+	if err := r.DeleteCar(ctx, cars[0].Id); !errors.Is(err, model.ErrCarNotFound) {
+		t.Errorf("DeleteCar() of deleted car error = %v, want %v", err, model.ErrCarNotFound)
+	}
+}
+
+func testGetOwnerById(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	got, err := r.GetOwnerById(ctx, cars[1].Owner.Id)
+	if err != nil {
+		t.Fatalf("GetOwnerById(): %v", err)
+	}
+	if got != cars[1].Owner {
+		t.Errorf("GetOwnerById() = %+v, want %+v", got, cars[1].Owner)
+	}
+
+	maxId := uint64(0)
+	for _, car := range cars {
+		maxId = max(maxId, car.Owner.Id)
+	}
+	if _, err = r.GetOwnerById(ctx, maxId+1); !errors.Is(err, model.ErrOwnerNotFound) {
+		t.Errorf("GetOwnerById() error = %v, want %v", err, model.ErrOwnerNotFound)
+	}
+}
+
+func testGetCarsByOwnerIds(t *testing.T, r repo.Repo) {
+	cars := addSamples(t, r)
+
+	// the owner of the deleted car has no cars and is absent in the result
+	if err := r.DeleteCar(context.Background(), cars[1].Id); err != nil {
+		t.Fatalf("DeleteCar(): %v", err)
+	}
+	got, err := r.GetCarsByOwnerIds(context.Background(), []uint64{cars[0].Owner.Id, cars[1].Owner.Id, cars[3].Owner.Id})
+	if err != nil {
+		t.Fatalf("GetCarsByOwnerIds(): %v", err)
+	}
+	want := map[uint64][]model.Car{
+		cars[0].Owner.Id: {cars[0], cars[2]},
+		cars[3].Owner.Id: {cars[3]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCarsByOwnerIds() = %+v, want %+v", got, want)
+	}
+}
+
+func testGetOwnershipHistory(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	car := cars[0]
+	car.Owner = petrov
+	car, err := r.UpdateCar(ctx, car.Id, car)
+	if err != nil {
+		t.Fatalf("UpdateCar(): %v", err)
+	}
+	// changes of other fields do not start new ownership
+	car.Year++
+	if _, err = r.UpdateCar(ctx, car.Id, car); err != nil {
+		t.Fatalf("UpdateCar(): %v", err)
+	}
+
+	history, err := r.GetOwnershipHistory(ctx, []uint64{cars[0].Id, cars[1].Id})
+	if err != nil {
+		t.Fatalf("GetOwnershipHistory(): %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("GetOwnershipHistory() has %d cars, want 2", len(history))
+	}
+
+	changed := history[cars[0].Id]
+	if len(changed) != 2 {
+		t.Fatalf("history of changed car has %d ownerships, want 2: %+v", len(changed), changed)
+	}
+	first, second := changed[0], changed[1]
+	if first.CarId != cars[0].Id || first.Owner != cars[0].Owner || second.Owner != cars[1].Owner {
+		t.Errorf("history of changed car = %+v, want owners %+v and %+v", changed, cars[0].Owner, cars[1].Owner)
+	}
+	if first.Till.IsZero() || first.Till.Before(first.From) || second.From.Before(first.Till) {
+		t.Errorf("first ownership %v - %v must end before second starts at %v", first.From, first.Till, second.From)
+	}
+	if !second.Till.IsZero() {
+		t.Errorf("current ownership ends at %v, want zero", second.Till)
+	}
+
+	unchanged := history[cars[1].Id]
+	if len(unchanged) != 1 || unchanged[0].Owner != cars[1].Owner || !unchanged[0].Till.IsZero() {
+		t.Errorf("history of unchanged car = %+v, want current ownership of %+v", unchanged, cars[1].Owner)
+	}
+}
+
+func testMergeOwners(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	into := cars[1].Owner
+	from := []uint64{cars[0].Owner.Id, cars[3].Owner.Id}
+	got, err := r.MergeOwners(ctx, into.Id, from)
+	if err != nil {
+		t.Fatalf("MergeOwners(): %v", err)
+	}
+
+	moved := []model.Car{cars[0], cars[2], cars[3]}
+	// moved cars are changed, so their versions are incremented
+	for i := range moved {
+		moved[i].Owner = into
+		moved[i].Version++
+	}
+	if !reflect.DeepEqual(got, moved) {
+		t.Errorf("MergeOwners() = %+v, want %+v", got, moved)
+	}
+	want := []model.Car{moved[0], cars[1], moved[1], moved[2]}
+	if got = getCars(t, r, model.Filter{Limit: 100}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCars() after merge = %+v, want %+v", got, want)
+	}
+
+	for _, id := range from {
+		if _, err = r.GetOwnerById(ctx, id); !errors.Is(err, model.ErrOwnerNotFound) {
+			t.Errorf("GetOwnerById() of merged owner error = %v, want %v", err, model.ErrOwnerNotFound)
+		}
+	}
+	history, err := r.GetOwnershipHistory(ctx, []uint64{cars[0].Id})
+	if err != nil {
+		t.Fatalf("GetOwnershipHistory(): %v", err)
+	}
+	if h := history[cars[0].Id]; len(h) != 1 || h[0].Owner != into {
+		t.Errorf("history after merge = %+v, want ownership of %+v", h, into)
+	}
+}
+
+func testMergeOwnersNotFound(t *testing.T, r repo.Repo) {
+	ctx := context.Background()
+	cars := addSamples(t, r)
+
+	missing := uint64(0)
+	for _, car := range cars {
+		missing = max(missing, car.Owner.Id+1)
+	}
+	tests := []struct {
+		name string
+		into uint64
+		from []uint64
+	}{
+		{"into", missing, []uint64{cars[0].Owner.Id}},
+		{"from", cars[1].Owner.Id, []uint64{cars[0].Owner.Id, missing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.MergeOwners(ctx, tt.into, tt.from); !errors.Is(err, model.ErrOwnerNotFound) {
+				t.Errorf("MergeOwners() error = %v, want %v", err, model.ErrOwnerNotFound)
+			}
+			// nothing is merged partially
+			if got := getCars(t, r, model.Filter{Limit: 100}); !reflect.DeepEqual(got, cars) {
+				t.Errorf("GetCars() after failed merge = %+v, want %+v", got, cars)
+			}
+		})
+	}
+}
+
+func getCars(t *testing.T, r repo.Repo, filter model.Filter) []model.Car {
+	t.Helper()
+	cars, err := r.GetCars(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("GetCars(%+v): %v", filter, err)
+	}
+	return cars
+}
@@ -0,0 +1,321 @@
+package repo
+
+import (
+	"cars-service/internal/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type webhookRepoImpl struct {
+	*pgxpool.Pool
+}
+
+func (r *webhookRepoImpl) CreateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	if err := r.QueryRow(ctx, insertWebhookQuery,
+		webhook.Url,
+		eventTypesToStrings(webhook.Events),
+		webhook.Mark,
+		webhook.Model,
+		webhook.Secret,
+		webhook.Active,
+	).Scan(&webhook.Id, &webhook.CreatedAt); err != nil {
+		return model.Webhook{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	return webhook, nil
+}
+
+func (r *webhookRepoImpl) GetWebhook(ctx context.Context, id uint64) (model.Webhook, error) {
+	webhook, err := scanWebhook(r.QueryRow(ctx, getWebhookQuery, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Webhook{}, model.ErrWebhookNotFound
+	} else if err != nil {
+		return model.Webhook{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	return webhook, nil
+}
+
+func (r *webhookRepoImpl) GetWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	return r.queryWebhooks(ctx, getWebhooksQuery)
+}
+
+func (r *webhookRepoImpl) UpdateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	if err := r.QueryRow(ctx, updateWebhookQuery,
+		webhook.Id,
+		webhook.Url,
+		eventTypesToStrings(webhook.Events),
+		webhook.Mark,
+		webhook.Model,
+		webhook.Active,
+	).Scan(&webhook.Secret, &webhook.Failures, &webhook.CreatedAt); errors.Is(err, pgx.ErrNoRows) {
+		return model.Webhook{}, model.ErrWebhookNotFound
+	} else if err != nil {
+		return model.Webhook{}, errors.Join(model.ErrDatabaseError, err)
+	}
+	return webhook, nil
+}
+
+func (r *webhookRepoImpl) DeleteWebhook(ctx context.Context, id uint64) error {
+	tx, err := r.Begin(ctx)
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if e, err := tx.Exec(ctx, deleteWebhookQuery, id); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	} else if e.RowsAffected() == 0 {
+		return model.ErrWebhookNotFound
+	}
+	if _, err = tx.Exec(ctx, deleteWebhookDeliveriesQuery, id); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+func (r *webhookRepoImpl) GetDeliveries(ctx context.Context, webhookId uint64, limit uint) ([]model.WebhookDelivery, error) {
+	return r.queryDeliveries(ctx, getDeliveriesQuery, webhookId, limit)
+}
+
+func (r *webhookRepoImpl) ClaimDeliveries(ctx context.Context, limit uint, claimedUntil time.Time) ([]model.WebhookDelivery, error) {
+	return r.queryDeliveries(ctx, claimDeliveriesQuery, limit, claimedUntil)
+}
+
+func (r *webhookRepoImpl) SetDeliveryResult(ctx context.Context, delivery model.WebhookDelivery, disableAfter int) error {
+	tx, err := r.Begin(ctx)
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err = tx.Exec(ctx, setDeliveryResultQuery,
+		delivery.Id,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.StatusCode,
+		delivery.Error,
+	); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+
+	// any successful delivery resets the counter of consecutive failures of the webhook
+	if delivery.Status == model.DeliveryDelivered {
+		_, err = tx.Exec(ctx, resetWebhookFailuresQuery, delivery.WebhookId)
+	} else {
+		_, err = tx.Exec(ctx, countWebhookFailureQuery, delivery.WebhookId, disableAfter)
+	}
+	if err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.Join(model.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+func (r *webhookRepoImpl) queryWebhooks(ctx context.Context, query string, args ...any) ([]model.Webhook, error) {
+	rows, err := r.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	var webhooks []model.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	return webhooks, nil
+}
+
+func (r *webhookRepoImpl) queryDeliveries(ctx context.Context, query string, args ...any) ([]model.WebhookDelivery, error) {
+	rows, err := r.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		var payload []byte
+		var statusCode *int
+		var errText *string
+		if err = rows.Scan(
+			&d.Id,
+			&d.WebhookId,
+			&d.Event.Type,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&statusCode,
+			&errText,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+		); err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		if err = decodeEventPayload(payload, &d.Event); err != nil {
+			return nil, errors.Join(model.ErrDatabaseError, err)
+		}
+		d.Event.Id = d.Id
+		d.Event.CarId = d.Event.Car.Id
+		d.Event.CreatedAt = d.CreatedAt
+		if statusCode != nil {
+			d.StatusCode = *statusCode
+		}
+		if errText != nil {
+			d.Error = *errText
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(model.ErrDatabaseError, err)
+	}
+	return deliveries, nil
+}
+
+// scanWebhook scans a row of any query selecting all columns of webhooks
+func scanWebhook(row pgx.Row) (model.Webhook, error) {
+	var webhook model.Webhook
+	var events []string
+	var mark, mdl *string
+	if err := row.Scan(
+		&webhook.Id,
+		&webhook.Url,
+		&events,
+		&mark,
+		&mdl,
+		&webhook.Secret,
+		&webhook.Active,
+		&webhook.Failures,
+		&webhook.CreatedAt,
+	); err != nil {
+		return model.Webhook{}, err
+	}
+	webhook.Events = make([]model.EventType, len(events))
+	for i, e := range events {
+		webhook.Events[i] = model.EventType(e)
+	}
+	if mark != nil {
+		webhook.Mark = *mark
+	}
+	if mdl != nil {
+		webhook.Model = *mdl
+	}
+	return webhook, nil
+}
+
+func eventTypesToStrings(events []model.EventType) []string {
+	res := make([]string, len(events))
+	for i, e := range events {
+		res[i] = string(e)
+	}
+	return res
+}
+
+const (
+	webhookColumns = `"id", "url", "events", "mark", "model", "secret", "active", "failures", "created_at"`
+
+	deliveryColumns = `"id", "webhook_id", "event_type", "payload", "status", "attempts", "next_attempt_at", "status_code", "error", "created_at", "updated_at"`
+
+	insertWebhookQuery = `
+		INSERT INTO "webhooks" ("url", "events", "mark", "model", "secret", "active")
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+		RETURNING "id", "created_at";`
+
+	getWebhookQuery = `
+		SELECT ` + webhookColumns + `
+		FROM "webhooks"
+		WHERE "id" = $1;`
+
+	getWebhooksQuery = `
+		SELECT ` + webhookColumns + `
+		FROM "webhooks"
+		ORDER BY "id";`
+
+	// updateWebhookQuery resets failures when the webhook is enabled again
+	updateWebhookQuery = `
+		UPDATE "webhooks"
+		SET "url" = $2,
+			"events" = $3,
+			"mark" = NULLIF($4, ''),
+			"model" = NULLIF($5, ''),
+			"failures" = CASE WHEN $6 AND NOT "active" THEN 0 ELSE "failures" END,
+			"active" = $6
+		WHERE "id" = $1
+		RETURNING "secret", "failures", "created_at";`
+
+	deleteWebhookQuery = `
+		DELETE FROM "webhooks"
+		WHERE "id" = $1;`
+
+	deleteWebhookDeliveriesQuery = `
+		DELETE FROM "webhook_deliveries"
+		WHERE "webhook_id" = $1;`
+
+	getDeliveriesQuery = `
+		SELECT ` + deliveryColumns + `
+		FROM "webhook_deliveries"
+		WHERE "webhook_id" = $1
+		ORDER BY "id" DESC
+		LIMIT $2;`
+
+	// claimDeliveriesQuery skips deliveries locked by dispatchers of other instances and moves the
+	// next attempt of claimed ones to the end of the lease, so they are sent by one dispatcher
+	claimDeliveriesQuery = `
+		UPDATE "webhook_deliveries"
+		SET "next_attempt_at" = CAST($2 AS TIMESTAMPTZ)
+		WHERE "id" IN (
+			SELECT "id"
+			FROM "webhook_deliveries"
+			WHERE "status" = 'pending'
+				AND "next_attempt_at" <= NOW()
+				AND "webhook_id" IN (SELECT "id" FROM "webhooks" WHERE "active")
+			ORDER BY "next_attempt_at"
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns + `;`
+
+	setDeliveryResultQuery = `
+		UPDATE "webhook_deliveries"
+		SET "status" = $2,
+			"attempts" = $3,
+			"next_attempt_at" = CAST($4 AS TIMESTAMPTZ),
+			"status_code" = NULLIF($5, 0),
+			"error" = NULLIF($6, ''),
+			"updated_at" = NOW()
+		WHERE "id" = $1;`
+
+	resetWebhookFailuresQuery = `
+		UPDATE "webhooks"
+		SET "failures" = 0
+		WHERE "id" = $1;`
+
+	// countWebhookFailureQuery disables the webhook after the given number of consecutive failures
+	countWebhookFailureQuery = `
+		UPDATE "webhooks"
+		SET "failures" = "failures" + 1,
+			"active" = "active" AND "failures" + 1 < $2
+		WHERE "id" = $1;`
+)
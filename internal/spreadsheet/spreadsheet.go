@@ -0,0 +1,233 @@
+package spreadsheet
+
+import (
+	"cars-service/internal/model"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Columns are the columns of exported and imported spreadsheets
+var Columns = []string{
+	"id",
+	"regNum",
+	"mark",
+	"model",
+	"year",
+	"ownerName",
+	"ownerSurname",
+	"ownerPatronymic",
+}
+
+// Row is a parsed row of imported spreadsheet, Err is set if the row can't be parsed
+type Row struct {
+	Line int
+	Car  model.Car
+	Err  error
+}
+
+// Record returns values of Columns for the car
+func Record(car model.Car) []string {
+	return []string{
+		strconv.FormatUint(car.Id, 10),
+		car.RegNum,
+		car.Mark,
+		car.Model,
+		strconv.Itoa(car.Year),
+		car.Owner.Name,
+		car.Owner.Surname,
+		car.Owner.Patronymic,
+	}
+}
+
+// XLSXWriter writes cars to XLSX spreadsheet, rows are buffered on disk by excelize
+// so the whole catalog is not kept in memory
+type XLSXWriter struct {
+	f      *excelize.File
+	sw     *excelize.StreamWriter
+	rowNum int
+}
+
+// NewXLSXWriter creates spreadsheet with the header row
+func NewXLSXWriter() (*XLSXWriter, error) {
+	f := excelize.NewFile()
+	sw, err := f.NewStreamWriter(f.GetSheetName(0))
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	header := make([]any, len(Columns))
+	for i, col := range Columns {
+		header[i] = col
+	}
+	if err = sw.SetRow("A1", header); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &XLSXWriter{
+		f:      f,
+		sw:     sw,
+		rowNum: 1,
+	}, nil
+}
+
+func (w *XLSXWriter) Write(car model.Car) error {
+	w.rowNum++
+	cell, err := excelize.CoordinatesToCellName(1, w.rowNum)
+	if err != nil {
+		return err
+	}
+	return w.sw.SetRow(cell, []any{
+		car.Id,
+		car.RegNum,
+		car.Mark,
+		car.Model,
+		car.Year,
+		car.Owner.Name,
+		car.Owner.Surname,
+		car.Owner.Patronymic,
+	})
+}
+
+// WriteTo finishes the spreadsheet and writes it to out
+func (w *XLSXWriter) WriteTo(out io.Writer) (int64, error) {
+	if err := w.sw.Flush(); err != nil {
+		return 0, err
+	}
+	return w.f.WriteTo(out)
+}
+
+// Close removes temporary files of the spreadsheet
+func (w *XLSXWriter) Close() error {
+	return w.f.Close()
+}
+
+// Read parses XLSX file if the name has .xlsx extension and CSV file otherwise
+func Read(r io.Reader, name string) ([]Row, error) {
+	if strings.HasSuffix(strings.ToLower(name), ".xlsx") {
+		return ReadXLSX(r)
+	}
+	return ReadCSV(r)
+}
+
+// ReadCSV parses CSV file with header row, only regNum column is required
+func ReadCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		}
+		rows = append(rows, parseRow(line, record, columns))
+	}
+}
+
+// ReadXLSX parses the first sheet of XLSX file with header row, only regNum column is required
+func ReadXLSX(r io.Reader) ([]Row, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	sheetRows, err := f.Rows(f.GetSheetName(0))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sheetRows.Close()
+	}()
+
+	if !sheetRows.Next() {
+		return nil, errors.New("empty spreadsheet")
+	}
+	header, err := sheetRows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for line := 2; sheetRows.Next(); line++ {
+		record, err := sheetRows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		rows = append(rows, parseRow(line, record, columns))
+	}
+	return rows, sheetRows.Error()
+}
+
+// parseHeader returns indexes of known columns in the header row
+func parseHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		for _, col := range Columns {
+			if strings.EqualFold(strings.TrimSpace(name), col) {
+				columns[col] = i
+			}
+		}
+	}
+	if _, ok := columns["regNum"]; !ok {
+		return nil, errors.New("regNum column is required")
+	}
+	return columns, nil
+}
+
+func parseRow(line int, record []string, columns map[string]int) Row {
+	value := func(col string) string {
+		if i, ok := columns[col]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := Row{
+		Line: line,
+		Car: model.Car{
+			RegNum: value("regNum"),
+			Mark:   value("mark"),
+			Model:  value("model"),
+			Owner: model.Owner{
+				Name:       value("ownerName"),
+				Surname:    value("ownerSurname"),
+				Patronymic: value("ownerPatronymic"),
+			},
+		},
+	}
+	if year := value("year"); year != "" {
+		var err error
+		if row.Car.Year, err = strconv.Atoi(year); err != nil {
+			row.Err = fmt.Errorf("%w: year must be a number", model.ErrInvalidInput)
+		}
+	}
+	return row
+}
@@ -0,0 +1,228 @@
+package webhooks
+
+import (
+	"bytes"
+	"cars-service/internal/model"
+	"cars-service/internal/outbox"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	pollInterval   = time.Second
+	batchSize      = 100
+	concurrency    = 8
+	requestTimeout = 10 * time.Second
+
+	// deliveryLease is a time for sending the claimed batch, deliveries which are not sent by then
+	// may be claimed by other dispatchers
+	deliveryLease = 5 * time.Minute
+
+	// maxAttempts is a number of attempts after which the delivery is marked as failed
+	maxAttempts = 10
+
+	// disableAfter is a number of consecutive failed attempts after which the webhook is disabled
+	disableAfter = 20
+
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+)
+
+type dispatcherImpl struct {
+	repo.WebhookRepo
+	logs logger.Logger
+	http.Client
+}
+
+func (d *dispatcherImpl) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatchBatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *dispatcherImpl) dispatchBatch(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, deliveryLease)
+	defer cancel()
+
+	deliveries, err := d.ClaimDeliveries(ctx, batchSize, time.Now().Add(deliveryLease))
+	if err != nil {
+		d.logError(0, err)
+		return
+	}
+
+	// webhooks are loaded once per batch, deliveries of the same webhook are sent concurrently
+	webhooks := make(map[uint64]model.Webhook)
+	for _, delivery := range deliveries {
+		if _, ok := webhooks[delivery.WebhookId]; ok {
+			continue
+		}
+		webhook, err := d.GetWebhook(ctx, delivery.WebhookId)
+		if err != nil {
+			d.logError(delivery.Id, err)
+			return
+		}
+		webhooks[webhook.Id] = webhook
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery model.WebhookDelivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			d.dispatch(ctx, webhooks[delivery.WebhookId], delivery)
+		}(delivery)
+	}
+	wg.Wait()
+}
+
+// dispatch makes a single attempt of the delivery and saves its result
+func (d *dispatcherImpl) dispatch(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) {
+	statusCode, err := d.send(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		// the attempt is not counted if it was interrupted by shutdown or the end of the lease,
+		// the delivery is claimed again after the lease
+		return
+	}
+
+	delivery.Attempts++
+	delivery.StatusCode = statusCode
+	delivery.Error = ""
+	switch {
+	case err == nil:
+		delivery.Status = model.DeliveryDelivered
+	case delivery.Attempts >= maxAttempts:
+		delivery.Status = model.DeliveryFailed
+		delivery.Error = err.Error()
+	default:
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(backoff(delivery.Attempts))
+	}
+
+	if err = d.SetDeliveryResult(ctx, delivery, disableAfter); err != nil {
+		d.logError(delivery.Id, err)
+	}
+}
+
+// send posts signed event to the webhook and returns the status code of the response
+func (d *dispatcherImpl) send(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) (int, error) {
+	body, err := outbox.EncodeEvent(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", strconv.FormatUint(webhook.Id, 10))
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(delivery.Id, 10))
+	req.Header.Set("X-Webhook-Event", string(delivery.Event.Type))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// errPrivateAddress is returned by attempts to send deliveries to addresses which are not public
+var errPrivateAddress = errors.New("webhook address is not public")
+
+// IsPublicAddress reports whether deliveries may be sent to the address. Loopback, private,
+// link-local, shared and unspecified addresses are rejected, so webhooks can't reach hosts of the
+// internal network
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// nonPublicPrefixes are not routed in the internet, but are not covered by methods of netip.Addr
+var nonPublicPrefixes = []netip.Prefix{
+	// "this network", connections to it may reach the local host
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// carrier-grade NAT
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// dialPublic is net.Dialer.Control which rejects connections to addresses which are not public. It
+// is called with resolved addresses, so names and redirects pointing to internal hosts are rejected
+func dialPublic(_ string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// Sign returns the value of X-Webhook-Signature header, it is HMAC-SHA256 of the timestamp
+// and the body joined with a dot, so the receiver can check both authenticity and freshness
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+func (d *dispatcherImpl) logError(deliveryId uint64, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	d.logs.Error(logger.Fields{
+		"Method":     "WebhookDispatcher",
+		"DeliveryId": deliveryId,
+	}, err.Error())
+}
@@ -0,0 +1,97 @@
+package webhooks
+
+import (
+	"cars-service/internal/model"
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// webhookRepo returns the same claimed deliveries and records results of their attempts
+type webhookRepo struct {
+	repo.WebhookRepo
+	webhook      model.Webhook
+	deliveries   []model.WebhookDelivery
+	claimedUntil time.Time
+	results      []model.WebhookDelivery
+}
+
+func (r *webhookRepo) ClaimDeliveries(_ context.Context, _ uint, claimedUntil time.Time) ([]model.WebhookDelivery, error) {
+	r.claimedUntil = claimedUntil
+	return r.deliveries, nil
+}
+
+func (r *webhookRepo) GetWebhook(context.Context, uint64) (model.Webhook, error) {
+	return r.webhook, nil
+}
+
+func (r *webhookRepo) SetDeliveryResult(_ context.Context, delivery model.WebhookDelivery, _ int) error {
+	r.results = append(r.results, delivery)
+	return nil
+}
+
+func TestDispatcherRejectsPrivateAddresses(t *testing.T) {
+	logs, err := logger.NewWithLevel("fatal")
+	if err != nil {
+		t.Fatalf("NewWithLevel: %v", err)
+	}
+	received := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		received = true
+	}))
+	defer receiver.Close()
+
+	r := &webhookRepo{
+		webhook: model.Webhook{Id: 1, Url: receiver.URL, Active: true},
+		deliveries: []model.WebhookDelivery{{Id: 1, WebhookId: 1, Event: model.Event{Type: model.EventCarCreated},
+			Status: model.DeliveryPending}},
+	}
+	d := NewDispatcher(r, logs).(*dispatcherImpl)
+	start := time.Now()
+	d.dispatchBatch(context.Background())
+
+	// the claim lasts until the end of the lease
+	if r.claimedUntil.Before(start.Add(deliveryLease)) {
+		t.Errorf("claimedUntil = %v, want at least %v", r.claimedUntil, start.Add(deliveryLease))
+	}
+	// the receiver listens on the loopback address, so the delivery is not sent
+	if received {
+		t.Error("delivery is sent to the loopback address")
+	}
+	if len(r.results) != 1 || r.results[0].Attempts != 1 || r.results[0].Status != model.DeliveryPending {
+		t.Fatalf("results %+v, want one failed attempt", r.results)
+	}
+	if _, err = d.send(context.Background(), r.webhook, r.deliveries[0]); !errors.Is(err, errPrivateAddress) {
+		t.Errorf("send error = %v, want %v", err, errPrivateAddress)
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:2800::1":    true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"::ffff:10.0.0.1": false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"0.1.2.3":         false,
+		"224.0.0.1":       false,
+	} {
+		if got := IsPublicAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublicAddress(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
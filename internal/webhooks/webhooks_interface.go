@@ -0,0 +1,34 @@
+package webhooks
+
+import (
+	"cars-service/internal/repo"
+	"cars-service/pkg/logger"
+	"context"
+	"net"
+	"net/http"
+)
+
+// Dispatcher is an interface of the background process which sends pending deliveries to webhooks
+type Dispatcher interface {
+	// Run sends deliveries until ctx is done, failed deliveries are retried with exponential backoff
+	Run(ctx context.Context)
+}
+
+// NewDispatcher creates Dispatcher implementation, deliveries are sent only to public addresses
+// and not through proxies
+func NewDispatcher(r repo.WebhookRepo, logs logger.Logger) Dispatcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: requestTimeout,
+		Control: dialPublic,
+	}).DialContext
+	return &dispatcherImpl{
+		WebhookRepo: r,
+		logs:        logs,
+		Client: http.Client{
+			Timeout:   requestTimeout,
+			Transport: transport,
+		},
+	}
+}
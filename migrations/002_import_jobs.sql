@@ -0,0 +1,17 @@
+CREATE TABLE "import_jobs" (
+    "id" SERIAL PRIMARY KEY,
+    "status" VARCHAR(16) NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE "import_items" (
+    "id" SERIAL PRIMARY KEY,
+    "job_id" INTEGER NOT NULL,
+    "reg_num" VARCHAR(100) NOT NULL,
+    "status" VARCHAR(16) NOT NULL,
+    "car_id" INTEGER,
+    "error" TEXT
+);
+
+CREATE INDEX "import_items_job_id_status_idx" ON "import_items" ("job_id", "status");
@@ -0,0 +1,11 @@
+CREATE TABLE "outbox" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "car_id" INTEGER NOT NULL,
+    "event_type" VARCHAR(32) NOT NULL,
+    "payload" JSONB NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "delivered_at" TIMESTAMP
+);
+
+CREATE INDEX "outbox_undelivered_idx" ON "outbox" ("id") WHERE "delivered_at" IS NULL;
@@ -0,0 +1,28 @@
+CREATE TABLE "webhooks" (
+    "id" SERIAL PRIMARY KEY,
+    "url" VARCHAR(2048) NOT NULL,
+    "events" VARCHAR(32)[] NOT NULL,
+    "mark" VARCHAR(100),
+    "model" VARCHAR(100),
+    "secret" VARCHAR(128) NOT NULL,
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "failures" INTEGER NOT NULL DEFAULT 0,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE "webhook_deliveries" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "webhook_id" INTEGER NOT NULL,
+    "event_type" VARCHAR(32) NOT NULL,
+    "payload" JSONB NOT NULL,
+    "status" VARCHAR(16) NOT NULL,
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "status_code" INTEGER,
+    "error" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "webhook_deliveries_webhook_id_idx" ON "webhook_deliveries" ("webhook_id", "id");
+CREATE INDEX "webhook_deliveries_pending_idx" ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
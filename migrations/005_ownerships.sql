@@ -0,0 +1,14 @@
+CREATE TABLE "ownerships" (
+    "id" SERIAL PRIMARY KEY,
+    "car_id" INTEGER NOT NULL,
+    "owner_id" INTEGER NOT NULL,
+    "started_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "ended_at" TIMESTAMP
+);
+
+CREATE INDEX "ownerships_car_id_idx" ON "ownerships" ("car_id", "id");
+CREATE INDEX "cars_owner_id_idx" ON "cars" ("owner_id");
+
+-- the history of existing cars starts with their current owners
+INSERT INTO "ownerships" ("car_id", "owner_id")
+SELECT "id", "owner_id" FROM "cars";
@@ -0,0 +1,3 @@
+-- sources map fields of the car to the names of providers which supplied them,
+-- cars added before are left without sources
+ALTER TABLE "cars" ADD COLUMN "sources" JSONB NOT NULL DEFAULT '{}';
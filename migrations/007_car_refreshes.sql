@@ -0,0 +1,16 @@
+-- existing cars are considered synced at the time of the migration
+ALTER TABLE "cars" ADD COLUMN "synced_at" TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX "cars_synced_at_idx" ON "cars" ("synced_at");
+
+CREATE TABLE "car_refreshes" (
+    "id" SERIAL PRIMARY KEY,
+    "car_id" INTEGER NOT NULL,
+    "status" VARCHAR(16) NOT NULL,
+    "payload" JSONB NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "resolved_at" TIMESTAMP
+);
+
+CREATE INDEX "car_refreshes_status_idx" ON "car_refreshes" ("status", "id");
+CREATE INDEX "car_refreshes_car_id_idx" ON "car_refreshes" ("car_id") WHERE "status" = 'pending';
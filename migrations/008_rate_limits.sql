@@ -0,0 +1,9 @@
+-- counters are lost on crash, which only resets rate limits, so the table is not logged
+CREATE UNLOGGED TABLE "rate_limits" (
+    "key" VARCHAR(128) PRIMARY KEY,
+    "window_start" TIMESTAMP NOT NULL,
+    "count" INTEGER NOT NULL,
+    "expires_at" TIMESTAMP NOT NULL
+);
+
+CREATE INDEX "rate_limits_expires_at_idx" ON "rate_limits" ("expires_at");
@@ -0,0 +1,13 @@
+-- key is the idempotency key prefixed with the client, so clients do not see responses of each other
+CREATE TABLE "idempotency_keys" (
+    "key" VARCHAR(320) PRIMARY KEY,
+    "fingerprint" CHAR(64) NOT NULL,
+    "completed" BOOLEAN NOT NULL DEFAULT FALSE,
+    "status_code" INTEGER,
+    "content_type" VARCHAR(255),
+    "body" BYTEA,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "expires_at" TIMESTAMP NOT NULL
+);
+
+CREATE INDEX "idempotency_keys_expires_at_idx" ON "idempotency_keys" ("expires_at");
@@ -0,0 +1,2 @@
+-- the version is incremented on every change of the car, HTTP ETags are computed from it
+ALTER TABLE "cars" ADD COLUMN "version" BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- items failed with temporary errors are retried with backoff until they run out of attempts
+ALTER TABLE "import_items"
+    ADD COLUMN "attempts" INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN "retry_at" TIMESTAMP NOT NULL DEFAULT NOW();
@@ -0,0 +1,8 @@
+-- failed events are retried after "retry_at" which also hides events claimed by a relay from other
+-- instances, events which run out of attempts are dead-lettered and do not block later events
+ALTER TABLE "outbox"
+    ADD COLUMN "retry_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    ADD COLUMN "dead_at" TIMESTAMP;
+
+DROP INDEX "outbox_undelivered_idx";
+CREATE INDEX "outbox_pending_idx" ON "outbox" ("car_id", "id") WHERE "delivered_at" IS NULL AND "dead_at" IS NULL;
@@ -0,0 +1,8 @@
+// Package migrations embeds SQL migrations of the database, files are applied in the order
+// of their numeric prefix
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -10,7 +10,22 @@ type loggerImpl struct {
 }
 
 func New() Logger {
+	return newLogger(logrus.New())
+}
+
+// NewWithLevel creates Logger writing only messages of the level and above, the level is one of
+// debug, info, error or fatal
+func NewWithLevel(level string) (Logger, error) {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
 	log := logrus.New()
+	log.SetLevel(lvl)
+	return newLogger(log), nil
+}
+
+func newLogger(log *logrus.Logger) Logger {
 	log.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp:          true,
 		TimestampFormat:        time.DateTime,